@@ -0,0 +1,54 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import "sort"
+
+// DiffSummaries compares two lists of secure files by Path. It returns the paths only found in a, the
+// paths only found in b, and the paths found in both whose Size or LastUpdated differ. Each list is sorted
+// and the order of a and b does not matter. Paths are compared as is, so summaries of different SDBs,
+// such as the same SDB in two environments, should have their paths made relative first
+func DiffSummaries(a, b []SecureFileSummary) (onlyA, onlyB, changed []string) {
+	var inB = make(map[string]SecureFileSummary, len(b))
+	for _, summary := range b {
+		inB[summary.Path] = summary
+	}
+	onlyA, onlyB, changed = []string{}, []string{}, []string{}
+	var inA = make(map[string]bool, len(a))
+	for _, summary := range a {
+		if inA[summary.Path] {
+			continue
+		}
+		inA[summary.Path] = true
+		other, ok := inB[summary.Path]
+		switch {
+		case !ok:
+			onlyA = append(onlyA, summary.Path)
+		case other.Size != summary.Size || !other.LastUpdated.Equal(summary.LastUpdated):
+			changed = append(changed, summary.Path)
+		}
+	}
+	for p := range inB {
+		if !inA[p] {
+			onlyB = append(onlyB, p)
+		}
+	}
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+	sort.Strings(changed)
+	return onlyA, onlyB, changed
+}
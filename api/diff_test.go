@@ -0,0 +1,72 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDiffSummaries(t *testing.T) {
+	var updated = time.Date(2017, 1, 5, 7, 18, 40, 0, time.UTC)
+	Convey("Two lists of secure files", t, func() {
+		a := []SecureFileSummary{
+			{Path: "app/sdb/same.txt", Size: 5, LastUpdated: updated},
+			{Path: "app/sdb/resized.txt", Size: 5, LastUpdated: updated},
+			{Path: "app/sdb/touched.txt", Size: 5, LastUpdated: updated},
+			{Path: "app/sdb/only-a.txt", Size: 5, LastUpdated: updated},
+		}
+		b := []SecureFileSummary{
+			{Path: "app/sdb/only-b.txt", Size: 5, LastUpdated: updated},
+			{Path: "app/sdb/touched.txt", Size: 5, LastUpdated: updated.Add(time.Second)},
+			// The same instant in another time zone is not a change
+			{Path: "app/sdb/same.txt", Size: 5, LastUpdated: updated.In(time.FixedZone("PST", -8*3600))},
+			{Path: "app/sdb/resized.txt", Size: 6, LastUpdated: updated},
+		}
+		Convey("Should report the differences", func() {
+			onlyA, onlyB, changed := DiffSummaries(a, b)
+			So(onlyA, ShouldResemble, []string{"app/sdb/only-a.txt"})
+			So(onlyB, ShouldResemble, []string{"app/sdb/only-b.txt"})
+			So(changed, ShouldResemble, []string{"app/sdb/resized.txt", "app/sdb/touched.txt"})
+		})
+		Convey("Should not depend on the order of the lists", func() {
+			reversed := make([]SecureFileSummary, len(a))
+			for i, summary := range a {
+				reversed[len(a)-1-i] = summary
+			}
+			onlyA, onlyB, changed := DiffSummaries(reversed, b)
+			So(onlyA, ShouldResemble, []string{"app/sdb/only-a.txt"})
+			So(onlyB, ShouldResemble, []string{"app/sdb/only-b.txt"})
+			So(changed, ShouldResemble, []string{"app/sdb/resized.txt", "app/sdb/touched.txt"})
+		})
+		Convey("Should swap the results when swapping the lists", func() {
+			onlyB, onlyA, changed := DiffSummaries(b, a)
+			So(onlyA, ShouldResemble, []string{"app/sdb/only-a.txt"})
+			So(onlyB, ShouldResemble, []string{"app/sdb/only-b.txt"})
+			So(changed, ShouldHaveLength, 2)
+		})
+	})
+
+	Convey("Empty lists", t, func() {
+		onlyA, onlyB, changed := DiffSummaries(nil, nil)
+		So(onlyA, ShouldBeEmpty)
+		So(onlyB, ShouldBeEmpty)
+		So(changed, ShouldBeEmpty)
+	})
+}
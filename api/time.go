@@ -0,0 +1,127 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// timestampLayouts are the layouts tried, in order, to parse a timestamp. Fractional seconds are optional in
+// all of them, and timestamps without a time zone are in UTC
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// ParseTimestamp parses a timestamp returned by Cerberus. Besides RFC 3339 with any precision, it accepts
+// time zones without a colon (+0000), a space instead of the T and timestamps without a time zone
+func ParseTimestamp(s string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Unable to parse timestamp %q", s)
+}
+
+// timestamp decodes a JSON timestamp into the time it points to. Strings are parsed with ParseTimestamp,
+// numbers are milliseconds since the epoch and null leaves the time unchanged
+type timestamp struct {
+	t *time.Time
+}
+
+func (ts timestamp) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	if millis, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+		*ts.t = time.Unix(0, millis*int64(time.Millisecond)).UTC()
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	t, err := ParseTimestamp(s)
+	if err != nil {
+		return err
+	}
+	*ts.t = t
+	return nil
+}
+
+// UnmarshalJSON decodes a Role, accepting any timestamp format supported by ParseTimestamp
+func (r *Role) UnmarshalJSON(b []byte) error {
+	type plain Role
+	var aux = struct {
+		*plain
+		Created     timestamp `json:"created_ts"`
+		LastUpdated timestamp `json:"last_updated_ts"`
+	}{plain: (*plain)(r), Created: timestamp{&r.Created}, LastUpdated: timestamp{&r.LastUpdated}}
+	return json.Unmarshal(b, &aux)
+}
+
+// UnmarshalJSON decodes a Category, accepting any timestamp format supported by ParseTimestamp
+func (c *Category) UnmarshalJSON(b []byte) error {
+	type plain Category
+	var aux = struct {
+		*plain
+		Created     timestamp `json:"created_ts"`
+		LastUpdated timestamp `json:"last_updated_ts"`
+	}{plain: (*plain)(c), Created: timestamp{&c.Created}, LastUpdated: timestamp{&c.LastUpdated}}
+	return json.Unmarshal(b, &aux)
+}
+
+// UnmarshalJSON decodes a SDBMetadata, accepting any timestamp format supported by ParseTimestamp
+func (m *SDBMetadata) UnmarshalJSON(b []byte) error {
+	type plain SDBMetadata
+	var aux = struct {
+		*plain
+		Created     timestamp `json:"created_ts"`
+		LastUpdated timestamp `json:"last_updated_ts"`
+	}{plain: (*plain)(m), Created: timestamp{&m.Created}, LastUpdated: timestamp{&m.LastUpdated}}
+	return json.Unmarshal(b, &aux)
+}
+
+// UnmarshalJSON decodes a SecureFileSummary, accepting any timestamp format supported by ParseTimestamp
+func (s *SecureFileSummary) UnmarshalJSON(b []byte) error {
+	type plain SecureFileSummary
+	var aux = struct {
+		*plain
+		Created     timestamp `json:"created_ts"`
+		LastUpdated timestamp `json:"last_updated_ts"`
+	}{plain: (*plain)(s), Created: timestamp{&s.Created}, LastUpdated: timestamp{&s.LastUpdated}}
+	return json.Unmarshal(b, &aux)
+}
+
+// UnmarshalJSON decodes a SecretVersion, accepting any timestamp format supported by ParseTimestamp
+func (v *SecretVersion) UnmarshalJSON(b []byte) error {
+	type plain SecretVersion
+	var aux = struct {
+		*plain
+		VersionCreated timestamp `json:"version_created_ts"`
+		ActionTime     timestamp `json:"action_ts"`
+	}{plain: (*plain)(v), VersionCreated: timestamp{&v.VersionCreated}, ActionTime: timestamp{&v.ActionTime}}
+	return json.Unmarshal(b, &aux)
+}
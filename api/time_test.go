@@ -0,0 +1,101 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	var expected = time.Date(2017, 1, 5, 7, 18, 40, 0, time.UTC)
+	var cases = []struct {
+		name  string
+		value string
+		want  time.Time
+	}{
+		{"RFC 3339", "2017-01-04T23:18:40-08:00", expected},
+		{"RFC 3339 in UTC", "2017-01-05T07:18:40Z", expected},
+		{"milliseconds", "2017-01-05T07:18:40.123Z", expected.Add(123 * time.Millisecond)},
+		{"microseconds", "2017-01-04T23:18:40.123456-08:00", expected.Add(123456 * time.Microsecond)},
+		{"a time zone without a colon", "2017-01-05T07:18:40.5+0000", expected.Add(500 * time.Millisecond)},
+		{"no time zone", "2017-01-05T07:18:40", expected},
+		{"a space separator", "2017-01-05 07:18:40Z", expected},
+	}
+	for _, tc := range cases {
+		Convey("A timestamp with "+tc.name, t, func() {
+			parsed, err := ParseTimestamp(tc.value)
+			Convey("Should be parsed", func() {
+				So(err, ShouldBeNil)
+				So(parsed.Equal(tc.want), ShouldBeTrue)
+			})
+		})
+	}
+	Convey("An invalid timestamp", t, func() {
+		_, err := ParseTimestamp("yesterday")
+		Convey("Should error", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestTimestampFields(t *testing.T) {
+	Convey("A secure file summary with loosely formatted timestamps", t, func() {
+		var summary SecureFileSummary
+		err := json.Unmarshal([]byte(`{
+			"name": "cert.pem",
+			"size_in_bytes": 42,
+			"created_ts": "2017-01-05T07:18:40.1+0000",
+			"last_updated_ts": 1483600720000
+		}`), &summary)
+		So(err, ShouldBeNil)
+		Convey("Should decode the timestamps and the other fields", func() {
+			So(summary.Name, ShouldEqual, "cert.pem")
+			So(summary.Size, ShouldEqual, 42)
+			So(summary.Created.Equal(time.Date(2017, 1, 5, 7, 18, 40, 100000000, time.UTC)), ShouldBeTrue)
+			So(summary.LastUpdated.Equal(time.Date(2017, 1, 5, 7, 18, 40, 0, time.UTC)), ShouldBeTrue)
+		})
+	})
+	Convey("A list of file summaries", t, func() {
+		var resp SecureFilesResponse
+		err := json.Unmarshal([]byte(`{"secure_file_summaries": [{"path": "a", "created_ts": "2017-01-05 07:18:40"}]}`), &resp)
+		So(err, ShouldBeNil)
+		Convey("Should decode the timestamps of each summary", func() {
+			So(resp.Summaries[0].Path, ShouldEqual, "a")
+			So(resp.Summaries[0].Created.Equal(time.Date(2017, 1, 5, 7, 18, 40, 0, time.UTC)), ShouldBeTrue)
+		})
+	})
+	Convey("A role with a null timestamp", t, func() {
+		var role Role
+		err := json.Unmarshal([]byte(`{"name": "owner", "created_ts": null}`), &role)
+		So(err, ShouldBeNil)
+		Convey("Should leave it zero", func() {
+			So(role.Name, ShouldEqual, "owner")
+			So(role.Created.IsZero(), ShouldBeTrue)
+		})
+	})
+	Convey("A secret version with an invalid timestamp", t, func() {
+		var version SecretVersion
+		err := json.Unmarshal([]byte(`{"id": "v1", "version_created_ts": "soon"}`), &version)
+		Convey("Should error", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
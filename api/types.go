@@ -22,7 +22,10 @@ limitations under the License.
 package api
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -167,8 +170,10 @@ type Category struct {
 
 // MetadataResponse is an object that wraps a list of SDBMetadata for convenience with pagination
 type MetadataResponse struct {
-	HasNext     bool `json:"has_next"`
-	NextOffset  int  `json:"next_offset"`
+	HasNext    bool `json:"has_next"`
+	NextOffset int  `json:"next_offset"`
+	// NextCursor is next_offset as sent by the server, see PageCursor
+	NextCursor  PageCursor `json:"-"`
 	Limit       int
 	Offset      int
 	ResultCount int           `json:"sdb_count_in_result"`
@@ -209,6 +214,8 @@ type SecureFileSummary struct {
 type SecureFilesResponse struct {
 	HasNext    bool `json:"has_next"`
 	NextOffset int  `json:"next_offset"`
+	// NextCursor is next_offset as sent by the server, see PageCursor
+	NextCursor PageCursor `json:"-"`
 	Limit      int
 	Offset     int
 
@@ -216,3 +223,145 @@ type SecureFilesResponse struct {
 	TotalCount  int                 `json:"total_file_count"`
 	Summaries   []SecureFileSummary `json:"secure_file_summaries"`
 }
+
+// ServerInfo represents the version and build information reported by a Cerberus server
+type ServerInfo struct {
+	Version string    `json:"version"`
+	Build   BuildInfo `json:"build"`
+}
+
+// AuthMethodsResponse lists the authentication methods supported by a Cerberus server, such as "user", "aws"
+// or "sts"
+type AuthMethodsResponse struct {
+	Methods []string `json:"auth_methods"`
+}
+
+// BuildInfo contains the build metadata of a Cerberus server
+type BuildInfo struct {
+	Version  string `json:"version"`
+	Artifact string `json:"artifact"`
+	Name     string `json:"name"`
+	Group    string `json:"group"`
+	Time     string `json:"time"`
+}
+
+// SecretVersion is the summary of a past version of a secret
+type SecretVersion struct {
+	// Version is the number of the version, starting at 1 for the oldest one. It is computed by the
+	// client from the version history and is what Secret.ReadVersion expects
+	Version int `json:"-"`
+	// ID identifies the version for the API
+	ID               string    `json:"id"`
+	SDBID            string    `json:"sdbox_id"`
+	Path             string    `json:"path"`
+	Action           string    `json:"action"`
+	VersionCreated   time.Time `json:"version_created_ts"`
+	VersionCreatedBy string    `json:"version_created_by"`
+	ActionPrincipal  string    `json:"action_principal"`
+	ActionTime       time.Time `json:"action_ts"`
+}
+
+// SecretVersionsResponse is an object that wraps a list of SecretVersion for convenience with pagination
+type SecretVersionsResponse struct {
+	HasNext    bool `json:"has_next"`
+	NextOffset int  `json:"next_offset"`
+	// NextCursor is next_offset as sent by the server, see PageCursor
+	NextCursor PageCursor `json:"-"`
+	Limit      int
+	Offset     int
+
+	ResultCount int             `json:"version_count_in_result"`
+	TotalCount  int             `json:"total_version_count"`
+	Versions    []SecretVersion `json:"secure_data_version_summaries"`
+}
+
+// Page is the pagination envelope shared by the paged responses, such as SecureFilesResponse
+type Page struct {
+	HasNext    bool `json:"has_next"`
+	NextOffset int  `json:"next_offset"`
+	// NextCursor is next_offset as sent by the server, see PageCursor
+	NextCursor PageCursor `json:"-"`
+}
+
+// PageCursor is the next_offset of a paged response as sent by the server. It is a number today, but it is
+// kept verbatim so it can be passed back as is to get the next page, even if the server switches to opaque
+// cursors. NextOffset is only set when the cursor is a number
+type PageCursor string
+
+// Int returns the cursor as an offset, and whether it is one
+func (c PageCursor) Int() (int, bool) {
+	n, err := strconv.Atoi(string(c))
+	return n, err == nil
+}
+
+// decodeNextOffset returns the cursor and, if it is a number, the offset of a next_offset value
+func decodeNextOffset(raw json.RawMessage) (PageCursor, int) {
+	var cursor PageCursor
+	var s string
+	switch {
+	case len(raw) == 0 || string(raw) == "null":
+		return "", 0
+	case json.Unmarshal(raw, &s) == nil:
+		cursor = PageCursor(s)
+	default:
+		cursor = PageCursor(strings.TrimSpace(string(raw)))
+	}
+	offset, _ := cursor.Int()
+	return cursor, offset
+}
+
+// UnmarshalJSON decodes a Page, accepting any next_offset value
+func (p *Page) UnmarshalJSON(b []byte) error {
+	type plain Page
+	var aux = struct {
+		*plain
+		NextOffset json.RawMessage `json:"next_offset"`
+	}{plain: (*plain)(p)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	p.NextCursor, p.NextOffset = decodeNextOffset(aux.NextOffset)
+	return nil
+}
+
+// UnmarshalJSON decodes a MetadataResponse, accepting any next_offset value
+func (m *MetadataResponse) UnmarshalJSON(b []byte) error {
+	type plain MetadataResponse
+	var aux = struct {
+		*plain
+		NextOffset json.RawMessage `json:"next_offset"`
+	}{plain: (*plain)(m)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	m.NextCursor, m.NextOffset = decodeNextOffset(aux.NextOffset)
+	return nil
+}
+
+// UnmarshalJSON decodes a SecureFilesResponse, accepting any next_offset value
+func (r *SecureFilesResponse) UnmarshalJSON(b []byte) error {
+	type plain SecureFilesResponse
+	var aux = struct {
+		*plain
+		NextOffset json.RawMessage `json:"next_offset"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	r.NextCursor, r.NextOffset = decodeNextOffset(aux.NextOffset)
+	return nil
+}
+
+// UnmarshalJSON decodes a SecretVersionsResponse, accepting any next_offset value
+func (r *SecretVersionsResponse) UnmarshalJSON(b []byte) error {
+	type plain SecretVersionsResponse
+	var aux = struct {
+		*plain
+		NextOffset json.RawMessage `json:"next_offset"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	r.NextCursor, r.NextOffset = decodeNextOffset(aux.NextOffset)
+	return nil
+}
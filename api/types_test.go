@@ -17,6 +17,7 @@ limitations under the License.
 package api
 
 import (
+	"encoding/json"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -42,3 +43,53 @@ func TestErrorResponse(t *testing.T) {
 		})
 	})
 }
+
+func TestNextOffset(t *testing.T) {
+	Convey("A paged response with a numeric next_offset", t, func() {
+		var resp SecureFilesResponse
+		err := json.Unmarshal([]byte(`{"has_next": true, "next_offset": 100, "limit": 100}`), &resp)
+		So(err, ShouldBeNil)
+		Convey("Should set both the offset and the cursor", func() {
+			So(resp.HasNext, ShouldBeTrue)
+			So(resp.Limit, ShouldEqual, 100)
+			So(resp.NextOffset, ShouldEqual, 100)
+			So(resp.NextCursor, ShouldEqual, PageCursor("100"))
+		})
+	})
+	Convey("A paged response with an opaque next_offset", t, func() {
+		var resp MetadataResponse
+		err := json.Unmarshal([]byte(`{"has_next": true, "next_offset": "abc123"}`), &resp)
+		So(err, ShouldBeNil)
+		Convey("Should keep the cursor verbatim", func() {
+			So(resp.NextCursor, ShouldEqual, PageCursor("abc123"))
+			So(resp.NextOffset, ShouldEqual, 0)
+			_, ok := resp.NextCursor.Int()
+			So(ok, ShouldBeFalse)
+		})
+	})
+	Convey("A paged response without a next_offset", t, func() {
+		var resp SecretVersionsResponse
+		err := json.Unmarshal([]byte(`{"has_next": false, "next_offset": null}`), &resp)
+		So(err, ShouldBeNil)
+		Convey("Should have an empty cursor", func() {
+			So(resp.NextCursor, ShouldEqual, PageCursor(""))
+		})
+	})
+	Convey("A page envelope", t, func() {
+		var page Page
+		err := json.Unmarshal([]byte(`{"has_next": true, "next_offset": 20, "secure_file_summaries": []}`), &page)
+		So(err, ShouldBeNil)
+		Convey("Should have the pagination fields", func() {
+			So(page.HasNext, ShouldBeTrue)
+			So(page.NextOffset, ShouldEqual, 20)
+			So(page.NextCursor, ShouldEqual, PageCursor("20"))
+		})
+	})
+	Convey("A malformed paged response", t, func() {
+		var resp SecureFilesResponse
+		err := json.Unmarshal([]byte(`{"has_next": "maybe"}`), &resp)
+		Convey("Should error", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
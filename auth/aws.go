@@ -159,8 +159,13 @@ func (a *AWSAuth) authenticate() error {
 	input := &kms.DecryptInput{
 		CiphertextBlob: binaryData,
 	}
-	result, err := a.kmsClient.Decrypt(input)
+	// The Date header tells how far off the local clock is if the request is rejected because of it
+	var date string
+	result, err := a.kmsClient.DecryptWithContext(aws.BackgroundContext(), input, withResponseHeader("Date", &date))
 	if err != nil {
+		if skewErr := clockSkewError(err, date); skewErr != nil {
+			return skewErr
+		}
 		return fmt.Errorf("Error while decrypting response: %s", err)
 	}
 	r := &api.IAMAuthResponse{}
@@ -26,6 +26,8 @@ import (
 	"time"
 
 	"github.com/Nike-Inc/cerberus-go-client/api"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
 	. "github.com/smartystreets/goconvey/convey"
@@ -57,9 +59,19 @@ type mockKMS struct {
 	kmsiface.KMSAPI
 	data        string
 	shouldError bool
+	// err is returned along with a response with the Date header date, if set
+	err  error
+	date string
 }
 
-func (m mockKMS) Decrypt(input *kms.DecryptInput) (*kms.DecryptOutput, error) {
+func (m mockKMS) DecryptWithContext(ctx aws.Context, input *kms.DecryptInput, opts ...request.Option) (*kms.DecryptOutput, error) {
+	if m.err != nil {
+		// Run the handlers of the options against a fake response
+		r := &request.Request{HTTPResponse: &http.Response{Header: http.Header{"Date": []string{m.date}}}}
+		r.ApplyOptions(opts...)
+		r.Handlers.Complete.Run(r)
+		return nil, m.err
+	}
 	if m.shouldError {
 		return nil, fmt.Errorf("Your decryption errored")
 	}
@@ -0,0 +1,100 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// clockSkewCodes are the error codes AWS returns when a signed request is too old or too far in the future
+var clockSkewCodes = map[string]bool{
+	"RequestTimeTooSkewed": true,
+	"RequestExpired":       true,
+	"SignatureExpired":     true,
+}
+
+// ClockSkewError is returned when AWS rejects a signed request because the clock of this machine is off,
+// so users can be told to synchronize it instead of seeing a generic authentication failure
+type ClockSkewError struct {
+	// LocalTime is the time of this machine when the request was rejected
+	LocalTime time.Time
+	// ServerTime is the time of AWS, parsed from the Date header of its response. It is zero if the
+	// response had no valid Date header
+	ServerTime time.Time
+	err        error
+}
+
+// Skew returns how far ahead of AWS the clock of this machine is, negative if it is behind, or 0 if the
+// time of AWS is unknown
+func (e *ClockSkewError) Skew() time.Duration {
+	if e.ServerTime.IsZero() {
+		return 0
+	}
+	return e.LocalTime.Sub(e.ServerTime)
+}
+
+func (e *ClockSkewError) Error() string {
+	if e.ServerTime.IsZero() {
+		return fmt.Sprintf("AWS rejected the request because the clock of this machine is off, synchronize it and try again: %v", e.err)
+	}
+	return fmt.Sprintf("AWS rejected the request because the clock of this machine is off by %v (local time %s, AWS time %s), synchronize it and try again: %v",
+		e.Skew(), e.LocalTime.UTC().Format(time.RFC3339), e.ServerTime.UTC().Format(time.RFC3339), e.err)
+}
+
+// Unwrap returns the error returned by AWS
+func (e *ClockSkewError) Unwrap() error {
+	return e.err
+}
+
+// clockSkewError returns a *ClockSkewError for err if it is an AWS clock skew failure, using date, the Date
+// header of the response, as the time of AWS. Other errors are returned as nil
+func clockSkewError(err error, date string) error {
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return nil
+	}
+	// Some services, such as KMS, report skew as an invalid signature
+	if !clockSkewCodes[awsErr.Code()] && !strings.Contains(awsErr.Message(), "Signature expired") {
+		return nil
+	}
+	skewErr := &ClockSkewError{
+		LocalTime: time.Now(),
+		err:       err,
+	}
+	if serverTime, err := http.ParseTime(date); err == nil {
+		skewErr.ServerTime = serverTime
+	}
+	return skewErr
+}
+
+// withResponseHeader is a request option storing the header key of the AWS response in val, even when the
+// request fails. It does nothing if there is no response
+func withResponseHeader(key string, val *string) request.Option {
+	return func(r *request.Request) {
+		r.Handlers.Complete.PushBack(func(req *request.Request) {
+			if req.HTTPResponse != nil {
+				*val = req.HTTPResponse.Header.Get(key)
+			}
+		})
+	}
+}
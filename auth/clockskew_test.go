@@ -0,0 +1,80 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Nike-Inc/cerberus-go-client/api"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClockSkewError(t *testing.T) {
+	Convey("An AWSAuth whose decryption is rejected", t, TestingServer(http.StatusOK, "/v2/auth/iam-principal", http.MethodPost, fakeAuthBody, map[string]string{
+		"X-Cerberus-Client": api.ClientHeader,
+	}, func(ts *httptest.Server) {
+		a, err := NewAWSAuth(ts.URL, "han-solo", "falcon")
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		serverTime := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+		Convey("Should return a ClockSkewError when the signature expired", func() {
+			a.kmsClient = mockKMS{
+				err:  awserr.New("InvalidSignatureException", "Signature expired: 20180614T103455Z is now earlier than 20180614T113455Z", nil),
+				date: serverTime.Format(http.TimeFormat),
+			}
+			tok, err := a.GetToken(nil)
+			So(tok, ShouldBeEmpty)
+			skewErr, ok := err.(*ClockSkewError)
+			So(ok, ShouldBeTrue)
+			So(skewErr.ServerTime.Equal(serverTime), ShouldBeTrue)
+			So(skewErr.Skew(), ShouldBeBetween, 59*time.Minute, 61*time.Minute)
+			So(err.Error(), ShouldContainSubstring, "AWS time "+serverTime.Format(time.RFC3339))
+			So(skewErr.Unwrap().(awserr.Error).Code(), ShouldEqual, "InvalidSignatureException")
+		})
+		Convey("Should return a ClockSkewError for a skew error code without a Date header", func() {
+			a.kmsClient = mockKMS{
+				err: awserr.New("RequestTimeTooSkewed", "The difference between the request time and the current time is too large", nil),
+			}
+			_, err := a.GetToken(nil)
+			skewErr, ok := err.(*ClockSkewError)
+			So(ok, ShouldBeTrue)
+			So(skewErr.ServerTime.IsZero(), ShouldBeTrue)
+			So(skewErr.Skew(), ShouldEqual, 0)
+		})
+		Convey("Should keep other AWS errors generic", func() {
+			a.kmsClient = mockKMS{
+				err:  awserr.New("AccessDeniedException", "User is not authorized to perform kms:Decrypt", nil),
+				date: serverTime.Format(http.TimeFormat),
+			}
+			_, err := a.GetToken(nil)
+			So(err, ShouldNotBeNil)
+			_, ok := err.(*ClockSkewError)
+			So(ok, ShouldBeFalse)
+		})
+	}))
+
+	Convey("A generic error", t, func() {
+		Convey("Should not be a clock skew error", func() {
+			So(clockSkewError(fmt.Errorf("Signature expired"), ""), ShouldBeNil)
+		})
+	})
+}
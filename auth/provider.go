@@ -0,0 +1,122 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/Nike-Inc/cerberus-go-client/api"
+	"github.com/Nike-Inc/cerberus-go-client/utils"
+)
+
+// AuthProvider is a minimal interface for plugging in a custom source of Cerberus tokens, such as a
+// secrets broker, without implementing all of Auth. Use NewProviderAuth to get an Auth from it
+type AuthProvider interface {
+	// GetToken returns a valid Cerberus token. It is called for every request, so it should
+	// cache the token if getting one is expensive
+	GetToken(ctx context.Context) (string, error)
+	// GetURL returns the URL of Cerberus
+	GetURL() string
+	// Refresh gets a new token, to be returned by the next calls to GetToken
+	Refresh(ctx context.Context) error
+}
+
+// ProviderAuth uses an AuthProvider to authenticate to Cerberus
+type ProviderAuth struct {
+	provider  AuthProvider
+	baseURL   *url.URL
+	loggedOut bool
+}
+
+// NewProviderAuth returns a ProviderAuth using the given provider. The URL returned by the
+// provider is validated once, when creating the ProviderAuth
+func NewProviderAuth(provider AuthProvider) (*ProviderAuth, error) {
+	if provider == nil {
+		return nil, fmt.Errorf("Provider cannot be nil")
+	}
+	if len(provider.GetURL()) == 0 {
+		return nil, fmt.Errorf("Cerberus URL cannot be empty")
+	}
+	parsedURL, err := utils.ValidateURL(provider.GetURL())
+	if err != nil {
+		return nil, err
+	}
+	return &ProviderAuth{
+		provider: provider,
+		baseURL:  parsedURL,
+	}, nil
+}
+
+// GetToken returns the token of the provider. Nil should be passed as the argument to the
+// function. The argument exists for compatibility with the Auth interface
+func (p *ProviderAuth) GetToken(f *os.File) (string, error) {
+	if p.loggedOut {
+		return "", api.ErrorUnauthenticated
+	}
+	return p.provider.GetToken(context.Background())
+}
+
+// IsAuthenticated returns true unless Logout has been called. Whether the token is valid
+// is up to the provider
+func (p *ProviderAuth) IsAuthenticated() bool {
+	return !p.loggedOut
+}
+
+// Refresh asks the provider for a new token
+func (p *ProviderAuth) Refresh() error {
+	if p.loggedOut {
+		return api.ErrorUnauthenticated
+	}
+	return p.provider.Refresh(context.Background())
+}
+
+// Logout revokes the current token of the provider. The ProviderAuth cannot be used afterwards
+func (p *ProviderAuth) Logout() error {
+	headers, err := p.GetHeaders()
+	if err != nil {
+		return err
+	}
+	if err := Logout(*p.baseURL, headers); err != nil {
+		return err
+	}
+	p.loggedOut = true
+	return nil
+}
+
+// GetHeaders returns HTTP headers used for requests, with the current token of the provider
+func (p *ProviderAuth) GetHeaders() (http.Header, error) {
+	token, err := p.GetToken(nil)
+	if err != nil {
+		return nil, err
+	}
+	var headers = http.Header{
+		"X-Cerberus-Client": []string{api.ClientHeader},
+	}
+	headers.Set("Content-Type", "application/json")
+	headers.Set("Accept", "application/json")
+	headers.Set("X-Vault-Token", token)
+	return headers, nil
+}
+
+// GetURL returns the URL for cerberus
+func (p *ProviderAuth) GetURL() *url.URL {
+	return p.baseURL
+}
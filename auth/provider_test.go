@@ -0,0 +1,124 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Nike-Inc/cerberus-go-client/api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// brokerProvider is an AuthProvider handing out numbered tokens
+type brokerProvider struct {
+	url       string
+	refreshes int
+	err       error
+}
+
+func (b *brokerProvider) GetToken(ctx context.Context) (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+	return fmt.Sprintf("death-star-%d", b.refreshes), nil
+}
+
+func (b *brokerProvider) GetURL() string {
+	return b.url
+}
+
+func (b *brokerProvider) Refresh(ctx context.Context) error {
+	b.refreshes++
+	return nil
+}
+
+var _ Auth = &ProviderAuth{}
+
+func TestNewProviderAuth(t *testing.T) {
+	Convey("A provider with a valid URL", t, func() {
+		p, err := NewProviderAuth(&brokerProvider{url: "https://test.example.com"})
+		Convey("Should return a valid ProviderAuth", func() {
+			So(err, ShouldBeNil)
+			So(p, ShouldNotBeNil)
+			So(p.GetURL().String(), ShouldEqual, "https://test.example.com")
+			So(p.IsAuthenticated(), ShouldBeTrue)
+		})
+	})
+
+	Convey("A provider with an empty URL", t, func() {
+		p, err := NewProviderAuth(&brokerProvider{})
+		Convey("Should error", func() {
+			So(err, ShouldNotBeNil)
+			So(p, ShouldBeNil)
+		})
+	})
+
+	Convey("A nil provider", t, func() {
+		p, err := NewProviderAuth(nil)
+		Convey("Should error", func() {
+			So(err, ShouldNotBeNil)
+			So(p, ShouldBeNil)
+		})
+	})
+}
+
+func TestProviderAuth(t *testing.T) {
+	Convey("A ProviderAuth", t, func() {
+		provider := &brokerProvider{url: "https://test.example.com"}
+		p, _ := NewProviderAuth(provider)
+		Convey("Should return the token of the provider", func() {
+			tok, err := p.GetToken(nil)
+			So(err, ShouldBeNil)
+			So(tok, ShouldEqual, "death-star-0")
+		})
+		Convey("Should set the token in the headers", func() {
+			headers, err := p.GetHeaders()
+			So(err, ShouldBeNil)
+			So(headers.Get("X-Vault-Token"), ShouldEqual, "death-star-0")
+			So(headers.Get("X-Cerberus-Client"), ShouldEqual, api.ClientHeader)
+		})
+		Convey("Should use the new token after a refresh", func() {
+			So(p.Refresh(), ShouldBeNil)
+			headers, _ := p.GetHeaders()
+			So(headers.Get("X-Vault-Token"), ShouldEqual, "death-star-1")
+		})
+		Convey("Should return the errors of the provider", func() {
+			provider.err = fmt.Errorf("broker unavailable")
+			_, err := p.GetHeaders()
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("A ProviderAuth being logged out", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer ts.Close()
+		p, _ := NewProviderAuth(&brokerProvider{url: ts.URL})
+		So(p.Logout(), ShouldBeNil)
+		Convey("Should not be authenticated anymore", func() {
+			So(p.IsAuthenticated(), ShouldBeFalse)
+			_, err := p.GetToken(nil)
+			So(err, ShouldEqual, api.ErrorUnauthenticated)
+			So(p.Refresh(), ShouldEqual, api.ErrorUnauthenticated)
+		})
+	})
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+)
+
+var secretBasePath = "/v1/secret"
+
+// CanAccess returns whether the current token is allowed to read the given SDB path (for example
+// "app/my-sdb/"). The policies returned when authenticating are Vault policy names that cannot be
+// mapped to paths by the client, so this performs a list request on the path as a lightweight probe.
+// A permission error results in false and a nil error. Any other failure is returned as an error
+func (c *Client) CanAccess(sdbPath string) (bool, error) {
+	resp, err := c.DoRequest(http.MethodGet, path.Join(secretBasePath, sdbPath)+"/", map[string]string{
+		"list": "true",
+	}, nil)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return false, fmt.Errorf("Error while checking access to %s: %v", sdbPath, err)
+	}
+
+	switch resp.StatusCode {
+	// Vault returns a not found when listing a path without any secret
+	case http.StatusOK, http.StatusNotFound:
+		return true, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return false, nil
+	}
+	return false, fmt.Errorf("Error while checking access to %s. Got HTTP status code %d", sdbPath, resp.StatusCode)
+}
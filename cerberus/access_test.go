@@ -0,0 +1,58 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCanAccess(t *testing.T) {
+	var cases = []struct {
+		name       string
+		statusCode int
+		expected   bool
+	}{
+		{"A readable path", http.StatusOK, true},
+		{"A readable path without secrets", http.StatusNotFound, true},
+		{"A forbidden path", http.StatusForbidden, false},
+	}
+	for _, tc := range cases {
+		Convey(tc.name, t, WithTestServer(tc.statusCode, "/v1/secret/app/my-sdb/", http.MethodGet, "{}", func(ts *httptest.Server) {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			So(cl, ShouldNotBeNil)
+			Convey("Should report whether it can be accessed", func() {
+				ok, err := cl.CanAccess("app/my-sdb/")
+				So(err, ShouldBeNil)
+				So(ok, ShouldEqual, tc.expected)
+			})
+		}))
+	}
+
+	Convey("A server error", t, WithTestServer(http.StatusInternalServerError, "/v1/secret/app/my-sdb/", http.MethodGet, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should error", func() {
+			ok, err := cl.CanAccess("app/my-sdb/")
+			So(err, ShouldNotBeNil)
+			So(ok, ShouldBeFalse)
+		})
+	}))
+}
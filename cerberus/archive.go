@@ -0,0 +1,198 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+)
+
+// Archive formats supported by GetDirArchive and PutDirArchive
+const (
+	ArchiveTar = "tar"
+	ArchiveZip = "zip"
+)
+
+// archiveWriter adds files to an archive
+type archiveWriter interface {
+	add(name string, modTime time.Time, content []byte) error
+	Close() error
+}
+
+type tarArchiveWriter struct {
+	w *tar.Writer
+}
+
+func (t *tarArchiveWriter) add(name string, modTime time.Time, content []byte) error {
+	// The zero time cannot be encoded in a tar header
+	if modTime.IsZero() {
+		modTime = time.Unix(0, 0)
+	}
+	if err := t.w.WriteHeader(&tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeReg,
+		Mode:     0600,
+		Size:     int64(len(content)),
+		ModTime:  modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := t.w.Write(content)
+	return err
+}
+
+func (t *tarArchiveWriter) Close() error {
+	return t.w.Close()
+}
+
+type zipArchiveWriter struct {
+	w *zip.Writer
+}
+
+func (z *zipArchiveWriter) add(name string, modTime time.Time, content []byte) error {
+	header := &zip.FileHeader{
+		Name:   name,
+		Method: zip.Deflate,
+	}
+	if !modTime.IsZero() {
+		header.SetModTime(modTime)
+	}
+	f, err := z.w.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	return err
+}
+
+func (z *zipArchiveWriter) Close() error {
+	return z.w.Close()
+}
+
+// newArchiveWriter returns a writer for the given archive format
+func newArchiveWriter(w io.Writer, format string) (archiveWriter, error) {
+	switch format {
+	case ArchiveTar:
+		return &tarArchiveWriter{w: tar.NewWriter(w)}, nil
+	case ArchiveZip:
+		return &zipArchiveWriter{w: zip.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q. Use %q or %q", format, ArchiveTar, ArchiveZip)
+	}
+}
+
+// GetDirArchive downloads all secure files under secureRootPath and writes them to w as a single
+// archive in the given format (ArchiveTar or ArchiveZip). Each file is stored with its path relative
+// to secureRootPath and, when the server sends it, its last updated time. Files are downloaded one at a time, so only one file is
+// held in memory
+func (r *SecureFile) GetDirArchive(secureRootPath string, w io.Writer, format string) error {
+	archive, err := newArchiveWriter(w, format)
+	if err != nil {
+		return err
+	}
+	root := r.resolvePath(secureRootPath)
+	summaries, err := r.ListAll(absoluteSecurePath(root))
+	if err != nil {
+		return err
+	}
+	for _, summary := range summaries {
+		rel, err := relativeSecurePath(root, summary.Path)
+		if err != nil {
+			return err
+		}
+		var content bytes.Buffer
+		if err := r.Get(absoluteSecurePath(summary.Path), &content); err != nil {
+			return fmt.Errorf("error while archiving secure file %s: %v", summary.Path, err)
+		}
+		if err := archive.add(rel, summary.LastUpdated, content.Bytes()); err != nil {
+			return fmt.Errorf("error while archiving secure file %s: %v", summary.Path, err)
+		}
+	}
+	return archive.Close()
+}
+
+// PutDirArchive reads an archive in the given format (ArchiveTar or ArchiveZip) and uploads each file it
+// contains to secureBasePath joined with the name of the entry. Directory entries are skipped. Entries
+// pointing outside of secureBasePath are rejected. Zip archives need random access, so they are read in
+// memory first; tar archives are streamed
+func (r *SecureFile) PutDirArchive(secureBasePath string, reader io.Reader, format string) error {
+	base := r.resolvePath(secureBasePath)
+	switch format {
+	case ArchiveTar:
+		tr := tar.NewReader(reader)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("error while reading archive: %v", err)
+			}
+			if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+				continue
+			}
+			if err := r.putArchiveEntry(base, header.Name, tr); err != nil {
+				return err
+			}
+		}
+	case ArchiveZip:
+		content, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return fmt.Errorf("error while reading archive: %v", err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			return fmt.Errorf("error while reading archive: %v", err)
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("error while reading archive entry %s: %v", f.Name, err)
+			}
+			err = r.putArchiveEntry(base, f.Name, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported archive format %q. Use %q or %q", format, ArchiveTar, ArchiveZip)
+	}
+}
+
+// putArchiveEntry uploads a single archive entry under base
+func (r *SecureFile) putArchiveEntry(base, name string, content io.Reader) error {
+	rel := path.Clean(name)
+	if rel == "." || rel == ".." || path.IsAbs(rel) || strings.HasPrefix(rel, "../") {
+		return fmt.Errorf("error while restoring archive entry %s: invalid entry name", name)
+	}
+	if err := r.Put(absoluteSecurePath(path.Join(base, rel)), path.Base(rel), content); err != nil {
+		return fmt.Errorf("error while restoring archive entry %s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// readTarArchive returns the content and modification time of each file of a tar archive
+func readTarArchive(t *testing.T, b []byte) (map[string]string, map[string]time.Time) {
+	files := map[string]string{}
+	times := map[string]time.Time{}
+	tr := tar.NewReader(bytes.NewReader(b))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, times
+		}
+		if err != nil {
+			t.Fatalf("Error reading tar archive: %v", err)
+		}
+		content, _ := ioutil.ReadAll(tr)
+		files[header.Name] = string(content)
+		times[header.Name] = header.ModTime
+	}
+}
+
+// readZipArchive returns the content and modification time of each file of a zip archive
+func readZipArchive(t *testing.T, b []byte) (map[string]string, map[string]time.Time) {
+	files := map[string]string{}
+	times := map[string]time.Time{}
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("Error reading zip archive: %v", err)
+	}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Error reading zip entry: %v", err)
+		}
+		content, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(content)
+		times[f.Name] = f.ModTime()
+	}
+	return files, times
+}
+
+func TestGetDirArchive(t *testing.T) {
+	updated := time.Date(2018, 6, 14, 10, 34, 56, 0, time.UTC)
+	expected := map[string]string{
+		"a.txt":     "hello",
+		"sub/b.txt": "world",
+	}
+	Convey("A folder of secure files", t, func() {
+		server := newFakeSecureFileServer(map[string]string{
+			"app/sdb/a.txt":     "hello",
+			"app/sdb/sub/b.txt": "world",
+			"app/other/c.txt":   "ignored",
+		})
+		server.updated = updated
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should be archived as tar", func() {
+			var buf bytes.Buffer
+			So(cl.SecureFile().GetDirArchive("app/sdb", &buf, ArchiveTar), ShouldBeNil)
+			files, times := readTarArchive(t, buf.Bytes())
+			So(files, ShouldResemble, expected)
+			So(times["a.txt"].Equal(updated), ShouldBeTrue)
+		})
+		Convey("Should be archived as zip", func() {
+			var buf bytes.Buffer
+			So(cl.SecureFile().GetDirArchive("app/sdb", &buf, ArchiveZip), ShouldBeNil)
+			files, times := readZipArchive(t, buf.Bytes())
+			So(files, ShouldResemble, expected)
+			So(times["sub/b.txt"].Equal(updated), ShouldBeTrue)
+		})
+		Convey("Should reject an unknown format", func() {
+			var buf bytes.Buffer
+			So(cl.SecureFile().GetDirArchive("app/sdb", &buf, "rar"), ShouldNotBeNil)
+			So(server.downloads, ShouldEqual, 0)
+		})
+	})
+
+	Convey("Files without a last updated time", t, func() {
+		server := newFakeSecureFileServer(map[string]string{"app/sdb/a.txt": "hello"})
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		Convey("Should still be archived", func() {
+			for _, format := range []string{ArchiveTar, ArchiveZip} {
+				var buf bytes.Buffer
+				So(cl.SecureFile().GetDirArchive("app/sdb", &buf, format), ShouldBeNil)
+			}
+		})
+	})
+}
+
+// writeTestArchive returns an archive in the given format containing the given files. Names ending with a
+// slash are added as directories
+func writeTestArchive(t *testing.T, format string, names []string, files map[string]string) []byte {
+	var buf bytes.Buffer
+	switch format {
+	case ArchiveTar:
+		tw := tar.NewWriter(&buf)
+		for _, name := range names {
+			header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(files[name])), Typeflag: tar.TypeReg}
+			if strings.HasSuffix(name, "/") {
+				header.Typeflag = tar.TypeDir
+				header.Mode = 0755
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				t.Fatalf("Error writing tar header: %v", err)
+			}
+			tw.Write([]byte(files[name]))
+		}
+		tw.Close()
+	case ArchiveZip:
+		zw := zip.NewWriter(&buf)
+		for _, name := range names {
+			f, err := zw.Create(name)
+			if err != nil {
+				t.Fatalf("Error writing zip entry: %v", err)
+			}
+			f.Write([]byte(files[name]))
+		}
+		zw.Close()
+	}
+	return buf.Bytes()
+}
+
+func TestPutDirArchive(t *testing.T) {
+	files := map[string]string{
+		"a.txt":     "hello",
+		"sub/b.txt": "world",
+	}
+	names := []string{"sub/", "a.txt", "sub/b.txt"}
+	for _, format := range []string{ArchiveTar, ArchiveZip} {
+		Convey("A "+format+" archive", t, func() {
+			server := newFakeSecureFileServer(map[string]string{})
+			ts := httptest.NewServer(server)
+			Reset(func() {
+				ts.Close()
+			})
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			So(cl, ShouldNotBeNil)
+			Convey("Should upload each file and skip directories", func() {
+				archive := writeTestArchive(t, format, names, files)
+				So(cl.SecureFile().PutDirArchive("app/sdb", bytes.NewReader(archive), format), ShouldBeNil)
+				So(server.uploads, ShouldEqual, 2)
+				So(string(server.files["app/sdb/a.txt"]), ShouldEqual, "hello")
+				So(string(server.files["app/sdb/sub/b.txt"]), ShouldEqual, "world")
+			})
+			Convey("Should round trip with GetDirArchive", func() {
+				archive := writeTestArchive(t, format, names, files)
+				So(cl.SecureFile().PutDirArchive("app/sdb", bytes.NewReader(archive), format), ShouldBeNil)
+				var buf bytes.Buffer
+				So(cl.SecureFile().GetDirArchive("app/sdb", &buf, format), ShouldBeNil)
+				var restored map[string]string
+				if format == ArchiveTar {
+					restored, _ = readTarArchive(t, buf.Bytes())
+				} else {
+					restored, _ = readZipArchive(t, buf.Bytes())
+				}
+				So(restored, ShouldResemble, files)
+			})
+			Convey("Should reject entries outside of the base path", func() {
+				archive := writeTestArchive(t, format, []string{"../escape.txt"}, map[string]string{"../escape.txt": "bad"})
+				err := cl.SecureFile().PutDirArchive("app/sdb", bytes.NewReader(archive), format)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "../escape.txt")
+				So(server.uploads, ShouldEqual, 0)
+			})
+		})
+	}
+
+	Convey("A failed upload", t, WithTestServer(http.StatusInternalServerError, "/v1/secure-file/app/sdb/a.txt", http.MethodPost, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		Convey("Should name the entry in the error", func() {
+			archive := writeTestArchive(t, ArchiveTar, []string{"a.txt"}, files)
+			err := cl.SecureFile().PutDirArchive("app/sdb", bytes.NewReader(archive), ArchiveTar)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "archive entry a.txt")
+		})
+	}))
+
+	Convey("An unknown format", t, func() {
+		cl, _ := NewClient(GenerateMockAuth("http://127.0.0.1:32876", "a-cool-token", false, false), nil)
+		So(cl.SecureFile().PutDirArchive("app/sdb", bytes.NewReader(nil), "rar"), ShouldNotBeNil)
+	})
+}
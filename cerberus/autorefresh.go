@@ -0,0 +1,159 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// autoRefreshRatio is the part of the TTL of the token after which it is refreshed
+	autoRefreshRatio = 0.8
+	// autoRefreshJitter is the largest part of the TTL added to or removed from the refresh delay, so
+	// clients started together don't all refresh at the same time
+	autoRefreshJitter = 0.1
+	// maxAutoRefreshBackoff caps the wait between failed refreshes
+	maxAutoRefreshBackoff = time.Minute
+)
+
+// ErrorAutoRefreshRunning is returned by StartAutoRefresh when the token is already refreshed in the background
+var ErrorAutoRefreshRunning = fmt.Errorf("Token auto refresh is already running")
+
+// autoRefresh is the state of the background token refresh. It is a pointer in Client so clients returned
+// by WithSDB share it with the client they come from, like the token
+type autoRefresh struct {
+	lock   sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartAutoRefresh refreshes the token in the background at about 80% of its TTL, with some jitter,
+// until ctx is done or Close is called. The TTL is looked up after each refresh, and a token which
+// does not expire is not refreshed. Failed refreshes are retried with an exponential backoff starting at
+// the wait between retries (see WithMaxRetries) and capped to a minute, and are reported to the handler
+// set with WithAutoRefreshErrorHandler, if any
+func (c *Client) StartAutoRefresh(ctx context.Context) error {
+	c.autoRefresh.lock.Lock()
+	defer c.autoRefresh.lock.Unlock()
+	if c.autoRefresh.cancel != nil {
+		return ErrorAutoRefreshRunning
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	c.autoRefresh.cancel = cancel
+	c.autoRefresh.done = done
+	go func() {
+		defer close(done)
+		c.autoRefreshLoop(ctx)
+	}()
+	return nil
+}
+
+// Close stops the background token refresh started with StartAutoRefresh and waits for it to return.
+// The client can still be used after Close
+func (c *Client) Close() error {
+	c.autoRefresh.lock.Lock()
+	cancel, done := c.autoRefresh.cancel, c.autoRefresh.done
+	c.autoRefresh.cancel, c.autoRefresh.done = nil, nil
+	c.autoRefresh.lock.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	return nil
+}
+
+// autoRefreshLoop refreshes the token before it expires until ctx is done
+func (c *Client) autoRefreshLoop(ctx context.Context) {
+	initialBackoff := c.retryWait
+	if initialBackoff <= 0 {
+		initialBackoff = defaultRetryWait
+	}
+	backoff := initialBackoff
+	for {
+		err := c.refreshBeforeExpiry(ctx)
+		if err == errTokenDoesNotExpire || ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			backoff = initialBackoff
+			continue
+		}
+		if c.autoRefreshErrorHandler != nil {
+			c.autoRefreshErrorHandler(err)
+		}
+		if !c.sleepContext(ctx, backoff) {
+			return
+		}
+		if backoff *= 2; backoff > maxAutoRefreshBackoff {
+			backoff = maxAutoRefreshBackoff
+		}
+	}
+}
+
+// errTokenDoesNotExpire is returned by refreshBeforeExpiry for tokens without a TTL
+var errTokenDoesNotExpire = fmt.Errorf("Token does not expire")
+
+// refreshBeforeExpiry waits until the current token is close to expiring and refreshes it
+func (c *Client) refreshBeforeExpiry(ctx context.Context) error {
+	tok, err := c.currentToken()
+	if err != nil {
+		return err
+	}
+	ttl, err := c.tokenTTL(ctx)
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		return errTokenDoesNotExpire
+	}
+	jitter := (rand.Float64()*2 - 1) * autoRefreshJitter
+	if !c.sleepContext(ctx, time.Duration(float64(ttl)*(autoRefreshRatio+jitter))) {
+		return ctx.Err()
+	}
+	// Nothing is refreshed if the token was refreshed in the meantime
+	_, err = c.refreshToken(tok)
+	return err
+}
+
+// tokenTTL returns how long the current token is still valid, or 0 if it does not expire
+func (c *Client) tokenTTL(ctx context.Context) (time.Duration, error) {
+	resp, err := c.doJSONRequest(withoutRefresh(ctx), http.MethodGet, tokenLookupSelfPath, map[string]string{}, nil)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Error while looking up token: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("Error while looking up token. Got HTTP status code %d", resp.StatusCode)
+	}
+	var lookup struct {
+		Data struct {
+			TTL int64 `json:"ttl"`
+		} `json:"data"`
+	}
+	if err := c.decodeResponse(resp.Body, &lookup); err != nil {
+		return 0, err
+	}
+	return time.Duration(lookup.Data.TTL) * time.Second, nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// tokenLookupServer answers token lookups with the given body and status code
+func tokenLookupServer(code int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/auth/token/lookup-self" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(code)
+		w.Write([]byte(body))
+	}))
+}
+
+// refreshCount returns the number of refreshes of the mock authentication of a client
+func refreshCount(cl *Client, m *MockAuth) int {
+	cl.authLock.RLock()
+	defer cl.authLock.RUnlock()
+	return m.refreshes
+}
+
+func TestAutoRefresh(t *testing.T) {
+	Convey("A token about to expire", t, func() {
+		ts := tokenLookupServer(http.StatusOK, `{"data": {"ttl": 1}}`)
+		m := GenerateMockAuth(ts.URL, "a-cool-token", false, false)
+		cl, _ := NewClient(m, nil)
+		Reset(func() {
+			cl.Close()
+			ts.Close()
+		})
+		Convey("Should be refreshed before it expires", func() {
+			So(cl.StartAutoRefresh(context.Background()), ShouldBeNil)
+			time.Sleep(1200 * time.Millisecond)
+			So(refreshCount(cl, m), ShouldEqual, 1)
+			r, err := cl.Secret().newRequest(http.MethodGet, "app/sdb/db")
+			So(err, ShouldBeNil)
+			So(r.ClientToken, ShouldEqual, refreshedToken)
+		})
+		Convey("Should only be refreshed by one loop", func() {
+			So(cl.StartAutoRefresh(context.Background()), ShouldBeNil)
+			So(cl.StartAutoRefresh(context.Background()), ShouldEqual, ErrorAutoRefreshRunning)
+		})
+		Convey("Should not be refreshed once closed", func() {
+			So(cl.StartAutoRefresh(context.Background()), ShouldBeNil)
+			So(cl.Close(), ShouldBeNil)
+			time.Sleep(1200 * time.Millisecond)
+			So(refreshCount(cl, m), ShouldEqual, 0)
+			Convey("And should be able to start again", func() {
+				So(cl.StartAutoRefresh(context.Background()), ShouldBeNil)
+			})
+		})
+		Convey("Should not be refreshed once the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			So(cl.StartAutoRefresh(ctx), ShouldBeNil)
+			cancel()
+			time.Sleep(1200 * time.Millisecond)
+			So(refreshCount(cl, m), ShouldEqual, 0)
+		})
+	})
+
+	Convey("A token which does not expire", t, func() {
+		ts := tokenLookupServer(http.StatusOK, `{"data": {"ttl": 0}}`)
+		defer ts.Close()
+		m := GenerateMockAuth(ts.URL, "a-cool-token", false, false)
+		cl, _ := NewClient(m, nil)
+		Convey("Should not be refreshed", func() {
+			So(cl.StartAutoRefresh(context.Background()), ShouldBeNil)
+			So(cl.Close(), ShouldBeNil)
+			So(refreshCount(cl, m), ShouldEqual, 0)
+		})
+	})
+
+	Convey("A failing token lookup", t, func() {
+		ts := tokenLookupServer(http.StatusInternalServerError, "")
+		defer ts.Close()
+		errs := make(chan error, 10)
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithAutoRefreshErrorHandler(func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		}))
+		Convey("Should be retried and reported", func() {
+			So(cl.StartAutoRefresh(context.Background()), ShouldBeNil)
+			time.Sleep(250 * time.Millisecond)
+			So(cl.Close(), ShouldBeNil)
+			// The lookup fails right away, then after 100ms and 300ms
+			So(len(errs), ShouldBeBetweenOrEqual, 2, 3)
+			So((<-errs).Error(), ShouldContainSubstring, "Got HTTP status code 500")
+		})
+	})
+}
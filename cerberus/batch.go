@@ -0,0 +1,155 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"sync"
+)
+
+// BatchResult is the outcome of an operation applied to many paths. Paths that failed can be retried
+// by passing the keys of Failed to the same batch method
+type BatchResult struct {
+	Succeeded []string
+	Failed    map[string]error
+	lock      sync.Mutex
+}
+
+func newBatchResult() *BatchResult {
+	return &BatchResult{
+		Succeeded: []string{},
+		Failed:    map[string]error{},
+	}
+}
+
+// add records the outcome for a path. It is safe to call concurrently
+func (b *BatchResult) add(p string, err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if err != nil {
+		b.Failed[p] = err
+		return
+	}
+	b.Succeeded = append(b.Succeeded, p)
+}
+
+// HasErrors returns whether any path failed
+func (b *BatchResult) HasErrors() bool {
+	return len(b.Failed) > 0
+}
+
+// Err returns an error describing all failed paths, or nil if there are none. The error of each path
+// can be matched with errors.Is and errors.As
+func (b *BatchResult) Err() error {
+	if !b.HasErrors() {
+		return nil
+	}
+	var merr = &multiError{total: len(b.Failed) + len(b.Succeeded)}
+	for p, err := range b.Failed {
+		merr.add(p, err)
+	}
+	return merr.errorOrNil()
+}
+
+// BatchOptions controls how batch operations are run
+type BatchOptions struct {
+	// Concurrency is the maximum number of parallel requests. Values under 1 mean 1
+	Concurrency int
+	// StopOnFirstError cancels the remaining work as soon as one operation fails, instead of attempting
+	// all of them. Operations not attempted are recorded in Failed with context.Canceled
+	StopOnFirstError bool
+}
+
+// runBatch calls fn for each path using up to concurrency goroutines and collects the results
+func runBatch(paths []string, concurrency int, fn func(p string) error) *BatchResult {
+	result, _ := runBatchContext(context.Background(), paths, BatchOptions{Concurrency: concurrency}, func(ctx context.Context, p string) error {
+		return fn(p)
+	})
+	return result
+}
+
+// runBatchContext is runBatch with a context and options. Once the context is done, no new call is started
+// and the remaining paths are recorded as failed with the context error, which is also returned. When
+// stopping on the first error, the context passed to fn is canceled at the first failure, which is returned
+func runBatchContext(ctx context.Context, paths []string, opts BatchOptions, fn func(ctx context.Context, p string) error) (*BatchResult, error) {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	parent := ctx
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	var firstErr error
+	var stop sync.Once
+
+	result := newBatchResult()
+	var wg sync.WaitGroup
+	work := make(chan string)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range work {
+				err := fn(ctx, p)
+				result.add(p, err)
+				if err != nil && opts.StopOnFirstError {
+					stop.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+	for i, p := range paths {
+		if ctx.Err() == nil {
+			select {
+			case work <- p:
+				continue
+			case <-ctx.Done():
+			}
+		}
+		for _, skipped := range paths[i:] {
+			result.add(skipped, ctx.Err())
+		}
+		break
+	}
+	close(work)
+	wg.Wait()
+	if firstErr != nil {
+		return result, firstErr
+	}
+	return result, parent.Err()
+}
+
+// DeleteBatch deletes the given secure files using up to concurrency parallel requests
+func (r *SecureFile) DeleteBatch(secureFilePaths []string, concurrency int) *BatchResult {
+	return runBatch(secureFilePaths, concurrency, r.Delete)
+}
+
+// DeleteBatchContext is DeleteBatch bounded by a context, typically with a deadline for the whole batch.
+// Once the context is done, requests in flight are canceled and no new one is sent. The returned result
+// is then partial: files not attempted are in Failed with the context error, which is also returned
+func (r *SecureFile) DeleteBatchContext(ctx context.Context, secureFilePaths []string, concurrency int) (*BatchResult, error) {
+	return r.DeleteBatchWithOptions(ctx, secureFilePaths, BatchOptions{Concurrency: concurrency})
+}
+
+// DeleteBatchWithOptions is DeleteBatchContext with options. If StopOnFirstError is set, it returns
+// the first error as soon as a delete fails
+func (r *SecureFile) DeleteBatchWithOptions(ctx context.Context, secureFilePaths []string, opts BatchOptions) (*BatchResult, error) {
+	return runBatchContext(ctx, secureFilePaths, opts, r.delete)
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBatchResult(t *testing.T) {
+	Convey("A batch result without failures", t, func() {
+		result := newBatchResult()
+		result.add("a", nil)
+		Convey("Should not have errors", func() {
+			So(result.HasErrors(), ShouldBeFalse)
+			So(result.Err(), ShouldBeNil)
+			So(result.Succeeded, ShouldResemble, []string{"a"})
+		})
+	})
+
+	Convey("A batch result with failures", t, func() {
+		result := newBatchResult()
+		result.add("a", nil)
+		result.add("c", fmt.Errorf("boom"))
+		result.add("b", fmt.Errorf("bang"))
+		Convey("Should report all failures in order", func() {
+			So(result.HasErrors(), ShouldBeTrue)
+			So(result.Failed, ShouldHaveLength, 2)
+			So(result.Err().Error(), ShouldEqual, "2 of 3 operations failed: b: bang; c: boom")
+		})
+	})
+}
+
+func TestDeleteBatch(t *testing.T) {
+	Convey("A batch delete", t, func() {
+		server := newFakeSecureFileServer(map[string]string{
+			"app/sdb/a.txt": "hello",
+			"app/sdb/b.txt": "world",
+		})
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should report which deletes failed", func() {
+			result := cl.SecureFile().DeleteBatch([]string{"app/sdb/a.txt", "app/sdb/missing.txt", "app/sdb/b.txt"}, 2)
+			So(result.Succeeded, ShouldHaveLength, 2)
+			So(result.Failed, ShouldHaveLength, 1)
+			So(result.Failed["app/sdb/missing.txt"], ShouldEqual, ErrorSecureFileNotFound)
+			So(server.files, ShouldBeEmpty)
+		})
+	})
+}
+
+// errorIs is errors.Is, which is not available in all the Go versions the client is built with
+func errorIs(err, target error) bool {
+	if err == target {
+		return true
+	}
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		for _, wrapped := range e.Unwrap() {
+			if errorIs(wrapped, target) {
+				return true
+			}
+		}
+	case interface{ Unwrap() error }:
+		return errorIs(e.Unwrap(), target)
+	}
+	return false
+}
+
+func TestDeleteBatchContext(t *testing.T) {
+	Convey("A batch delete against a server which hangs", t, func() {
+		var lock sync.Mutex
+		var requests int
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			requests++
+			lock.Unlock()
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should stop at the deadline and return a partial result", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			result, err := cl.SecureFile().DeleteBatchContext(ctx, []string{"app/sdb/a.txt", "app/sdb/b.txt", "app/sdb/c.txt"}, 1)
+			So(err, ShouldEqual, context.DeadlineExceeded)
+			So(time.Since(start), ShouldBeLessThan, 2*time.Second)
+			So(result.Succeeded, ShouldBeEmpty)
+			So(result.Failed, ShouldHaveLength, 3)
+			So(result.Failed["app/sdb/a.txt"], ShouldEqual, context.DeadlineExceeded)
+			So(result.Failed["app/sdb/c.txt"], ShouldEqual, context.DeadlineExceeded)
+			So(errorIs(result.Err(), context.DeadlineExceeded), ShouldBeTrue)
+			lock.Lock()
+			defer lock.Unlock()
+			So(requests, ShouldEqual, 1)
+		})
+	})
+
+	Convey("A batch delete finishing before the deadline", t, func() {
+		server := newFakeSecureFileServer(map[string]string{"app/sdb/a.txt": "hello"})
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		Convey("Should not return an error", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			result, err := cl.SecureFile().DeleteBatchContext(ctx, []string{"app/sdb/a.txt"}, 2)
+			So(err, ShouldBeNil)
+			So(result.Succeeded, ShouldResemble, []string{"app/sdb/a.txt"})
+		})
+	})
+}
+
+func TestDeleteBatchWithOptions(t *testing.T) {
+	Convey("A batch delete with a missing file", t, func() {
+		server := newFakeSecureFileServer(map[string]string{
+			"app/sdb/b.txt": "hello",
+			"app/sdb/c.txt": "world",
+		})
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		paths := []string{"app/sdb/missing.txt", "app/sdb/b.txt", "app/sdb/c.txt"}
+		Convey("Should stop at the first error when asked to", func() {
+			result, err := cl.SecureFile().DeleteBatchWithOptions(context.Background(), paths, BatchOptions{Concurrency: 1, StopOnFirstError: true})
+			So(err, ShouldEqual, ErrorSecureFileNotFound)
+			So(result.Succeeded, ShouldBeEmpty)
+			So(result.Failed, ShouldHaveLength, 3)
+			So(result.Failed["app/sdb/c.txt"], ShouldEqual, context.Canceled)
+			So(server.files, ShouldHaveLength, 2)
+		})
+		Convey("Should attempt every file by default", func() {
+			result, err := cl.SecureFile().DeleteBatchWithOptions(context.Background(), paths, BatchOptions{Concurrency: 1})
+			So(err, ShouldBeNil)
+			So(result.Succeeded, ShouldHaveLength, 2)
+			So(result.Failed, ShouldHaveLength, 1)
+			So(server.files, ShouldBeEmpty)
+		})
+	})
+}
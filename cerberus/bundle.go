@@ -0,0 +1,166 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Nike-Inc/cerberus-go-client/api"
+)
+
+// SDBBundleVersion is the version of the bundles written by ExportSDB. ImportSDB refuses other versions
+const SDBBundleVersion = 1
+
+// sdbBundle is the JSON document written by ExportSDB. Paths are relative to the SDB, so a bundle can be
+// imported into another SDB
+type sdbBundle struct {
+	Version int    `json:"version"`
+	SDBPath string `json:"sdb_path"`
+	// Secrets maps the path of each secret to its data
+	Secrets     map[string]map[string]interface{} `json:"secrets"`
+	SecureFiles []bundleSecureFile                `json:"secure_files"`
+}
+
+// bundleSecureFile is a secure file of a bundle. Content is encoded as base64
+type bundleSecureFile struct {
+	Path        string    `json:"path"`
+	Name        string    `json:"name"`
+	Size        int       `json:"size_in_bytes"`
+	LastUpdated time.Time `json:"last_updated_ts"`
+	Content     []byte    `json:"content"`
+}
+
+// ExportSDB writes all secrets and secure files of the SDB at sdbPath, such as "app/my-sdb", to w as a
+// single versioned JSON document which ImportSDB can restore, into the same SDB or another one. Secrets
+// are stored by their path relative to the SDB and secure files with their base64 encoded content and
+// metadata. The whole bundle is held in memory. Secrets or secure files the token is not allowed to read
+// fail the export, so a bundle is never silently incomplete
+func (c *Client) ExportSDB(sdbPath string, w io.Writer) error {
+	ctx := context.Background()
+	root := strings.Trim(sdbPath, "/")
+	var bundle = sdbBundle{
+		Version:     SDBBundleVersion,
+		SDBPath:     root,
+		Secrets:     map[string]map[string]interface{}{},
+		SecureFiles: []bundleSecureFile{},
+	}
+	var seen = map[string]bool{}
+	if err := c.listSecretsRecursive(ctx, root, seen); err != nil {
+		return fmt.Errorf("Error while exporting SDB %s: %v", root, err)
+	}
+	for p := range seen {
+		rel, err := relativeSecurePath(root, p)
+		if err != nil {
+			return fmt.Errorf("Error while exporting SDB %s: %v", root, err)
+		}
+		data, err := c.readSecretData(ctx, p)
+		if err != nil {
+			return fmt.Errorf("Error while exporting secret %s: %v", p, err)
+		}
+		// The secret may have been deleted since it was listed
+		if data != nil {
+			bundle.Secrets[rel] = data
+		}
+	}
+	err := c.SecureFile().iterate(ctx, absoluteSecurePath(root), func(summary api.SecureFileSummary) error {
+		rel, err := relativeSecurePath(root, summary.Path)
+		if err != nil {
+			return err
+		}
+		var content bytes.Buffer
+		if err := c.SecureFile().Get(absoluteSecurePath(summary.Path), &content); err != nil {
+			return fmt.Errorf("Error while exporting secure file %s: %v", summary.Path, err)
+		}
+		bundle.SecureFiles = append(bundle.SecureFiles, bundleSecureFile{
+			Path:        rel,
+			Name:        summary.Name,
+			Size:        content.Len(),
+			LastUpdated: summary.LastUpdated,
+			Content:     content.Bytes(),
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Error while exporting SDB %s: %v", root, err)
+	}
+	sort.Slice(bundle.SecureFiles, func(i, j int) bool {
+		return bundle.SecureFiles[i].Path < bundle.SecureFiles[j].Path
+	})
+	if err := json.NewEncoder(w).Encode(bundle); err != nil {
+		return fmt.Errorf("Error while writing bundle of SDB %s: %v", root, err)
+	}
+	return nil
+}
+
+// ImportSDB restores a bundle written by ExportSDB into the SDB at sdbPath, which must already exist.
+// Secrets and secure files of the bundle overwrite the ones with the same path, and the other content of
+// the SDB is left as is. The import stops at the first failure, so the SDB can be partially restored
+func (c *Client) ImportSDB(sdbPath string, r io.Reader) error {
+	root := strings.Trim(sdbPath, "/")
+	var bundle sdbBundle
+	if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+		return fmt.Errorf("Error while reading bundle: %v", err)
+	}
+	if bundle.Version != SDBBundleVersion {
+		return fmt.Errorf("Error while reading bundle: unsupported version %d, expected %d", bundle.Version, SDBBundleVersion)
+	}
+	var secretPaths = make([]string, 0, len(bundle.Secrets))
+	for rel := range bundle.Secrets {
+		secretPaths = append(secretPaths, rel)
+	}
+	sort.Strings(secretPaths)
+	// sdbPath is the full path of the SDB, so secrets are not written under the SDB of a client from WithSDB
+	secrets := &Secret{c: c}
+	for _, rel := range secretPaths {
+		if err := validateBundlePath(rel); err != nil {
+			return err
+		}
+		if _, err := secrets.Write(path.Join(root, rel), bundle.Secrets[rel]); err != nil {
+			return fmt.Errorf("Error while importing secret %s: %v", rel, err)
+		}
+	}
+	for _, f := range bundle.SecureFiles {
+		if err := validateBundlePath(f.Path); err != nil {
+			return err
+		}
+		name := f.Name
+		if name == "" {
+			name = path.Base(f.Path)
+		}
+		if err := c.SecureFile().Put(absoluteSecurePath(path.Join(root, f.Path)), name, bytes.NewReader(f.Content)); err != nil {
+			return fmt.Errorf("Error while importing secure file %s: %v", f.Path, err)
+		}
+	}
+	return nil
+}
+
+// validateBundlePath refuses bundle paths pointing outside of the SDB they are imported into
+func validateBundlePath(p string) error {
+	rel := path.Clean(p)
+	if rel == "." || rel == ".." || path.IsAbs(rel) || strings.HasPrefix(rel, "../") {
+		return fmt.Errorf("Error while reading bundle: invalid path %q", p)
+	}
+	return nil
+}
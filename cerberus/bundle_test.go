@@ -0,0 +1,168 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeSDBServer serves in memory secrets next to the secure files of a fakeSecureFileServer
+type fakeSDBServer struct {
+	lock    sync.Mutex
+	secrets map[string]map[string]interface{}
+	files   *fakeSecureFileServer
+}
+
+func (f *fakeSDBServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, secretBasePath+"/") {
+		f.files.ServeHTTP(w, r)
+		return
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	p := strings.Trim(strings.TrimPrefix(r.URL.Path, secretBasePath), "/")
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.URL.Query().Get("list") == "true":
+		var keys = map[string]bool{}
+		for s := range f.secrets {
+			if !strings.HasPrefix(s, p+"/") {
+				continue
+			}
+			rest := strings.TrimPrefix(s, p+"/")
+			if i := strings.Index(rest, "/"); i >= 0 {
+				keys[rest[:i+1]] = true
+			} else {
+				keys[rest] = true
+			}
+		}
+		if len(keys) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var list = []string{}
+		for k := range keys {
+			list = append(list, k)
+		}
+		sort.Strings(list)
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"keys": list}})
+	case r.Method == http.MethodGet:
+		data, ok := f.secrets[p]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	case r.Method == http.MethodPut:
+		var data map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&data)
+		f.secrets[p] = data
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestExportImportSDB(t *testing.T) {
+	Convey("An SDB with secrets and secure files", t, func() {
+		fake := &fakeSDBServer{
+			secrets: map[string]map[string]interface{}{
+				"app/src/db":         {"password": "hunter2", "user": "admin"},
+				"app/src/nested/api": {"key": "abc"},
+				"app/other/db":       {"password": "other"},
+			},
+			files: newFakeSecureFileServer(map[string]string{
+				"app/src/certs/cert.pem": "a certificate",
+				"app/src/config.json":    `{"a": 1}`,
+				"app/other/config.json":  "other",
+			}),
+		}
+		ts := httptest.NewServer(fake)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should export it to a bundle", func() {
+			var buf bytes.Buffer
+			So(cl.ExportSDB("app/src/", &buf), ShouldBeNil)
+			var bundle sdbBundle
+			So(json.Unmarshal(buf.Bytes(), &bundle), ShouldBeNil)
+			So(bundle.Version, ShouldEqual, SDBBundleVersion)
+			So(bundle.SDBPath, ShouldEqual, "app/src")
+			So(bundle.Secrets, ShouldResemble, map[string]map[string]interface{}{
+				"db":         {"password": "hunter2", "user": "admin"},
+				"nested/api": {"key": "abc"},
+			})
+			So(bundle.SecureFiles, ShouldHaveLength, 2)
+			So(bundle.SecureFiles[0].Path, ShouldEqual, "certs/cert.pem")
+			So(bundle.SecureFiles[0].Name, ShouldEqual, "cert.pem")
+			So(string(bundle.SecureFiles[0].Content), ShouldEqual, "a certificate")
+			So(bundle.SecureFiles[1].Path, ShouldEqual, "config.json")
+			So(buf.String(), ShouldContainSubstring, `"content":"YSBjZXJ0aWZpY2F0ZQ=="`)
+
+			Convey("Which can be imported into another SDB", func() {
+				So(cl.ImportSDB("app/dst", &buf), ShouldBeNil)
+				So(fake.secrets["app/dst/db"], ShouldResemble, map[string]interface{}{"password": "hunter2", "user": "admin"})
+				So(fake.secrets["app/dst/nested/api"], ShouldResemble, map[string]interface{}{"key": "abc"})
+				So(string(fake.files.files["app/dst/certs/cert.pem"]), ShouldEqual, "a certificate")
+				So(string(fake.files.files["app/dst/config.json"]), ShouldEqual, `{"a": 1}`)
+				So(fake.secrets["app/other/db"], ShouldResemble, map[string]interface{}{"password": "other"})
+			})
+			Convey("Which can be imported with a client scoped to an SDB", func() {
+				So(cl.WithSDB("app/src").ImportSDB("app/dst", &buf), ShouldBeNil)
+				So(fake.secrets["app/dst/db"], ShouldResemble, map[string]interface{}{"password": "hunter2", "user": "admin"})
+				So(fake.secrets, ShouldNotContainKey, "app/src/app/dst/db")
+				So(string(fake.files.files["app/dst/config.json"]), ShouldEqual, `{"a": 1}`)
+			})
+		})
+		Convey("Should refuse a bundle of another version", func() {
+			err := cl.ImportSDB("app/dst", strings.NewReader(`{"version": 2, "secrets": {"db": {"a": "b"}}}`))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "unsupported version 2")
+			So(fake.secrets, ShouldNotContainKey, "app/dst/db")
+		})
+		Convey("Should refuse paths outside of the SDB", func() {
+			err := cl.ImportSDB("app/dst", strings.NewReader(`{"version": 1, "secrets": {"../other/db": {"a": "b"}}}`))
+			So(err, ShouldNotBeNil)
+			So(fake.secrets["app/other/db"], ShouldResemble, map[string]interface{}{"password": "other"})
+		})
+		Convey("Should refuse a document which is not a bundle", func() {
+			So(cl.ImportSDB("app/dst", strings.NewReader("not json")), ShouldNotBeNil)
+		})
+	})
+
+	Convey("An SDB which cannot be listed", t, WithTestServer(http.StatusInternalServerError, path.Join(secretBasePath, "app/src")+"/", http.MethodGet, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should fail the export without writing anything", func() {
+			var buf bytes.Buffer
+			So(cl.ExportSDB("app/src", &buf), ShouldNotBeNil)
+			So(buf.Len(), ShouldEqual, 0)
+		})
+	}))
+}
@@ -44,7 +44,7 @@ func (r *Category) List() ([]*api.Category, error) {
 		return nil, fmt.Errorf("Error while trying to GET categories. Got HTTP status code %d", resp.StatusCode)
 	}
 	var categoryList = []*api.Category{}
-	err = parseResponse(resp.Body, &categoryList)
+	err = r.c.decodeResponse(resp.Body, &categoryList)
 	if err != nil {
 		return nil, err
 	}
@@ -17,13 +17,19 @@ limitations under the License.
 package cerberus
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Nike-Inc/cerberus-go-client/api"
 	"github.com/Nike-Inc/cerberus-go-client/auth"
@@ -36,12 +42,96 @@ type Client struct {
 	CerberusURL    *url.URL
 	vaultClient    *vault.Client
 	httpClient     *http.Client
+	// idempotencyKeys controls whether uploads send an Idempotency-Key header
+	idempotencyKeys bool
+	// createDirs controls whether downloads to a local file create missing directories
+	createDirs bool
+	logger     Logger
+	// downloadDir is the directory used for downloads when no local path is given
+	downloadDir string
+	// downloadFileMode is the mode of the local files secure files are downloaded to
+	downloadFileMode os.FileMode
+	// bulkDelete allows deleting all secure files under a path
+	bulkDelete bool
+	// configureDecoder is applied to every JSON decoder used to parse responses
+	configureDecoder func(*json.Decoder)
+	// maxRetries is the number of times a request is sent again after a retryable status code
+	maxRetries           int
+	retryableStatusCodes map[int]bool
+	retryWait            time.Duration
+	retryPredicate       RetryPredicate
+	// limitParam and offsetParam are the names of the pagination query parameters
+	limitParam  string
+	offsetParam string
+	// maxDownloadBytes is the maximum size of a secure file download, 0 meaning no limit
+	maxDownloadBytes int64
+	progress         ProgressFunc
+	transferMetrics  TransferMetricsFunc
+	// maxResponseBytes is the maximum size of a JSON response, 0 meaning no limit
+	maxResponseBytes int64
+	// curlLogging logs every request as a curl command
+	curlLogging bool
+	// securePathPrefix is prepended to the paths given to the SecureFile client
+	securePathPrefix string
+	// sdbPath is prepended to the paths given to the Secret client
+	sdbPath string
+	// verifyAfterUpload downloads uploaded secure files to check their content
+	verifyAfterUpload bool
+	// treatMissingAsEmpty makes listing a missing folder return no files instead of an error
+	treatMissingAsEmpty bool
+	// dialTimeout is the maximum time spent establishing a connection
+	dialTimeout time.Duration
+	// compressUploads makes uploads gzip compress their body
+	compressUploads bool
+	// maxUploadBytes is the maximum size of an uploaded secure file, or 0 for no limit
+	maxUploadBytes int64
+	// transport sends the requests. Clients share it unless set with WithSharedTransport
+	transport *http.Transport
+	http2     HTTP2Mode
+	// validateUTF8 rejects secret writes with strings which are not valid UTF-8
+	validateUTF8 bool
+	// strictEnvValues rejects secret values which are not strings in Secret.ReadAsEnv
+	strictEnvValues bool
+	// sdbIndex caches the SDBs for sdbIndexTTL. It is shared with the clients scoped with WithSDB
+	sdbIndex    *sdbIndex
+	sdbIndexTTL time.Duration
+	// disableKeepAlives closes connections after each request
+	disableKeepAlives bool
+	// normalizeLineEndings makes GetText convert line endings to \n
+	normalizeLineEndings bool
+	// refreshOnUnauthorized refreshes the token and retries requests failing with a 401
+	refreshOnUnauthorized bool
+	// jsonAccept and downloadAccept are the Accept headers sent to JSON endpoints and on downloads
+	jsonAccept     string
+	downloadAccept string
+	// followRedirects makes the client follow redirects instead of returning a RedirectError
+	followRedirects bool
+	// correlationIDs makes every request carry a generated correlation ID in correlationIDHeader
+	correlationIDs      bool
+	correlationIDHeader string
+	// host overrides the Host header of requests, which are still sent to the address of the URL
+	host string
+	// authLock guards Authentication, whose implementations are not safe for concurrent use.
+	// It is a pointer so clients returned by WithSDB share it with the client they come from
+	authLock *sync.RWMutex
+	// autoRefresh is the background token refresh started with StartAutoRefresh
+	autoRefresh *autoRefresh
+	// autoRefreshErrorHandler is called with the errors of background token refreshes
+	autoRefreshErrorHandler func(error)
+	// tokenNearExpiry is called when a request is sent with a token expiring within tokenNearExpiryThreshold.
+	// tokenExpiry is shared with the clients scoped with WithSDB
+	tokenNearExpiry          TokenNearExpiryFunc
+	tokenNearExpiryThreshold time.Duration
+	tokenExpiry              *tokenExpiry
+	// clock tells the time and waits for all time dependent behaviors
+	clock Clock
 }
 
 // NewClient creates a new Client given an Authentication method.
 // This method expects a file (which can be nil) as a source for a OTP used for MFA against Cerberus (if needed).
 // If it is a file, it expect the token and a new line.
-func NewClient(authMethod auth.Auth, otpFile *os.File) (*Client, error) {
+// Any number of ClientOption can be passed to customize the behavior of the client
+func NewClient(authMethod auth.Auth, otpFile *os.File, opts ...ClientOption) (*Client, error) {
 	// Get the token and authenticate
 	token, loginErr := authMethod.GetToken(otpFile)
 	if loginErr != nil {
@@ -56,12 +146,53 @@ func NewClient(authMethod auth.Auth, otpFile *os.File) (*Client, error) {
 	}
 	// Used the returned token to set it as the token for this client as well
 	vclient.SetToken(token)
-	return &Client{
+	c := &Client{
 		Authentication: authMethod,
 		CerberusURL:    authMethod.GetURL(),
 		vaultClient:    vclient,
-		httpClient:     &http.Client{},
-	}, nil
+		authLock:       &sync.RWMutex{},
+		autoRefresh:    &autoRefresh{},
+		sdbIndex:       &sdbIndex{},
+		tokenExpiry:    &tokenExpiry{},
+
+		dialTimeout:          DefaultDialTimeout,
+		retryableStatusCodes: statusCodeSet(DefaultRetryableStatusCodes),
+		retryWait:            defaultRetryWait,
+		limitParam:           DefaultLimitParam,
+		offsetParam:          DefaultOffsetParam,
+		jsonAccept:           DefaultJSONAccept,
+		downloadAccept:       DefaultDownloadAccept,
+		correlationIDHeader:  DefaultCorrelationIDHeader,
+		sdbIndexTTL:          DefaultSDBIndexTTL,
+		clock:                realClock{},
+		downloadFileMode:     DefaultDownloadFileMode,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.transport == nil {
+		c.transport = sharedTransport(transportSettings{
+			dialTimeout:       c.dialTimeout,
+			disableKeepAlives: c.disableKeepAlives,
+			http2:             c.http2,
+		})
+	}
+	c.httpClient = &http.Client{
+		Transport:     c.transport,
+		CheckRedirect: c.checkRedirect,
+	}
+	return c, nil
+}
+
+// WithSDB returns a client scoped to the SDB at the given path (such as "app/my-sdb"): the paths given to
+// its Secret and SecureFile clients are relative to the SDB. It replaces any prefix set with
+// WithSecurePathPrefix. The returned client shares the HTTP client, the authentication and the cache of SDBs
+// (see RefreshSDBIndex) of c
+func (c *Client) WithSDB(sdbPath string) *Client {
+	scoped := *c
+	scoped.sdbPath = sdbPath
+	scoped.securePathPrefix = sdbPath
+	return &scoped
 }
 
 // SDB returns the SDB client
@@ -74,7 +205,8 @@ func (c *Client) SDB() *SDB {
 // Secret returns the Secret client
 func (c *Client) Secret() *Secret {
 	return &Secret{
-		v: c.vaultClient.Logical(),
+		c:       c,
+		sdbPath: c.sdbPath,
 	}
 }
 
@@ -110,52 +242,95 @@ func (c *Client) SecureFile() *SecureFile {
 // This likely means that there is some sort of server error that is occurring
 var ErrorBodyNotReturned = fmt.Errorf("No error body returned from server")
 
-// DoRequestWithBody executes a request with provided body
+// DoRequestWithBody executes a request with provided body. If the body implements io.Seeker, it is
+// rewound to be sent again when the request is retried and it is not closed by the client
 func (c *Client) DoRequestWithBody(method, path string, params map[string]string, contentType string, body io.Reader) (*http.Response, error) {
+	return c.doRequest(context.Background(), method, path, params, nil, contentType, body)
+}
+
+// buildURL returns the URL of a request to the given path with the given query parameters.
+// Each segment of the path is escaped, so paths can contain spaces and other special characters
+func (c *Client) buildURL(path string, params map[string]string) *url.URL {
 	// Get a copy of the base URL and add the path
 	var baseURL = *c.CerberusURL
 	baseURL.Path = path
+	baseURL.RawPath = escapePath(path)
 	p := baseURL.Query()
 	// Add the params in to the request
 	for k, v := range params {
 		p.Add(k, v)
 	}
 	baseURL.RawQuery = p.Encode()
+	return &baseURL
+}
+
+// escapePath escapes each segment of a slash separated path
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// doRequest executes a request with provided body. Any headers given are added on top of the
+// authentication headers for this request only
+func (c *Client) doRequest(ctx context.Context, method, path string, params map[string]string, extraHeaders http.Header, contentType string, body io.Reader) (*http.Response, error) {
 	var req *http.Request
 	var err error
 
-	req, err = http.NewRequest(method, baseURL.String(), body)
+	req, err = http.NewRequest(method, c.buildURL(path, params).String(), body)
 	if err != nil {
 		return nil, err
 	}
-	headers, headerErr := c.Authentication.GetHeaders()
+	req = req.WithContext(ctx)
+	if c.host != "" {
+		req.Host = c.host
+	}
+	makeRewindable(req, body)
+	headers, headerErr := c.authHeaders()
 	if headerErr != nil {
 		return nil, headerErr
 	}
 	req.Header = headers
+	// Responses are JSON, except for downloads which set their own Accept header
+	req.Header.Set("Accept", c.jsonAccept)
+	for k, v := range extraHeaders {
+		req.Header[k] = append([]string(nil), v...)
+	}
+
+	if err := c.setCorrelationID(req); err != nil {
+		return nil, err
+	}
 
 	// Add content type if present
 	if contentType != "" {
 		req.Header.Set("Content-Type", contentType)
 	}
 
-	resp, respErr := c.httpClient.Do(req)
+	c.checkTokenExpiry(ctx, req.Header.Get("X-Vault-Token"))
+	c.logRequest(req, body)
+	c.logCurl(req, body)
+
+	resp, respErr := c.send(req)
+	if respErr == nil {
+		resp, respErr = c.retryUnauthorized(req, resp)
+	}
 	if respErr != nil {
 		// We may get an actual response for redirect error
 		return resp, respErr
 	}
+	c.logResponse(req, resp)
+	if err := c.redirectError(resp); err != nil {
+		return nil, err
+	}
 	// Cerberus uses a refresh token header. If that header is sent with a value of "true,"
 	// refresh the token before returning
-	if resp.Header.Get("X-Refresh-Token") == "true" {
-		if err := c.Authentication.Refresh(); err != nil {
-			// logging here
-		}
-		tok, err := c.Authentication.GetToken(nil)
-		if err != nil {
+	if resp.Header.Get("X-Refresh-Token") == "true" && refreshAllowed(ctx) {
+		// A failed refresh still leaves a token, only failing to get one is an error
+		if tok, err := c.refreshToken(req.Header.Get("X-Vault-Token")); err != nil && tok == "" {
 			return nil, err
 		}
-		// Used the returned token to set it as the token for this client as well
-		c.vaultClient.SetToken(tok)
 	}
 	return resp, nil
 }
@@ -163,26 +338,104 @@ func (c *Client) DoRequestWithBody(method, path string, params map[string]string
 // DoRequest is used to perform an HTTP request with the given method and path
 // This method is what is called by other parts of the client and is exposed for advanced usage
 func (c *Client) DoRequest(method, path string, params map[string]string, data interface{}) (*http.Response, error) {
-	var body io.ReadWriter
+	return c.DoRequestWithJSON(method, path, params, data)
+}
+
+// DoRequestWithJSON performs an HTTP request with v marshaled as a JSON body and the Content-Type
+// set to application/json. If v is nil, the request is sent without a body
+func (c *Client) DoRequestWithJSON(method, path string, query map[string]string, v interface{}) (*http.Response, error) {
+	return c.doJSONRequest(context.Background(), method, path, query, v)
+}
+
+// doJSONRequest is DoRequestWithJSON with a context
+func (c *Client) doJSONRequest(ctx context.Context, method, path string, query map[string]string, v interface{}) (*http.Response, error) {
+	var body io.Reader
 	var contentType string
 
-	if data != nil {
-		body = &bytes.Buffer{}
-		contentType = "application/json"
-		err := json.NewEncoder(body).Encode(data)
-		if err != nil {
+	if v != nil {
+		buf := &bytes.Buffer{}
+		if err := json.NewEncoder(buf).Encode(v); err != nil {
 			return nil, err
 		}
+		body = buf
+		contentType = "application/json"
 	}
 
-	return c.DoRequestWithBody(method, path, params, contentType, body)
+	return c.doRequest(ctx, method, path, query, nil, contentType, body)
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	// Set version 4 and the RFC 4122 variant
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
 }
 
 // parseResponse marshals the given body into the given interface. It should be used just like
-// json.Marshal in that you pass a pointer to the function.
+// json.Marshal in that you pass a pointer to the function. It uses the default decoder settings.
 func parseResponse(r io.Reader, parseTo interface{}) error {
+	return decodeJSON(r, parseTo, nil)
+}
+
+// decodeResponse is parseResponse using the decoder configuration of the client (see WithJSONDecoder)
+// and the response size limit (see WithMaxResponseBytes)
+func (c *Client) decodeResponse(r io.Reader, parseTo interface{}) error {
+	if c.maxResponseBytes > 0 {
+		r = &limitedReader{r: r, remaining: c.maxResponseBytes, err: ErrorResponseTooLarge}
+	}
+	return decodeJSON(r, parseTo, c.configureDecoder)
+}
+
+// maxBodySnippet is the maximum number of bytes of a body included in a NonJSONResponseError
+const maxBodySnippet = 200
+
+// NonJSONResponseError is returned when a response body is not valid JSON, such as an HTML error or login page
+// served by a proxy in front of Cerberus. Snippet is the start of the body. It is empty for a body which looks
+// like malformed JSON, as that may be a secret whose content must not end up in logs
+type NonJSONResponseError struct {
+	Snippet string
+	Err     error
+}
+
+func (e *NonJSONResponseError) Error() string {
+	if e.Snippet == "" {
+		return fmt.Sprintf("Response from Cerberus is not valid JSON (%v)", e.Err)
+	}
+	return fmt.Sprintf("Response from Cerberus is not valid JSON (%v), body starts with: %q", e.Err, e.Snippet)
+}
+
+// decodeJSON decodes r into parseTo, calling configure on the decoder first if it is not nil. Syntax errors
+// are returned as a NonJSONResponseError. The content type is not checked, as JSON is not always labeled as such
+func decodeJSON(r io.Reader, parseTo interface{}, configure func(*json.Decoder)) error {
+	br := bufio.NewReaderSize(r, maxBodySnippet)
+	// Keep the start of the body to describe it if it fails to decode
+	snippet, _ := br.Peek(maxBodySnippet)
+	snippet = append([]byte(nil), snippet...)
+	decoder := json.NewDecoder(br)
+	if configure != nil {
+		configure(decoder)
+	}
 	// Decode the body into the provided interface
-	return json.NewDecoder(r).Decode(parseTo)
+	err := decoder.Decode(parseTo)
+	if _, ok := err.(*json.SyntaxError); ok {
+		return &NonJSONResponseError{Snippet: bodySnippet(snippet), Err: err}
+	}
+	return err
+}
+
+// bodySnippet returns the start of a body for a NonJSONResponseError, or an empty string if the body starts
+// like a JSON object or array
+func bodySnippet(snippet []byte) string {
+	trimmed := strings.TrimSpace(string(snippet))
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		return ""
+	}
+	return trimmed
 }
 
 // handleAPIError is a helper for parsing an error response body from the API.
@@ -18,12 +18,15 @@ package cerberus
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/Nike-Inc/cerberus-go-client/api"
@@ -36,6 +39,7 @@ type MockAuth struct {
 	token       string
 	getTokenErr bool
 	refreshErr  bool
+	refreshes   int
 }
 
 const refreshedToken = "a refreshed token"
@@ -66,6 +70,7 @@ func (m *MockAuth) IsAuthenticated() bool {
 }
 
 func (m *MockAuth) Refresh() error {
+	m.refreshes++
 	if !m.refreshErr {
 		m.token = refreshedToken
 		return nil
@@ -129,6 +134,86 @@ func TestSubclients(t *testing.T) {
 	})
 }
 
+func TestWithSDB(t *testing.T) {
+	Convey("A client scoped to an SDB", t, func() {
+		var paths []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data": {"password": "hunter2"}}`))
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		c, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithSecurePathPrefix("app/other-sdb"))
+		scoped := c.WithSDB("app/my-sdb")
+		So(scoped, ShouldNotBeNil)
+		Convey("Should prefix secret paths", func() {
+			secret, err := scoped.Secret().Read("db")
+			So(err, ShouldBeNil)
+			So(secret.Data["password"], ShouldEqual, "hunter2")
+			So(paths, ShouldResemble, []string{"/v1/secret/app/my-sdb/db"})
+		})
+		Convey("Should prefix secure file paths", func() {
+			So(scoped.SecureFile().URL("hello.txt"), ShouldEqual, ts.URL+"/v1/secure-file/app/my-sdb/hello.txt")
+		})
+		Convey("Should share the HTTP client and authentication", func() {
+			So(scoped.httpClient, ShouldEqual, c.httpClient)
+			So(scoped.vaultClient, ShouldEqual, c.vaultClient)
+			So(scoped.Authentication, ShouldEqual, c.Authentication)
+		})
+		Convey("Should leave the original client unchanged", func() {
+			c.Secret().Read("app/my-sdb/db")
+			So(paths, ShouldResemble, []string{"/v1/secret/app/my-sdb/db"})
+			So(c.SecureFile().URL("hello.txt"), ShouldEqual, ts.URL+"/v1/secure-file/app/other-sdb/hello.txt")
+		})
+	})
+}
+
+func TestBuildURL(t *testing.T) {
+	Convey("A client", t, func() {
+		c, _ := NewClient(GenerateMockAuth("http://example.com", "a-cool-token", false, false), nil)
+		So(c, ShouldNotBeNil)
+		Convey("Should escape spaces", func() {
+			So(c.buildURL("/v1/secure-file/app/sdb/my file.txt", nil).String(), ShouldEqual, "http://example.com/v1/secure-file/app/sdb/my%20file.txt")
+		})
+		Convey("Should escape characters reserved in a path segment", func() {
+			So(c.buildURL("/v1/secure-file/app/sdb/a;b,c?d.txt", nil).String(), ShouldEqual, "http://example.com/v1/secure-file/app/sdb/a%3Bb%2Cc%3Fd.txt")
+		})
+		Convey("Should keep plus signs", func() {
+			So(c.buildURL("/v1/secure-file/app/sdb/a+b.txt", nil).String(), ShouldEqual, "http://example.com/v1/secure-file/app/sdb/a+b.txt")
+		})
+		Convey("Should escape unicode", func() {
+			So(c.buildURL("/v1/secure-file/app/sdb/café.txt", nil).String(), ShouldEqual, "http://example.com/v1/secure-file/app/sdb/caf%C3%A9.txt")
+		})
+		Convey("Should keep the query parameters", func() {
+			So(c.buildURL("/v1/secure-files/app/my sdb/", map[string]string{"list": "true"}).String(), ShouldEqual, "http://example.com/v1/secure-files/app/my%20sdb/?list=true")
+		})
+	})
+}
+
+func TestSpecialCharacterPaths(t *testing.T) {
+	for _, name := range []string{"my file.txt", "a+b.txt", "café ☕.txt", "50%.txt"} {
+		Convey("A secure file named "+name, t, func() {
+			server := newFakeSecureFileServer(map[string]string{"app/sdb/" + name: "hello"})
+			ts := httptest.NewServer(server)
+			Reset(func() {
+				ts.Close()
+			})
+			c, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			Convey("Should be downloaded", func() {
+				var buf bytes.Buffer
+				So(c.SecureFile().Get("app/sdb/"+name, &buf), ShouldBeNil)
+				So(buf.String(), ShouldEqual, "hello")
+			})
+			Convey("Should be uploaded", func() {
+				So(c.SecureFile().Put("app/sdb/new "+name, name, strings.NewReader("new")), ShouldBeNil)
+				So(string(server.files["app/sdb/new "+name]), ShouldEqual, "new")
+			})
+		})
+	}
+}
+
 func TestParseResponse(t *testing.T) {
 	Convey("Valid JSON object", t, func() {
 		buf := bytes.NewBuffer([]byte(`{
@@ -159,6 +244,61 @@ func TestParseResponse(t *testing.T) {
 	})
 }
 
+func TestDecodeResponse(t *testing.T) {
+	Convey("A client configured to use json.Number", t, func() {
+		cl, _ := NewClient(GenerateMockAuth("http://example.com", "a-cool-token", false, false), nil, WithJSONDecoder(func(d *json.Decoder) {
+			d.UseNumber()
+		}))
+		So(cl, ShouldNotBeNil)
+		Convey("Should keep the precision of large integers", func() {
+			var parsed map[string]interface{}
+			err := cl.decodeResponse(bytes.NewBufferString(`{"size": 9007199254740993}`), &parsed)
+			So(err, ShouldBeNil)
+			So(parsed["size"], ShouldEqual, json.Number("9007199254740993"))
+		})
+	})
+	Convey("A client with the default decoder", t, func() {
+		cl, _ := NewClient(GenerateMockAuth("http://example.com", "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should decode numbers as float64", func() {
+			var parsed map[string]interface{}
+			err := cl.decodeResponse(bytes.NewBufferString(`{"size": 1}`), &parsed)
+			So(err, ShouldBeNil)
+			So(parsed["size"], ShouldEqual, float64(1))
+		})
+	})
+	Convey("An HTML page", t, func() {
+		var parsed map[string]interface{}
+		page := "\n<html><body>Please log in to the corporate portal" + strings.Repeat(".", 300) + "</body></html>"
+		err := parseResponse(bytes.NewBufferString(page), &parsed)
+		Convey("Should return a NonJSONResponseError with the start of the body", func() {
+			So(err, ShouldHaveSameTypeAs, &NonJSONResponseError{})
+			snippet := err.(*NonJSONResponseError).Snippet
+			So(snippet, ShouldStartWith, "<html><body>Please log in")
+			So(len(snippet), ShouldBeLessThanOrEqualTo, maxBodySnippet)
+			So(err.Error(), ShouldContainSubstring, "not valid JSON")
+		})
+	})
+	Convey("A malformed JSON body", t, func() {
+		var parsed map[string]interface{}
+		err := parseResponse(bytes.NewBufferString(` {"data": {"password": "hunter2",}}`), &parsed)
+		Convey("Should not include the start of the body in the error", func() {
+			So(err, ShouldHaveSameTypeAs, &NonJSONResponseError{})
+			So(err.(*NonJSONResponseError).Snippet, ShouldBeEmpty)
+			So(err.Error(), ShouldNotContainSubstring, "hunter2")
+		})
+	})
+	Convey("A SDB list served as an HTML page", t, WithTestServer(http.StatusOK, "/v2/safe-deposit-box", http.MethodGet, "<html>Not found</html>", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should describe the page in the error", func() {
+			_, err := cl.SDB().List()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "<html>Not found</html>")
+		})
+	}))
+}
+
 func WithServer(returnCode int, shouldRefresh bool, expectedPath, expectedMethod, bodyContains string, expectedParams map[string]string, f func(ts *httptest.Server)) func() {
 	return func() {
 		Convey("http requests should be correct", func(c C) {
@@ -238,8 +378,10 @@ func TestDoRequest(t *testing.T) {
 			resp, err := cl.DoRequest(http.MethodPost, "/v1/books/armaments", map[string]string{}, testData)
 			So(err, ShouldBeNil)
 			So(resp.StatusCode, ShouldEqual, http.StatusOK)
-			Convey("Vault token should be set to the new token", func() {
-				So(cl.vaultClient.Token(), ShouldEqual, refreshedToken)
+			Convey("Secrets should be requested with the new token", func() {
+				r, err := cl.Secret().newRequest(http.MethodGet, "app/sdb/db")
+				So(err, ShouldBeNil)
+				So(r.ClientToken, ShouldEqual, refreshedToken)
 			})
 		})
 	}))
@@ -269,6 +411,31 @@ func TestDoRequest(t *testing.T) {
 	})
 }
 
+func TestDoRequestWithJSON(t *testing.T) {
+	Convey("Valid PUT request with a JSON body", t, WithServer(http.StatusOK, false, "/v1/books/armaments", http.MethodPut, `"weapon":"holy hand grenade of antioch"`, map[string]string{}, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		var testData = map[string]string{
+			"weapon": "holy hand grenade of antioch",
+		}
+		Convey("Should return a valid response", func() {
+			resp, err := cl.DoRequestWithJSON(http.MethodPut, "/v1/books/armaments", map[string]string{}, testData)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		})
+	}))
+
+	Convey("A request with a value that cannot be marshaled", t, func() {
+		cl, _ := NewClient(GenerateMockAuth("http://127.0.0.1:32876", "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return an error", func() {
+			resp, err := cl.DoRequestWithJSON(http.MethodPut, "/v1/blah", map[string]string{}, make(chan int))
+			So(err, ShouldNotBeNil)
+			So(resp, ShouldBeNil)
+		})
+	})
+}
+
 func TestHandleAPIError(t *testing.T) {
 	Convey("Valid error body", t, func() {
 		buf := bytes.NewBuffer([]byte(`{
@@ -320,3 +487,74 @@ func TestHandleAPIError(t *testing.T) {
 		})
 	})
 }
+
+func TestNewUUID(t *testing.T) {
+	Convey("A generated UUID", t, func() {
+		id, err := newUUID()
+		Convey("Should be a valid version 4 UUID", func() {
+			So(err, ShouldBeNil)
+			So(id, ShouldHaveLength, 36)
+			So(id[14], ShouldEqual, '4')
+			So(strings.Count(id, "-"), ShouldEqual, 4)
+		})
+	})
+}
+
+func TestAcceptHeaders(t *testing.T) {
+	Convey("A client", t, func() {
+		var accepts = map[string]string{}
+		var lock sync.Mutex
+		server := newFakeSecureFileServer(map[string]string{"app/sdb/a.txt": "hello"})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			accepts[r.Method+" "+r.URL.Path] = r.Header.Get("Accept")
+			lock.Unlock()
+			server.ServeHTTP(w, r)
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		Convey("Should ask for JSON by default and for bytes on downloads", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			_, err := cl.SecureFile().List("app/sdb")
+			So(err, ShouldBeNil)
+			So(cl.SecureFile().Get("app/sdb/a.txt", ioutil.Discard), ShouldBeNil)
+			So(accepts["GET /v1/secure-files/app/sdb/"], ShouldEqual, "application/json")
+			So(accepts["GET /v1/secure-file/app/sdb/a.txt"], ShouldEqual, "application/octet-stream")
+		})
+		Convey("Should use the configured headers", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil,
+				WithAcceptHeaders("application/vnd.cerberus+json", ""))
+			_, err := cl.SecureFile().List("app/sdb")
+			So(err, ShouldBeNil)
+			So(cl.SecureFile().Get("app/sdb/a.txt", ioutil.Discard), ShouldBeNil)
+			So(accepts["GET /v1/secure-files/app/sdb/"], ShouldEqual, "application/vnd.cerberus+json")
+			So(accepts["GET /v1/secure-file/app/sdb/a.txt"], ShouldEqual, "application/octet-stream")
+		})
+	})
+}
+
+func TestHostHeader(t *testing.T) {
+	Convey("A client", t, func() {
+		var host string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host = r.Host
+			w.Write([]byte("[]"))
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		Convey("Should send the host of the URL by default", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			_, err := cl.SDB().List()
+			So(err, ShouldBeNil)
+			So(host, ShouldEqual, strings.TrimPrefix(ts.URL, "http://"))
+		})
+		Convey("Should send the configured host to the address of the URL", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithHost("cerberus.example.com"))
+			_, err := cl.SDB().List()
+			So(err, ShouldBeNil)
+			So(host, ShouldEqual, "cerberus.example.com")
+		})
+	})
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"time"
+)
+
+// Clock tells the time and waits for durations. The client uses it for everything depending on time, such
+// as the wait between retries, background token refreshes, Watch and the SDB cache, so tests can control
+// it with WithClock
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// After returns a channel receiving the current time once d has elapsed
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock of the time package, used by default
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// sleepContext waits for d and returns true, or returns false as soon as ctx is done
+func (c *Client) sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-c.clock.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeClock is a Clock whose waits return right away, moving its time forward by the waited duration
+type fakeClock struct {
+	lock  sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.now = f.now.Add(d)
+	f.waits = append(f.waits, d)
+	ch := make(chan time.Time, 1)
+	ch <- f.now
+	return ch
+}
+
+// advance moves the time of the clock forward by d
+func (f *fakeClock) advance(d time.Duration) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func TestClock(t *testing.T) {
+	Convey("A client with a fake clock", t, func() {
+		clock := &fakeClock{now: time.Date(2018, 6, 14, 10, 0, 0, 0, time.UTC)}
+		Convey("Should wait between retries with the clock", func() {
+			server := &flakyServer{failures: 2, failCode: http.StatusServiceUnavailable}
+			ts := httptest.NewServer(server)
+			defer ts.Close()
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithMaxRetries(2), WithClock(clock))
+			So(cl, ShouldNotBeNil)
+			resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(clock.waits, ShouldResemble, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond})
+		})
+		Convey("Should expire the SDB cache with the clock", func() {
+			var lock sync.Mutex
+			var lists int
+			ts := sdbListServer(&lists, &lock)
+			defer ts.Close()
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithSDBIndexTTL(time.Minute), WithClock(clock))
+			So(cl, ShouldNotBeNil)
+			_, _, err := cl.SDB().SplitSDBPath("app/web/db")
+			So(err, ShouldBeNil)
+			clock.advance(59 * time.Second)
+			_, _, err = cl.SDB().SplitSDBPath("app/web/db")
+			So(err, ShouldBeNil)
+			So(lists, ShouldEqual, 1)
+			clock.advance(time.Second)
+			_, _, err = cl.SDB().SplitSDBPath("app/web/db")
+			So(err, ShouldBeNil)
+			So(lists, ShouldEqual, 2)
+		})
+	})
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// compressedTypes are the types of content which is already compressed, and are not compressed again
+var compressedTypes = map[string]bool{
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+	"application/zip":              true,
+	"application/x-bzip2":          true,
+	"application/x-xz":             true,
+	"application/x-7z-compressed":  true,
+	"application/x-rar-compressed": true,
+	"application/zstd":             true,
+	"application/pdf":              true,
+}
+
+// compressedExtensions are the extensions of files which are already compressed. The MIME types of
+// some of them are not known on every system
+var compressedExtensions = map[string]bool{
+	".gz": true, ".tgz": true, ".zip": true, ".jar": true, ".bz2": true, ".xz": true, ".7z": true,
+	".rar": true, ".zst": true, ".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true,
+}
+
+// isCompressed returns whether a file is already compressed based on its name or its type
+func isCompressed(filename, contentType string) bool {
+	return compressedExtensions[strings.ToLower(filepath.Ext(filename))] || isCompressedType(contentType)
+}
+
+// isCompressedType returns whether content of the given type is already compressed, like images,
+// audio, video and archives
+func isCompressedType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range []string{"image/", "audio/", "video/"} {
+		// SVG is text
+		if strings.HasPrefix(mediaType, prefix) && mediaType != "image/svg+xml" {
+			return true
+		}
+	}
+	return compressedTypes[mediaType]
+}
+
+// gzipBody returns the gzip compressed body, and whether it is smaller than the original. Compressing small
+// or incompressible bodies can make them larger, in which case they should be sent as is
+func gzipBody(body *bytes.Buffer) (*bytes.Buffer, bool, error) {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	if _, err := w.Write(body.Bytes()); err != nil {
+		return nil, false, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, false, err
+	}
+	return &compressed, compressed.Len() < body.Len(), nil
+}
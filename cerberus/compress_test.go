@@ -0,0 +1,114 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// gunzipServer decompresses gzip encoded requests before passing them to h, like a server supporting them
+func gunzipServer(h http.Handler, encodings *[]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*encodings = append(*encodings, r.Header.Get("Content-Encoding"))
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			r.Body = ioutil.NopCloser(gz)
+			r.ContentLength = -1
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func TestUploadCompression(t *testing.T) {
+	Convey("A server accepting compressed uploads", t, func() {
+		var encodings []string
+		server := newFakeSecureFileServer(map[string]string{})
+		ts := httptest.NewServer(gunzipServer(server, &encodings))
+		Reset(func() {
+			ts.Close()
+		})
+		large := strings.Repeat("log line that compresses well\n", 1000)
+		Convey("Should receive uncompressed uploads by default", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			So(cl.SecureFile().PutString("app/sdb/app.log", "app.log", large), ShouldBeNil)
+			So(encodings, ShouldResemble, []string{""})
+		})
+		Convey("When compression is enabled", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithUploadCompression(true))
+			Convey("Should compress text files", func() {
+				So(cl.SecureFile().PutString("app/sdb/app.log", "app.log", large), ShouldBeNil)
+				So(encodings, ShouldResemble, []string{"gzip"})
+				So(string(server.files["app/sdb/app.log"]), ShouldEqual, large)
+			})
+			Convey("Should not compress files which already are", func() {
+				So(cl.SecureFile().PutString("app/sdb/logs.zip", "logs.zip", large), ShouldBeNil)
+				So(encodings, ShouldResemble, []string{""})
+			})
+		})
+	})
+}
+
+func TestIsCompressedType(t *testing.T) {
+	Convey("Content types", t, func() {
+		So(isCompressedType("application/zip"), ShouldBeTrue)
+		So(isCompressedType("image/png"), ShouldBeTrue)
+		So(isCompressedType("application/gzip; charset=binary"), ShouldBeTrue)
+		So(isCompressedType("image/svg+xml"), ShouldBeFalse)
+		So(isCompressedType("text/plain; charset=utf-8"), ShouldBeFalse)
+		So(isCompressedType("application/octet-stream"), ShouldBeFalse)
+	})
+	Convey("File names", t, func() {
+		So(isCompressed("backup.TGZ", "application/octet-stream"), ShouldBeTrue)
+		So(isCompressed("app.conf", "application/octet-stream"), ShouldBeFalse)
+	})
+}
+
+func TestGzipBody(t *testing.T) {
+	Convey("A compressible body", t, func() {
+		body := bytes.NewBufferString(strings.Repeat("a", 1000))
+		compressed, smaller, err := gzipBody(body)
+		So(err, ShouldBeNil)
+		So(smaller, ShouldBeTrue)
+		Convey("Should decompress to the original", func() {
+			gz, err := gzip.NewReader(compressed)
+			So(err, ShouldBeNil)
+			var out bytes.Buffer
+			_, err = io.Copy(&out, gz)
+			So(err, ShouldBeNil)
+			So(out.String(), ShouldEqual, strings.Repeat("a", 1000))
+		})
+	})
+
+	Convey("A tiny body", t, func() {
+		_, smaller, err := gzipBody(bytes.NewBufferString("a"))
+		So(err, ShouldBeNil)
+		So(smaller, ShouldBeFalse)
+	})
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// DefaultCorrelationIDHeader is the default header correlation IDs are sent in
+const DefaultCorrelationIDHeader = "X-Correlation-ID"
+
+// correlationIDKey is the context key of the correlation ID set with ContextWithCorrelationID
+type correlationIDKey struct{}
+
+// ContextWithCorrelationID returns a context which makes the requests sent with it carry the given
+// correlation ID, such as the one of an incoming request, instead of a generated one. It is sent even
+// if the client was not created with WithCorrelationIDs
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID set with ContextWithCorrelationID, if any
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(correlationIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// setCorrelationID sets the correlation ID header of a request: the ID of its context if there is one,
+// or a new UUID if WithCorrelationIDs is enabled. Resending the same request, such as on retries,
+// keeps the same ID
+func (c *Client) setCorrelationID(req *http.Request) error {
+	id, ok := CorrelationIDFromContext(req.Context())
+	if !ok {
+		if !c.correlationIDs {
+			return nil
+		}
+		var err error
+		if id, err = newUUID(); err != nil {
+			return fmt.Errorf("Error generating correlation ID: %v", err)
+		}
+	}
+	req.Header.Set(c.correlationIDHeader, id)
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCorrelationIDs(t *testing.T) {
+	Convey("A server recording correlation IDs", t, func() {
+		var ids []string
+		var lock sync.Mutex
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			ids = append(ids, r.Header.Get("X-Correlation-ID")+r.Header.Get("X-Request-ID"))
+			lock.Unlock()
+			w.Write([]byte(`{"version": "1.0.0"}`))
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		Convey("Should not send an ID by default", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			_, err := cl.ServerInfo(context.Background())
+			So(err, ShouldBeNil)
+			So(ids, ShouldResemble, []string{""})
+		})
+		Convey("Should send a new ID with each request and log it", func() {
+			logger := &recordingLogger{}
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithCorrelationIDs(""), WithLogger(logger))
+			_, err := cl.ServerInfo(context.Background())
+			So(err, ShouldBeNil)
+			_, err = cl.ServerInfo(context.Background())
+			So(err, ShouldBeNil)
+			So(ids, ShouldHaveLength, 2)
+			So(ids[0], ShouldHaveLength, 36)
+			So(ids[1], ShouldNotEqual, ids[0])
+			So(logger.String(), ShouldContainSubstring, "GET /info request body 0 bytes (correlation ID "+ids[0]+")")
+			So(logger.String(), ShouldContainSubstring, "GET /info response 200 body 20 bytes (correlation ID "+ids[0]+")")
+		})
+		Convey("Should use the configured header", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithCorrelationIDs("X-Request-ID"))
+			_, err := cl.ServerInfo(context.Background())
+			So(err, ShouldBeNil)
+			So(ids[0], ShouldHaveLength, 36)
+		})
+		Convey("Should propagate the ID of the context", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			_, err := cl.ServerInfo(ContextWithCorrelationID(context.Background(), "incoming-request"))
+			So(err, ShouldBeNil)
+			So(ids, ShouldResemble, []string{"incoming-request"})
+		})
+	})
+
+	Convey("A context", t, func() {
+		Convey("Should return its correlation ID", func() {
+			id, ok := CorrelationIDFromContext(ContextWithCorrelationID(context.Background(), "abc"))
+			So(ok, ShouldBeTrue)
+			So(id, ShouldEqual, "abc")
+		})
+		Convey("Should not have one by default", func() {
+			_, ok := CorrelationIDFromContext(context.Background())
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// redactedHeaders are the headers whose values are never included in curl commands
+var redactedHeaders = map[string]bool{
+	"X-Vault-Token": true,
+	"Authorization": true,
+}
+
+// logCurl logs the request as a curl command when enabled with WithCurlLogging
+func (c *Client) logCurl(req *http.Request, body io.Reader) {
+	if !c.curlLogging || c.logger == nil {
+		return
+	}
+	c.logger.Printf("cerberus: %s", formatCurl(req, body))
+}
+
+// formatCurl returns a curl command replaying the request. Authentication headers are redacted.
+// Only buffered bodies can be included; file parts of multipart bodies are replaced by a reference
+// to a local file with the same name
+func formatCurl(req *http.Request, body io.Reader) string {
+	var args = []string{"curl", "-X", req.Method}
+
+	var multipartArgs []string
+	var data string
+	var hasData bool
+	// Compressed bodies are binary
+	if buf, ok := body.(*bytes.Buffer); ok && req.Header.Get("Content-Encoding") == "" {
+		if fields, isMultipart := multipartCurlFields(req.Header.Get("Content-Type"), buf.Bytes()); isMultipart {
+			multipartArgs = fields
+		} else if buf.Len() > 0 {
+			data = buf.String()
+			hasData = true
+		}
+	}
+
+	var names = make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		// curl sets its own multipart boundary
+		if multipartArgs != nil && http.CanonicalHeaderKey(k) == "Content-Type" {
+			continue
+		}
+		for _, v := range req.Header[k] {
+			if redactedHeaders[http.CanonicalHeaderKey(k)] {
+				v = "REDACTED"
+			}
+			args = append(args, "-H", shellQuote(k+": "+v))
+		}
+	}
+	// The Host header is not part of req.Header
+	if req.Host != "" && req.Host != req.URL.Host {
+		args = append(args, "-H", shellQuote("Host: "+req.Host))
+	}
+	for _, f := range multipartArgs {
+		args = append(args, "-F", shellQuote(f))
+	}
+	if hasData {
+		args = append(args, "--data-binary", shellQuote(data))
+	}
+	args = append(args, shellQuote(req.URL.String()))
+	return strings.Join(args, " ")
+}
+
+// multipartCurlFields returns the curl -F values of a multipart body. File parts are
+// represented as name=@filename. The boolean is false if the body is not multipart
+func multipartCurlFields(contentType string, body []byte) ([]string, bool) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, false
+	}
+	var fields = []string{}
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return fields, true
+		}
+		if part.FileName() != "" {
+			fields = append(fields, part.FormName()+"=@"+part.FileName())
+			continue
+		}
+		var value bytes.Buffer
+		value.ReadFrom(part)
+		fields = append(fields, part.FormName()+"="+value.String())
+	}
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
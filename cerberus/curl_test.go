@@ -0,0 +1,90 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFormatCurl(t *testing.T) {
+	Convey("A JSON request", t, func() {
+		body := bytes.NewBufferString(`{"name":"it's me"}`)
+		req, _ := http.NewRequest(http.MethodPost, "https://cerberus.example.com/v2/safe-deposit-box?a=b", body)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Vault-Token", "a-cool-token")
+		curl := formatCurl(req, body)
+		Convey("Should include the method, URL, headers and body", func() {
+			So(curl, ShouldEqual, `curl -X POST -H 'Content-Type: application/json' -H 'X-Vault-Token: REDACTED' `+
+				`--data-binary '{"name":"it'\''s me"}' 'https://cerberus.example.com/v2/safe-deposit-box?a=b'`)
+		})
+		Convey("Should not include the token", func() {
+			So(curl, ShouldNotContainSubstring, "a-cool-token")
+		})
+	})
+
+	Convey("A file upload", t, func() {
+		body, contentType, err := getUploadFileBodyWriter("hello.txt", "", strings.NewReader("a very secret value"))
+		So(err, ShouldBeNil)
+		req, _ := http.NewRequest(http.MethodPost, "https://cerberus.example.com/v1/secure-file/app/hello.txt", body)
+		req.Header.Set("Content-Type", contentType)
+		curl := formatCurl(req, body)
+		Convey("Should reference the file instead of its content", func() {
+			So(curl, ShouldEqual, `curl -X POST -F 'file-content=@hello.txt' 'https://cerberus.example.com/v1/secure-file/app/hello.txt'`)
+		})
+	})
+
+	Convey("A request without a body", t, func() {
+		req, _ := http.NewRequest(http.MethodGet, "https://cerberus.example.com/v1/secure-file/app/hello.txt", nil)
+		So(formatCurl(req, nil), ShouldEqual, `curl -X GET 'https://cerberus.example.com/v1/secure-file/app/hello.txt'`)
+	})
+
+	Convey("A request with another Host header", t, func() {
+		req, _ := http.NewRequest(http.MethodGet, "https://10.0.0.1/v2/safe-deposit-box", nil)
+		req.Host = "cerberus.example.com"
+		So(formatCurl(req, nil), ShouldEqual, `curl -X GET -H 'Host: cerberus.example.com' 'https://10.0.0.1/v2/safe-deposit-box'`)
+	})
+}
+
+func TestCurlLogging(t *testing.T) {
+	Convey("A client with curl logging", t, WithTestServer(http.StatusOK, "/v2/safe-deposit-box", http.MethodGet, "[]", func(ts *httptest.Server) {
+		logger := &recordingLogger{}
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithLogger(logger), WithCurlLogging(true))
+		So(cl, ShouldNotBeNil)
+		Convey("Should log each request as a curl command", func() {
+			_, err := cl.SDB().List()
+			So(err, ShouldBeNil)
+			So(logger.String(), ShouldContainSubstring, "cerberus: curl -X GET")
+			So(logger.String(), ShouldContainSubstring, ts.URL+"/v2/safe-deposit-box")
+			So(logger.String(), ShouldNotContainSubstring, "a-cool-token")
+		})
+	}))
+
+	Convey("A client with only a logger", t, WithTestServer(http.StatusOK, "/v2/safe-deposit-box", http.MethodGet, "[]", func(ts *httptest.Server) {
+		logger := &recordingLogger{}
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithLogger(logger))
+		Convey("Should not log curl commands", func() {
+			cl.SDB().List()
+			So(logger.String(), ShouldNotContainSubstring, "curl")
+		})
+	}))
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// maxEnvLineLength is the longest line accepted in a .env file
+const maxEnvLineLength = 1024 * 1024
+
+// WriteEnvFile writes the variables of the dotenv file at localEnvFile as the secret at the given path, each
+// variable being a key of the secret. Lines are KEY=value, optionally starting with "export". Blank lines
+// and lines starting with # are ignored. Values can be double quoted, with \n, \r, \t, \", \\, \$ and \` escapes, or
+// single quoted, which keeps them as is. Unquoted values end at a # preceded by a space. If a variable is
+// set more than once, the last value is used. Malformed lines fail with their line number and nothing is
+// written. Path should not be prefaced with a "/"
+func (s *Secret) WriteEnvFile(path, localEnvFile string) error {
+	f, err := os.Open(localEnvFile)
+	if err != nil {
+		return fmt.Errorf("Error while opening %s: %v", localEnvFile, err)
+	}
+	defer f.Close()
+	data, err := parseEnvFile(f)
+	if err != nil {
+		return fmt.Errorf("Error while parsing %s: %v", localEnvFile, err)
+	}
+	_, err = s.Write(path, data)
+	return err
+}
+
+// ErrorSecretNotFound is returned when a secret does not exist
+var ErrorSecretNotFound = fmt.Errorf("Unable to find secret")
+
+// ReadAsEnv reads the secret at the given path and formats it as KEY=value lines, sorted by key, which can be
+// sourced by a shell or written to a .env file read by WriteEnvFile. Values are single quoted unless they only
+// contain safe characters. Values with single quotes or line breaks are double quoted with escapes instead;
+// shells keep \n escapes as is, while WriteEnvFile reads them as line breaks. Values which are not strings are
+// encoded as JSON, or rejected if the client was created using WithStrictEnvValues(true). Keys must be valid
+// shell variable names. Returns ErrorSecretNotFound if the secret does not exist. Path should not be prefaced with a "/"
+func (s *Secret) ReadAsEnv(path string) (string, error) {
+	secret, err := s.Read(path)
+	if err != nil {
+		return "", err
+	}
+	if secret == nil {
+		return "", ErrorSecretNotFound
+	}
+	var keys = make([]string, 0, len(secret.Data))
+	for key := range secret.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var out bytes.Buffer
+	for _, key := range keys {
+		if !validShellName(key) {
+			return "", fmt.Errorf("Error while formatting secret %s: %q is not a valid variable name", path, key)
+		}
+		value, ok := secret.Data[key].(string)
+		if !ok {
+			if s.c.strictEnvValues {
+				return "", fmt.Errorf("Error while formatting secret %s: the value of %s is not a string", path, key)
+			}
+			encoded, err := json.Marshal(secret.Data[key])
+			if err != nil {
+				return "", fmt.Errorf("Error while encoding secret key %s: %v", key, err)
+			}
+			value = string(encoded)
+		}
+		fmt.Fprintf(&out, "%s=%s\n", key, quoteEnvValue(value))
+	}
+	return out.String(), nil
+}
+
+// envReplacer escapes a double quoted value for both shells and parseEnvFile
+var envReplacer = strings.NewReplacer("\\", "\\\\", `"`, `\"`, "$", `\$`, "`", "\\`", "\n", `\n`, "\r", `\r`)
+
+// quoteEnvValue quotes a value of a dotenv file if it contains characters a shell would interpret
+func quoteEnvValue(value string) string {
+	safe := value != ""
+	for _, c := range value {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', strings.ContainsRune("_-.,/:@%+=", c):
+		default:
+			safe = false
+		}
+	}
+	switch {
+	case safe:
+		return value
+	case !strings.ContainsAny(value, "'\n\r"):
+		return "'" + value + "'"
+	default:
+		return `"` + envReplacer.Replace(value) + `"`
+	}
+}
+
+// validShellName returns whether name can be used as a shell variable name
+func validShellName(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseEnvFile returns the variables set in a dotenv file
+func parseEnvFile(r io.Reader) (map[string]interface{}, error) {
+	var data = map[string]interface{}{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEnvLineLength)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, err := parseEnvLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		data[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// parseEnvLine returns the variable set by a line of a dotenv file, which is neither blank nor a comment
+func parseEnvLine(line string) (string, string, error) {
+	if strings.HasPrefix(line, "export ") || strings.HasPrefix(line, "export\t") {
+		line = strings.TrimSpace(line[len("export"):])
+	}
+	i := strings.Index(line, "=")
+	if i < 0 {
+		return "", "", fmt.Errorf("expected KEY=value")
+	}
+	key := strings.TrimSpace(line[:i])
+	if !validEnvKey(key) {
+		return "", "", fmt.Errorf("invalid variable name %q", key)
+	}
+	untrimmed := line[i+1:]
+	raw := strings.TrimSpace(untrimmed)
+	var value, rest string
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		var b bytes.Buffer
+		closed := false
+		j := 1
+		for ; j < len(raw); j++ {
+			c := raw[j]
+			if c == '"' {
+				closed = true
+				break
+			}
+			if c == '\\' && j+1 < len(raw) {
+				j++
+				switch raw[j] {
+				case 'n':
+					b.WriteByte('\n')
+				case 'r':
+					b.WriteByte('\r')
+				case 't':
+					b.WriteByte('\t')
+				case '"', '\\', '$', '`':
+					b.WriteByte(raw[j])
+				default:
+					// Unknown escapes are kept as is
+					b.WriteByte('\\')
+					b.WriteByte(raw[j])
+				}
+				continue
+			}
+			b.WriteByte(c)
+		}
+		if !closed {
+			return "", "", fmt.Errorf("unterminated double quoted value of %s", key)
+		}
+		value, rest = b.String(), raw[j+1:]
+	case strings.HasPrefix(raw, "'"):
+		j := strings.Index(raw[1:], "'")
+		if j < 0 {
+			return "", "", fmt.Errorf("unterminated single quoted value of %s", key)
+		}
+		value, rest = raw[1:j+1], raw[j+2:]
+	default:
+		value = raw
+		for j := 1; j < len(raw); j++ {
+			if raw[j] == '#' && (raw[j-1] == ' ' || raw[j-1] == '\t') {
+				value = strings.TrimSpace(raw[:j])
+				break
+			}
+		}
+		// A comment right after the equal sign leaves the value empty
+		if strings.HasPrefix(raw, "#") && (untrimmed[0] == ' ' || untrimmed[0] == '\t') {
+			value = ""
+		}
+	}
+	// Only a comment can follow a quoted value
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", "", fmt.Errorf("unexpected %q after the quoted value of %s", rest, key)
+	}
+	return key, value, nil
+}
+
+// validEnvKey returns whether key is a valid variable name: letters, digits, underscores, dots and dashes,
+// not starting with a digit
+func validEnvKey(key string) bool {
+	if key == "" || (key[0] >= '0' && key[0] <= '9') {
+		return false
+	}
+	for _, c := range key {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '.', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	Convey("A dotenv file", t, func() {
+		content := strings.Join([]string{
+			"# Database settings",
+			"",
+			"DB_HOST=db.local",
+			"export DB_PORT = 5432",
+			`DB_PASSWORD="hun\"ter2 # not a comment"  # a comment`,
+			`GREETING="hello\nworld"`,
+			"RAW='single $quoted \\n'",
+			"URL=https://example.com/#anchor # trailing comment",
+			"EMPTY=",
+			"COMMENTED= # nothing",
+			"DB_HOST=db2.local",
+		}, "\n")
+		Convey("Should be parsed into variables", func() {
+			data, err := parseEnvFile(strings.NewReader(content))
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, map[string]interface{}{
+				"DB_HOST":     "db2.local",
+				"DB_PORT":     "5432",
+				"DB_PASSWORD": `hun"ter2 # not a comment`,
+				"GREETING":    "hello\nworld",
+				"RAW":         "single $quoted \\n",
+				"URL":         "https://example.com/#anchor",
+				"EMPTY":       "",
+				"COMMENTED":   "",
+			})
+		})
+	})
+
+	Convey("A malformed dotenv file", t, func() {
+		for _, tc := range []struct {
+			line     string
+			expected string
+		}{
+			{"NO_EQUAL_SIGN", "expected KEY=value"},
+			{"1KEY=value", "invalid variable name"},
+			{"MY KEY=value", "invalid variable name"},
+			{`KEY="unterminated`, "unterminated double quoted value"},
+			{"KEY='unterminated", "unterminated single quoted value"},
+			{`KEY="value" extra`, "after the quoted value"},
+		} {
+			Convey("Should report the line of "+tc.line, func() {
+				_, err := parseEnvFile(strings.NewReader("# comment\nOK=1\n" + tc.line + "\n"))
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldStartWith, "line 3: ")
+				So(err.Error(), ShouldContainSubstring, tc.expected)
+			})
+		}
+	})
+}
+
+func TestSecretWriteEnvFile(t *testing.T) {
+	Convey("Writing a dotenv file as a secret", t, func() {
+		var written map[string]interface{}
+		var requests int
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if r.Method != http.MethodPut || r.URL.Path != "/v1/secret/app/sdb/env" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&written)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		dir, err := ioutil.TempDir("", "cerberus-test")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			ts.Close()
+			os.RemoveAll(dir)
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should write its variables", func() {
+			envFile := filepath.Join(dir, ".env")
+			So(ioutil.WriteFile(envFile, []byte("A=1\nB=\"two\"\n"), 0600), ShouldBeNil)
+			So(cl.Secret().WriteEnvFile("app/sdb/env", envFile), ShouldBeNil)
+			So(written, ShouldResemble, map[string]interface{}{"A": "1", "B": "two"})
+		})
+		Convey("Should not write a malformed file", func() {
+			envFile := filepath.Join(dir, ".env")
+			So(ioutil.WriteFile(envFile, []byte("A=1\nB\n"), 0600), ShouldBeNil)
+			err := cl.Secret().WriteEnvFile("app/sdb/env", envFile)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "line 2")
+			So(requests, ShouldEqual, 0)
+		})
+		Convey("Should return an error for a missing file", func() {
+			So(cl.Secret().WriteEnvFile("app/sdb/env", filepath.Join(dir, "missing.env")), ShouldNotBeNil)
+			So(requests, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestSecretReadAsEnv(t *testing.T) {
+	var secretResponse = `{"data": {
+		"DB_HOST": "db.local",
+		"DB_PASSWORD": "it's $ecret \"quoted\"\nsecond line",
+		"GREETING": "hello world",
+		"EMPTY": "",
+		"DB_PORT": 5432
+	}}`
+
+	Convey("A secret read as a dotenv file", t, WithTestServer(http.StatusOK, "/v1/secret/app/sdb/env", http.MethodGet, secretResponse, func(ts *httptest.Server) {
+		Convey("Should format every key as a sorted line", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			env, err := cl.Secret().ReadAsEnv("app/sdb/env")
+			So(err, ShouldBeNil)
+			So(env, ShouldEqual, strings.Join([]string{
+				"DB_HOST=db.local",
+				`DB_PASSWORD="it's \$ecret \"quoted\"\nsecond line"`,
+				"DB_PORT=5432",
+				"EMPTY=''",
+				"GREETING='hello world'",
+				"",
+			}, "\n"))
+			Convey("Which can be parsed back", func() {
+				data, err := parseEnvFile(strings.NewReader(env))
+				So(err, ShouldBeNil)
+				So(data, ShouldResemble, map[string]interface{}{
+					"DB_HOST":     "db.local",
+					"DB_PASSWORD": "it's $ecret \"quoted\"\nsecond line",
+					"DB_PORT":     "5432",
+					"EMPTY":       "",
+					"GREETING":    "hello world",
+				})
+			})
+		})
+		Convey("Should reject values which are not strings when strict", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithStrictEnvValues(true))
+			_, err := cl.Secret().ReadAsEnv("app/sdb/env")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "DB_PORT is not a string")
+		})
+	}))
+
+	Convey("A secret with a key which is not a variable name", t, WithTestServer(http.StatusOK, "/v1/secret/app/sdb/env", http.MethodGet, `{"data": {"db.host": "a"}}`, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		Convey("Should return an error", func() {
+			_, err := cl.Secret().ReadAsEnv("app/sdb/env")
+			So(err, ShouldNotBeNil)
+		})
+	}))
+
+	Convey("A missing secret", t, WithTestServer(http.StatusNotFound, "/v1/secret/app/sdb/env", http.MethodGet, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		Convey("Should return ErrorSecretNotFound", func() {
+			_, err := cl.Secret().ReadAsEnv("app/sdb/env")
+			So(err, ShouldEqual, ErrorSecretNotFound)
+		})
+	}))
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TokenNearExpiryFunc is called with the remaining validity of the token when a request is sent with a token
+// close to expiring, see WithTokenNearExpiry
+type TokenNearExpiryFunc func(remaining time.Duration)
+
+// tokenExpiry caches when the last seen token expires, so its TTL is only looked up once. It is a pointer in
+// Client so clients returned by WithSDB share it with the client they come from, like the token
+type tokenExpiry struct {
+	lock  sync.Mutex
+	token string
+	// expires is zero if the token does not expire or its TTL could not be looked up
+	expires time.Time
+	// lookingUp is the token whose TTL is being looked up, if any
+	lookingUp string
+}
+
+// noExpiryCheckKey is the context key disabling the expiry check for a request
+type noExpiryCheckKey struct{}
+
+// checkTokenExpiry calls the handler set with WithTokenNearExpiry if token expires within the threshold. The
+// TTL of a token is looked up the first time it is seen, without holding the lock, and requests sent during
+// the lookup are not checked. A token whose TTL cannot be looked up is not checked
+func (c *Client) checkTokenExpiry(ctx context.Context, token string) {
+	if c.tokenNearExpiry == nil || token == "" || ctx.Value(noExpiryCheckKey{}) != nil {
+		return
+	}
+	c.tokenExpiry.lock.Lock()
+	if c.tokenExpiry.token == token {
+		expires := c.tokenExpiry.expires
+		c.tokenExpiry.lock.Unlock()
+		c.warnNearExpiry(expires)
+		return
+	}
+	if c.tokenExpiry.lookingUp == token {
+		c.tokenExpiry.lock.Unlock()
+		return
+	}
+	c.tokenExpiry.lookingUp = token
+	c.tokenExpiry.lock.Unlock()
+
+	var expires time.Time
+	// The lookup is a request too
+	if ttl, err := c.tokenTTL(context.WithValue(ctx, noExpiryCheckKey{}, true)); err == nil && ttl > 0 {
+		expires = c.clock.Now().Add(ttl)
+	}
+	c.tokenExpiry.lock.Lock()
+	c.tokenExpiry.token = token
+	c.tokenExpiry.expires = expires
+	if c.tokenExpiry.lookingUp == token {
+		c.tokenExpiry.lookingUp = ""
+	}
+	c.tokenExpiry.lock.Unlock()
+	c.warnNearExpiry(expires)
+}
+
+// warnNearExpiry calls the handler set with WithTokenNearExpiry if expires is within the threshold
+func (c *Client) warnNearExpiry(expires time.Time) {
+	if expires.IsZero() {
+		return
+	}
+	remaining := expires.Sub(c.clock.Now())
+	if remaining < 0 {
+		remaining = 0
+	}
+	if remaining < c.tokenNearExpiryThreshold {
+		c.tokenNearExpiry(remaining)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// ttlServer answers token lookups with the given TTL and every other request with a 200, counting the lookups
+func ttlServer(ttl string, lookups *int, lock *sync.Mutex) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != tokenLookupSelfPath {
+			w.Write([]byte(`{}`))
+			return
+		}
+		lock.Lock()
+		*lookups++
+		lock.Unlock()
+		if ttl == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"data": {"ttl": ` + ttl + `}}`))
+	}))
+}
+
+func TestTokenNearExpiry(t *testing.T) {
+	Convey("A client warned about tokens close to expiring", t, func() {
+		var lock sync.Mutex
+		var lookups int
+		var warnings []time.Duration
+		clock := &fakeClock{now: time.Date(2018, 6, 14, 10, 0, 0, 0, time.UTC)}
+		warn := func(remaining time.Duration) {
+			warnings = append(warnings, remaining)
+		}
+		newClient := func(ts *httptest.Server) *Client {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil,
+				WithClock(clock), WithTokenNearExpiry(time.Minute, warn))
+			return cl
+		}
+		Convey("Should warn once the remaining TTL is below the threshold", func() {
+			ts := ttlServer("120", &lookups, &lock)
+			defer ts.Close()
+			cl := newClient(ts)
+			So(cl, ShouldNotBeNil)
+			_, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			So(warnings, ShouldBeEmpty)
+			clock.advance(90 * time.Second)
+			_, err = cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			clock.advance(time.Hour)
+			_, err = cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			So(warnings, ShouldResemble, []time.Duration{30 * time.Second, 0})
+			So(lookups, ShouldEqual, 1)
+		})
+		Convey("Should look up the TTL of a new token", func() {
+			ts := ttlServer("30", &lookups, &lock)
+			defer ts.Close()
+			m := GenerateMockAuth(ts.URL, "a-cool-token", false, false)
+			cl, _ := NewClient(m, nil, WithClock(clock), WithTokenNearExpiry(time.Minute, warn))
+			So(cl, ShouldNotBeNil)
+			cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			// The mock does not update its headers on refresh
+			m.headers.Set("X-Vault-Token", refreshedToken)
+			cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(lookups, ShouldEqual, 2)
+			So(warnings, ShouldHaveLength, 2)
+		})
+		Convey("Should not warn for tokens which do not expire", func() {
+			ts := ttlServer("0", &lookups, &lock)
+			defer ts.Close()
+			cl := newClient(ts)
+			So(cl, ShouldNotBeNil)
+			_, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			So(warnings, ShouldBeEmpty)
+		})
+		Convey("Should not fail requests if the TTL cannot be looked up", func() {
+			ts := ttlServer("", &lookups, &lock)
+			defer ts.Close()
+			cl := newClient(ts)
+			So(cl, ShouldNotBeNil)
+			_, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			_, err = cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			So(warnings, ShouldBeEmpty)
+			So(lookups, ShouldEqual, 1)
+		})
+		Convey("Should not block other requests during the lookup", func() {
+			release := make(chan struct{})
+			started := make(chan struct{})
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if r.URL.Path == tokenLookupSelfPath {
+					close(started)
+					<-release
+					w.Write([]byte(`{"data": {"ttl": 30}}`))
+					return
+				}
+				w.Write([]byte(`{}`))
+			}))
+			defer ts.Close()
+			cl := newClient(ts)
+			So(cl, ShouldNotBeNil)
+			done := make(chan error)
+			go func() {
+				_, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+				done <- err
+			}()
+			<-started
+			_, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			So(warnings, ShouldBeEmpty)
+			close(release)
+			So(<-done, ShouldBeNil)
+			So(warnings, ShouldResemble, []time.Duration{30 * time.Second})
+		})
+		Convey("Should not look up the TTL without a handler", func() {
+			ts := ttlServer("30", &lookups, &lock)
+			defer ts.Close()
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			So(cl, ShouldNotBeNil)
+			_, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			So(lookups, ShouldEqual, 0)
+		})
+	})
+}
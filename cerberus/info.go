@@ -0,0 +1,91 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Nike-Inc/cerberus-go-client/api"
+)
+
+// ErrorServerInfoNotAvailable is returned when the server does not expose its version information
+var ErrorServerInfoNotAvailable = fmt.Errorf("Server version information is not available")
+
+var serverInfoPath = "/info"
+
+// ServerInfo returns the version and build information of the Cerberus server. If the server only
+// reports build information, Version is set to the build version. Returns ErrorServerInfoNotAvailable
+// if the server does not have the info endpoint
+func (c *Client) ServerInfo(ctx context.Context) (api.ServerInfo, error) {
+	var info = api.ServerInfo{}
+	resp, err := c.doJSONRequest(ctx, http.MethodGet, serverInfoPath, map[string]string{}, nil)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return info, fmt.Errorf("Error while trying to get server info: %v", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return info, ErrorServerInfoNotAvailable
+	}
+	if resp.StatusCode != http.StatusOK {
+		return info, fmt.Errorf("Error while trying to GET server info. Got HTTP status code %d", resp.StatusCode)
+	}
+	if err := c.decodeResponse(resp.Body, &info); err != nil {
+		return api.ServerInfo{}, err
+	}
+	if info.Version == "" {
+		info.Version = info.Build.Version
+	}
+	return info, nil
+}
+
+// ErrorAuthMethodsNotAvailable is returned when the server does not list its authentication methods
+var ErrorAuthMethodsNotAvailable = fmt.Errorf("Authentication methods are not available")
+
+var authMethodsPath = "/v1/auth/methods"
+
+// AuthMethods returns the names of the authentication methods supported by the Cerberus server, in the order
+// it lists them, so a tool can only offer the logins that work. Returns ErrorAuthMethodsNotAvailable if the
+// server does not have the auth methods endpoint, as is the case for older servers
+func (c *Client) AuthMethods(ctx context.Context) ([]string, error) {
+	resp, err := c.doJSONRequest(ctx, http.MethodGet, authMethodsPath, map[string]string{}, nil)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error while trying to get auth methods: %v", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrorAuthMethodsNotAvailable
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error while trying to GET auth methods. Got HTTP status code %d", resp.StatusCode)
+	}
+	var methods = api.AuthMethodsResponse{}
+	if err := c.decodeResponse(resp.Body, &methods); err != nil {
+		return nil, err
+	}
+	if methods.Methods == nil {
+		return []string{}, nil
+	}
+	return methods.Methods, nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var serverInfoBody = `{
+	"build": {
+		"version": "3.14.0",
+		"artifact": "cms",
+		"name": "cms",
+		"group": "com.nike.cerberus",
+		"time": "2018-06-14T10:34:55.057Z"
+	}
+}`
+
+func TestServerInfo(t *testing.T) {
+	Convey("A valid call to ServerInfo", t, WithTestServer(http.StatusOK, "/info", http.MethodGet, serverInfoBody, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return the server version", func() {
+			info, err := cl.ServerInfo(context.Background())
+			So(err, ShouldBeNil)
+			So(info.Version, ShouldEqual, "3.14.0")
+			So(info.Build.Artifact, ShouldEqual, "cms")
+		})
+	}))
+
+	Convey("A server without the info endpoint", t, WithTestServer(http.StatusNotFound, "/info", http.MethodGet, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return a not available error", func() {
+			_, err := cl.ServerInfo(context.Background())
+			So(err, ShouldEqual, ErrorServerInfoNotAvailable)
+		})
+	}))
+
+	Convey("An invalid call to ServerInfo", t, WithTestServer(http.StatusInternalServerError, "/info", http.MethodGet, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should error", func() {
+			_, err := cl.ServerInfo(context.Background())
+			So(err, ShouldNotBeNil)
+		})
+	}))
+
+	Convey("A canceled call to ServerInfo", t, WithTestServer(http.StatusOK, "/info", http.MethodGet, serverInfoBody, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should error", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := cl.ServerInfo(ctx)
+			So(err, ShouldNotBeNil)
+		})
+	}))
+}
+
+func TestAuthMethods(t *testing.T) {
+	Convey("A valid call to AuthMethods", t, WithTestServer(http.StatusOK, "/v1/auth/methods", http.MethodGet, `{"auth_methods": ["user", "sts"]}`, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return the supported methods", func() {
+			methods, err := cl.AuthMethods(context.Background())
+			So(err, ShouldBeNil)
+			So(methods, ShouldResemble, []string{"user", "sts"})
+		})
+	}))
+
+	Convey("A server without any auth method", t, WithTestServer(http.StatusOK, "/v1/auth/methods", http.MethodGet, `{}`, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return an empty list", func() {
+			methods, err := cl.AuthMethods(context.Background())
+			So(err, ShouldBeNil)
+			So(methods, ShouldBeEmpty)
+			So(methods, ShouldNotBeNil)
+		})
+	}))
+
+	Convey("A server without the auth methods endpoint", t, WithTestServer(http.StatusNotFound, "/v1/auth/methods", http.MethodGet, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return a not available error", func() {
+			_, err := cl.AuthMethods(context.Background())
+			So(err, ShouldEqual, ErrorAuthMethodsNotAvailable)
+		})
+	}))
+
+	Convey("An invalid call to AuthMethods", t, WithTestServer(http.StatusInternalServerError, "/v1/auth/methods", http.MethodGet, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should error", func() {
+			_, err := cl.AuthMethods(context.Background())
+			So(err, ShouldNotBeNil)
+		})
+	}))
+}
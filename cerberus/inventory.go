@@ -0,0 +1,96 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Nike-Inc/cerberus-go-client/api"
+)
+
+// Inventory returns the paths of all secrets and secure files the current token can read, across all the SDBs
+// it can see, sorted and without duplicates. Secret paths are the vault paths without the "secret/" prefix,
+// such as "app/my-sdb/db-password", and secure files are the paths returned in their summaries. SDBs the
+// token is not allowed to read are skipped and reported to the Logger set with WithLogger, if any.
+// Listing stops when the context is done
+func (c *Client) Inventory(ctx context.Context) ([]string, error) {
+	boxes, err := c.SDB().List()
+	if err != nil {
+		return nil, err
+	}
+	var seen = map[string]bool{}
+	for _, box := range boxes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		allowed, err := c.CanAccess(box.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			if c.logger != nil {
+				c.logger.Printf("cerberus: skipping SDB %s in inventory: permission denied", box.Path)
+			}
+			continue
+		}
+		if err := c.listSecretsRecursive(ctx, strings.Trim(box.Path, "/"), seen); err != nil {
+			return nil, err
+		}
+		err = c.SecureFile().iterate(ctx, absoluteSecurePath(box.Path), func(summary api.SecureFileSummary) error {
+			seen[strings.Trim(summary.Path, "/")] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	var paths = make([]string, 0, len(seen))
+	for p := range seen {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// listSecretsRecursive adds the paths of all secrets under dir to seen
+func (c *Client) listSecretsRecursive(ctx context.Context, dir string, seen map[string]bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	keys, err := c.listSecretKeys(ctx, dir)
+	if err == errSecretAccessDenied {
+		return fmt.Errorf("Error while listing secrets under %s: %v", dir, err)
+	}
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		// Keys ending with a slash are folders
+		if strings.HasSuffix(key, "/") {
+			if err := c.listSecretsRecursive(ctx, path.Join(dir, key), seen); err != nil {
+				return err
+			}
+			continue
+		}
+		seen[path.Join(dir, key)] = true
+	}
+	return nil
+}
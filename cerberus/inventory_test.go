@@ -0,0 +1,89 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// inventoryServer serves two SDBs, the second of which cannot be read
+func inventoryServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v2/safe-deposit-box":
+			w.Write([]byte(`[{"id": "a", "path": "app/readable/"}, {"id": "b", "path": "app/forbidden/"}]`))
+		case "/v1/secret/app/readable/":
+			w.Write([]byte(`{"data": {"keys": ["db", "nested/"]}}`))
+		case "/v1/secret/app/readable/nested/":
+			w.Write([]byte(`{"data": {"keys": ["api-key"]}}`))
+		case "/v1/secure-files/app/readable/":
+			w.Write([]byte(`{"has_next": false, "secure_file_summaries": [{"path": "app/readable/cert.pem"}]}`))
+		case "/v1/secret/app/forbidden/":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+}
+
+func TestInventory(t *testing.T) {
+	Convey("A token with access to some SDBs", t, func() {
+		ts := inventoryServer()
+		Reset(func() {
+			ts.Close()
+		})
+		logger := &recordingLogger{}
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithLogger(logger))
+		So(cl, ShouldNotBeNil)
+		Convey("Should list the secrets and secure files of the readable SDBs", func() {
+			paths, err := cl.Inventory(context.Background())
+			So(err, ShouldBeNil)
+			So(paths, ShouldResemble, []string{
+				"app/readable/cert.pem",
+				"app/readable/db",
+				"app/readable/nested/api-key",
+			})
+		})
+		Convey("Should report the skipped SDBs", func() {
+			_, err := cl.Inventory(context.Background())
+			So(err, ShouldBeNil)
+			So(logger.String(), ShouldContainSubstring, "skipping SDB app/forbidden/ in inventory: permission denied")
+		})
+		Convey("Should stop when the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := cl.Inventory(ctx)
+			So(err, ShouldEqual, context.Canceled)
+		})
+	})
+
+	Convey("A server error", t, WithTestServer(http.StatusInternalServerError, "/v2/safe-deposit-box", http.MethodGet, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should error", func() {
+			paths, err := cl.Inventory(context.Background())
+			So(err, ShouldNotBeNil)
+			So(paths, ShouldBeNil)
+		})
+	}))
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// Logger is used by the client to log debugging information about requests. It is satisfied by *log.Logger.
+// Body content is not logged, only its size and, for multipart bodies, the names of the fields, unless
+// WithCurlLogging is enabled
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// logRequest logs the size of the request body and its multipart field names, if any
+func (c *Client) logRequest(req *http.Request, body io.Reader) {
+	if c.logger == nil {
+		return
+	}
+	msg := fmt.Sprintf("cerberus: %s %s request body %s", req.Method, req.URL.Path, formatSize(req.ContentLength))
+	// Only buffered bodies can be inspected without consuming them
+	if buf, ok := body.(*bytes.Buffer); ok {
+		if fields := multipartFieldNames(req.Header.Get("Content-Type"), buf.Bytes()); len(fields) > 0 {
+			msg += fmt.Sprintf(" (multipart fields: %s)", strings.Join(fields, ", "))
+		}
+	}
+	c.logger.Printf("%s%s", msg, c.correlationSuffix(req))
+}
+
+// logResponse logs the status and size of a response body
+func (c *Client) logResponse(req *http.Request, resp *http.Response) {
+	if c.logger == nil {
+		return
+	}
+	c.logger.Printf("cerberus: %s %s response %d body %s%s", req.Method, req.URL.Path, resp.StatusCode, formatSize(resp.ContentLength),
+		c.correlationSuffix(req))
+}
+
+// correlationSuffix returns the correlation ID of a request to append to its log lines, if it has one
+func (c *Client) correlationSuffix(req *http.Request) string {
+	if id := req.Header.Get(c.correlationIDHeader); id != "" {
+		return fmt.Sprintf(" (correlation ID %s)", id)
+	}
+	return ""
+}
+
+// formatSize formats a content length, which is negative when unknown
+func formatSize(n int64) string {
+	if n < 0 {
+		return "of unknown size"
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// multipartFieldNames returns the names of the fields of a multipart body. The content of the parts is not read
+func multipartFieldNames(contentType string, body []byte) []string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil
+	}
+	var names []string
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return names
+		}
+		names = append(names, part.FormName())
+	}
+}
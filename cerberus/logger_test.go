@@ -0,0 +1,80 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// recordingLogger keeps all logged lines in memory
+type recordingLogger struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func (l *recordingLogger) String() string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return strings.Join(l.lines, "\n")
+}
+
+func TestLogBodySizes(t *testing.T) {
+	Convey("An upload with a logger configured", t, withBinaryTestServer(http.StatusNoContent,
+		"/v1/secure-file/test/file/hello.txt",
+		http.MethodPost,
+		"hello.txt",
+		[]byte{},
+		func(ts *httptest.Server) {
+			logger := &recordingLogger{}
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithLogger(logger))
+			So(cl, ShouldNotBeNil)
+			Convey("Should log the sizes and field names but not the content", func() {
+				err := cl.SecureFile().Put("/test/file/hello.txt", "hello.txt", getTestInputReader(t, "a very secret value"))
+				So(err, ShouldBeNil)
+				So(logger.String(), ShouldContainSubstring, "POST /v1/secure-file/test/file/hello.txt request body")
+				So(logger.String(), ShouldContainSubstring, "multipart fields: file-content")
+				So(logger.String(), ShouldContainSubstring, "response 204 body 0 bytes")
+				So(logger.String(), ShouldNotContainSubstring, "a very secret value")
+			})
+		}))
+}
+
+func TestMultipartFieldNames(t *testing.T) {
+	Convey("A multipart body", t, func() {
+		body, contentType, err := getUploadFileBodyWriter("hello.txt", "", strings.NewReader("hello"))
+		So(err, ShouldBeNil)
+		Convey("Should return the field names", func() {
+			So(multipartFieldNames(contentType, body.(interface{ Bytes() []byte }).Bytes()), ShouldResemble, []string{"file-content"})
+		})
+	})
+	Convey("A non multipart body", t, func() {
+		So(multipartFieldNames("application/json", []byte(`{}`)), ShouldBeNil)
+	})
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// GetMerged downloads the secure files at the given paths, parses each of them as a JSON object and
+// merges them in order: values from later files override the ones from earlier files. Objects are
+// merged recursively, any other value (including arrays) is replaced as a whole
+func (r *SecureFile) GetMerged(paths []string) (map[string]interface{}, error) {
+	var merged = map[string]interface{}{}
+	for _, p := range paths {
+		var content bytes.Buffer
+		if err := r.Get(p, &content); err != nil {
+			return nil, err
+		}
+		var layer map[string]interface{}
+		// Secure files are not API responses: decode them without the response size limit, and without
+		// NonJSONResponseError which would quote their content
+		if err := json.Unmarshal(content.Bytes(), &layer); err != nil {
+			return nil, fmt.Errorf("error while parsing secure file %s: not a JSON object: %v", p, err)
+		}
+		mergeJSON(merged, layer)
+	}
+	return merged, nil
+}
+
+// mergeJSON deep merges src into dst
+func mergeJSON(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[string]interface{})
+		dstMap, dstIsMap := dst[k].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeJSON(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+}
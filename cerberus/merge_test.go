@@ -0,0 +1,84 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGetMerged(t *testing.T) {
+	Convey("Layered config files", t, func() {
+		server := newFakeSecureFileServer(map[string]string{
+			"app/sdb/base.json":     `{"db": {"host": "localhost", "port": 5432}, "features": ["a", "b"], "debug": true}`,
+			"app/sdb/override.json": `{"db": {"host": "db.example.com"}, "features": ["c"], "debug": null}`,
+			"app/sdb/list.json":     `["not", "an", "object"]`,
+			"app/sdb/hello.txt":     "hello",
+		})
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should be deep merged with later files overriding earlier ones", func() {
+			merged, err := cl.SecureFile().GetMerged([]string{"app/sdb/base.json", "app/sdb/override.json"})
+			So(err, ShouldBeNil)
+			So(merged, ShouldResemble, map[string]interface{}{
+				"db": map[string]interface{}{
+					"host": "db.example.com",
+					"port": float64(5432),
+				},
+				"features": []interface{}{"c"},
+				"debug":    nil,
+			})
+		})
+		Convey("Should return an empty map for no files", func() {
+			merged, err := cl.SecureFile().GetMerged(nil)
+			So(err, ShouldBeNil)
+			So(merged, ShouldBeEmpty)
+		})
+		Convey("Should fail for a file which is not JSON", func() {
+			_, err := cl.SecureFile().GetMerged([]string{"app/sdb/base.json", "app/sdb/hello.txt"})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "app/sdb/hello.txt")
+		})
+		Convey("Should not include the content of a malformed file in the error", func() {
+			server.files["app/sdb/broken.json"] = []byte(`{"password": "hunter2",}`)
+			_, err := cl.SecureFile().GetMerged([]string{"app/sdb/broken.json"})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldNotContainSubstring, "hunter2")
+		})
+		Convey("Should not limit the size of the files to the response size limit", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithMaxResponseBytes(10))
+			merged, err := cl.SecureFile().GetMerged([]string{"app/sdb/base.json"})
+			So(err, ShouldBeNil)
+			So(merged["debug"], ShouldEqual, true)
+		})
+		Convey("Should fail for JSON which is not an object", func() {
+			_, err := cl.SecureFile().GetMerged([]string{"app/sdb/list.json"})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "not a JSON object")
+		})
+		Convey("Should fail for a missing file", func() {
+			_, err := cl.SecureFile().GetMerged([]string{"app/sdb/missing.json"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -17,6 +17,9 @@ limitations under the License.
 package cerberus
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 
@@ -32,20 +35,28 @@ type Metadata struct {
 type MetadataOpts struct {
 	Limit  uint
 	Offset uint
+	// Cursor is the NextCursor of the previous page. When set, it is sent instead of Offset
+	Cursor api.PageCursor
 }
 
 var metadataBasePath = "/v1/metadata"
 
+// metadataPageSize is the default page size of List, and the page size used by ListAll
+var metadataPageSize uint = 100
+
 // List returns a MetadataResponse which is a wrapper containing pagination data and an array of metadata objects
 func (m *Metadata) List(opts MetadataOpts) (*api.MetadataResponse, error) {
 	// Set the limit opt to default if it isn't set
 	if opts.Limit == 0 {
-		opts.Limit = 100
+		opts.Limit = metadataPageSize
 	}
 	// Put the options into the params
 	var params = map[string]string{}
-	params["limit"] = fmt.Sprintf("%d", opts.Limit)
-	params["offset"] = fmt.Sprintf("%d", opts.Offset)
+	params[m.c.limitParam] = fmt.Sprintf("%d", opts.Limit)
+	params[m.c.offsetParam] = fmt.Sprintf("%d", opts.Offset)
+	if opts.Cursor != "" {
+		params[m.c.offsetParam] = string(opts.Cursor)
+	}
 	resp, err := m.c.DoRequest(http.MethodGet, metadataBasePath, params, nil)
 	if resp != nil {
 		defer resp.Body.Close()
@@ -63,9 +74,41 @@ func (m *Metadata) List(opts MetadataOpts) (*api.MetadataResponse, error) {
 		return nil, fmt.Errorf("Error while trying to GET metadata. Got HTTP status code %d", resp.StatusCode)
 	}
 	var metadataResp = &api.MetadataResponse{}
-	err = parseResponse(resp.Body, metadataResp)
+	err = m.c.decodeResponse(resp.Body, metadataResp)
 	if err != nil {
 		return nil, err
 	}
 	return metadataResp, nil
 }
+
+// ListAll returns the metadata of all SDBs, requesting as many pages as needed
+func (m *Metadata) ListAll() ([]api.SDBMetadata, error) {
+	var metadata = []api.SDBMetadata{}
+	err := m.c.paginate(context.Background(), metadataBasePath,
+		map[string]string{
+			m.c.limitParam: fmt.Sprintf("%d", metadataPageSize),
+		},
+		func(raw json.RawMessage) error {
+			page := &api.MetadataResponse{}
+			if err := m.c.decodeResponse(bytes.NewReader(raw), page); err != nil {
+				return err
+			}
+			metadata = append(metadata, page.Metadata...)
+			return nil
+		})
+	if pageErr, ok := err.(*pageError); ok {
+		switch {
+		case pageErr.err != nil:
+			return nil, fmt.Errorf("Error while trying to get metadata: %v", pageErr.err)
+		case pageErr.statusCode == http.StatusBadRequest:
+			// Return the API error to the user
+			return nil, handleAPIError(bytes.NewReader(pageErr.body))
+		default:
+			return nil, fmt.Errorf("Error while trying to GET metadata. Got HTTP status code %d", pageErr.statusCode)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
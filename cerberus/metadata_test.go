@@ -17,6 +17,7 @@ limitations under the License.
 package cerberus
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -72,6 +73,7 @@ var metadataBody = `{
 var expectedMetadata = &api.MetadataResponse{
 	HasNext:     false,
 	NextOffset:  0,
+	NextCursor:  "0",
 	Limit:       10,
 	Offset:      0,
 	ResultCount: 2,
@@ -154,3 +156,59 @@ func TestListMetadata(t *testing.T) {
 		})
 	})
 }
+
+func TestMetadataPaginationParams(t *testing.T) {
+	Convey("A List with custom pagination parameters", t, WithServer(http.StatusOK, false, "/v1/metadata", http.MethodGet, "", map[string]string{"per_page": "10", "page": "20"}, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithPaginationParams("per_page", "page"))
+		So(cl, ShouldNotBeNil)
+		Convey("Should use the configured parameter names", func() {
+			_, err := cl.Metadata().List(MetadataOpts{Limit: 10, Offset: 20})
+			So(err, ShouldBeNil)
+		})
+	}))
+}
+
+func TestMetadataCursor(t *testing.T) {
+	Convey("A List with a cursor", t, WithServer(http.StatusOK, false, "/v1/metadata", http.MethodGet, "", map[string]string{"limit": "10", "offset": "opaque-token"}, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should send the cursor instead of the offset", func() {
+			_, err := cl.Metadata().List(MetadataOpts{Limit: 10, Offset: 20, Cursor: "opaque-token"})
+			So(err, ShouldBeNil)
+		})
+	}))
+}
+
+func TestMetadataListAll(t *testing.T) {
+	Convey("Metadata over several pages", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if r.URL.Query().Get("offset") == "0" {
+				fmt.Fprint(w, `{"has_next": true, "next_offset": 1, "safe_deposit_box_metadata": [{"name": "a"}]}`)
+				return
+			}
+			fmt.Fprint(w, `{"has_next": false, "safe_deposit_box_metadata": [{"name": "b"}]}`)
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return the metadata of every page", func() {
+			metadata, err := cl.Metadata().ListAll()
+			So(err, ShouldBeNil)
+			So(metadata, ShouldHaveLength, 2)
+			So(metadata[0].Name, ShouldEqual, "a")
+			So(metadata[1].Name, ShouldEqual, "b")
+		})
+	})
+
+	Convey("A ListAll rejected by the API", t, WithTestServer(http.StatusBadRequest, "/v1/metadata", http.MethodGet, `{"error_id": "bad-request", "errors": [{"code": 1, "message": "bad limit"}]}`, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return the API error", func() {
+			_, err := cl.Metadata().ListAll()
+			So(err, ShouldHaveSameTypeAs, api.ErrorResponse{})
+		})
+	}))
+}
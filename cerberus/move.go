@@ -0,0 +1,66 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Move moves a secure file to another path. Cerberus has no move operation, so the file is downloaded,
+// uploaded to the new path and then deleted from the old one. If the delete fails, the file exists at
+// both paths and the error says so. Moving a file to its own path does nothing
+func (r *SecureFile) Move(srcPath, dstPath string) error {
+	// Clean the paths so equivalent spellings of the same path are not taken for two files
+	src := strings.TrimPrefix(path.Clean("/"+r.resolvePath(srcPath)), "/")
+	dst := strings.TrimPrefix(path.Clean("/"+r.resolvePath(dstPath)), "/")
+	if src == dst {
+		return nil
+	}
+	var content bytes.Buffer
+	if err := r.Get(absoluteSecurePath(src), &content); err != nil {
+		return err
+	}
+	if err := r.Put(absoluteSecurePath(dst), path.Base(dst), &content); err != nil {
+		return err
+	}
+	if err := r.Delete(absoluteSecurePath(src)); err != nil {
+		return fmt.Errorf("secure file %s was copied to %s but could not be deleted: %v", src, dst, err)
+	}
+	return nil
+}
+
+// Rename renames the secure file oldName in the folder secureDirPath to newName, using Move. Both names
+// must be file names, not paths
+func (r *SecureFile) Rename(secureDirPath, oldName, newName string) error {
+	for _, name := range []string{oldName, newName} {
+		if err := validateFileName(name); err != nil {
+			return err
+		}
+	}
+	return r.Move(path.Join(secureDirPath, oldName), path.Join(secureDirPath, newName))
+}
+
+// validateFileName checks that name can be used as the name of a secure file in a folder
+func validateFileName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid secure file name %q: it must be a name without path separators", name)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSecureFileMove(t *testing.T) {
+	Convey("A secure file", t, func() {
+		server := newFakeSecureFileServer(map[string]string{"app/sdb/a.txt": "hello"})
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should be moved to the new path", func() {
+			So(cl.SecureFile().Move("app/sdb/a.txt", "app/sdb/sub/b.txt"), ShouldBeNil)
+			So(string(server.files["app/sdb/sub/b.txt"]), ShouldEqual, "hello")
+			So(server.files, ShouldNotContainKey, "app/sdb/a.txt")
+		})
+		Convey("Should stay in place when moved to its own path", func() {
+			So(cl.SecureFile().Move("app/sdb/a.txt", "/app/sdb//a.txt"), ShouldBeNil)
+			So(string(server.files["app/sdb/a.txt"]), ShouldEqual, "hello")
+			So(server.uploads, ShouldEqual, 0)
+		})
+		Convey("Should be renamed in its folder", func() {
+			So(cl.SecureFile().Rename("app/sdb", "a.txt", "renamed.txt"), ShouldBeNil)
+			So(string(server.files["app/sdb/renamed.txt"]), ShouldEqual, "hello")
+			So(server.files, ShouldNotContainKey, "app/sdb/a.txt")
+		})
+		Convey("Should not be renamed to a path", func() {
+			for _, name := range []string{"sub/b.txt", "..", "", `a\b.txt`} {
+				So(cl.SecureFile().Rename("app/sdb", "a.txt", name), ShouldNotBeNil)
+			}
+			So(server.uploads, ShouldEqual, 0)
+		})
+		Convey("Should error when it does not exist", func() {
+			So(cl.SecureFile().Move("app/sdb/missing.txt", "app/sdb/b.txt"), ShouldNotBeNil)
+			So(server.uploads, ShouldEqual, 0)
+		})
+	})
+
+	Convey("A secure file which cannot be deleted", t, func() {
+		server := newFakeSecureFileServer(map[string]string{"app/sdb/a.txt": "hello"})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodDelete {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			server.ServeHTTP(w, r)
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should report that it was only copied", func() {
+			err := cl.SecureFile().Move("app/sdb/a.txt", "app/sdb/b.txt")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "copied to app/sdb/b.txt but could not be deleted")
+			So(server.files, ShouldContainKey, "app/sdb/b.txt")
+		})
+	})
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// pathError is the failure of an operation on a single path
+type pathError struct {
+	path string
+	err  error
+}
+
+func (e *pathError) Error() string {
+	return fmt.Sprintf("%s: %v", e.path, e.err)
+}
+
+// Unwrap returns the error of the operation
+func (e *pathError) Unwrap() error {
+	return e.err
+}
+
+// multiError collects the failures of an operation applied to many paths, such as the files of a
+// recursive transfer. Each failure is unwrapped to its own error, so errors.Is and errors.As look
+// through all of them
+type multiError struct {
+	// total is the number of attempted operations, including the successful ones
+	total  int
+	errors []*pathError
+}
+
+// add records the failure of the operation on p
+func (m *multiError) add(p string, err error) {
+	m.errors = append(m.errors, &pathError{path: p, err: err})
+}
+
+// errorOrNil returns m if any operation failed, and nil otherwise so the result can be returned as an error
+func (m *multiError) errorOrNil() error {
+	if len(m.errors) == 0 {
+		return nil
+	}
+	sort.Slice(m.errors, func(i, j int) bool {
+		return m.errors[i].path < m.errors[j].path
+	})
+	return m
+}
+
+func (m *multiError) Error() string {
+	var details = make([]string, 0, len(m.errors))
+	for _, err := range m.errors {
+		details = append(details, err.Error())
+	}
+	return fmt.Sprintf("%d of %d operations failed: %s", len(m.errors), m.total, strings.Join(details, "; "))
+}
+
+// Unwrap returns the error of each failed path
+func (m *multiError) Unwrap() []error {
+	var errs = make([]error, 0, len(m.errors))
+	for _, err := range m.errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMultiError(t *testing.T) {
+	Convey("An operation without failures", t, func() {
+		merr := &multiError{total: 2}
+		Convey("Should not return an error", func() {
+			So(merr.errorOrNil(), ShouldBeNil)
+		})
+	})
+
+	Convey("An operation with failures", t, func() {
+		boom := fmt.Errorf("boom")
+		merr := &multiError{total: 3}
+		merr.add("c", boom)
+		merr.add("a", ErrorDownloadTooLarge)
+		err := merr.errorOrNil()
+		So(err, ShouldNotBeNil)
+		Convey("Should list the failures by path", func() {
+			So(err.Error(), ShouldEqual, fmt.Sprintf("2 of 3 operations failed: a: %v; c: boom", ErrorDownloadTooLarge))
+		})
+		Convey("Should unwrap to the error of each path", func() {
+			errs := err.(*multiError).Unwrap()
+			So(errs, ShouldHaveLength, 2)
+			So(errs[0].(*pathError).Unwrap(), ShouldEqual, ErrorDownloadTooLarge)
+			So(errs[1].(*pathError).Unwrap(), ShouldEqual, boom)
+		})
+	})
+}
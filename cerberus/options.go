@@ -0,0 +1,394 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ClientOption is used to customize a Client when calling NewClient
+type ClientOption func(*Client)
+
+// WithIdempotencyKeys enables sending an Idempotency-Key header on secure file uploads.
+// The key is generated once per Put call so that a resent upload is not stored twice
+func WithIdempotencyKeys(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.idempotencyKeys = enabled
+	}
+}
+
+// WithCreateDirs controls whether SecureFile.GetToFile creates the missing parent directories of
+// the local file. By default, downloading into a directory that does not exist is an error
+func WithCreateDirs(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.createDirs = enabled
+	}
+}
+
+// WithLogger sets a Logger used to log the size of request and response bodies
+func WithLogger(l Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// WithDownloadDir sets the directory SecureFile.Download saves files in. An explicit local path given to
+// SecureFile.GetToFile still takes precedence. When not set, files are saved in the current directory
+func WithDownloadDir(dir string) ClientOption {
+	return func(c *Client) {
+		c.downloadDir = dir
+	}
+}
+
+// DefaultDownloadFileMode is the mode of the local files secure files are downloaded to, readable and
+// writable by the current user only since secure files usually hold sensitive data
+const DefaultDownloadFileMode os.FileMode = 0600
+
+// WithDownloadFileMode sets the mode of the local files written by SecureFile.GetToFile, Download, GetIfNewer
+// and GetDir, DefaultDownloadFileMode by default. The mode is set on existing files they overwrite too, and is
+// not reduced by the umask of the process. Before this option, files were created with 0666 minus the umask
+func WithDownloadFileMode(mode os.FileMode) ClientOption {
+	return func(c *Client) {
+		c.downloadFileMode = mode
+	}
+}
+
+// WithBulkDelete allows SecureFile.DeletePrefix to delete all files under a path. It is disabled by default
+// to avoid deleting many files by accident
+func WithBulkDelete(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.bulkDelete = enabled
+	}
+}
+
+// WithJSONDecoder sets a function called on every JSON decoder used to parse API responses. For example,
+// passing func(d *json.Decoder) { d.UseNumber() } decodes numbers into interface{} values as json.Number
+// instead of float64, which avoids losing precision on large integers at the cost of having to convert
+// them. Fields with a concrete type, like integers in the api package, are not affected by UseNumber.
+// Secrets are read through the Vault client and are not affected by this option
+func WithJSONDecoder(configure func(*json.Decoder)) ClientOption {
+	return func(c *Client) {
+		c.configureDecoder = configure
+	}
+}
+
+// WithMaxRetries sets how many times a request is sent again when the response has a retryable status code
+// (see WithRetryableStatusCodes). Retries wait 100ms, doubling after each attempt. Requests are not
+// retried by default. Requests whose body cannot be replayed are never retried
+func WithMaxRetries(n int) ClientOption {
+	return func(c *Client) {
+		c.maxRetries = n
+	}
+}
+
+// WithRetryableStatusCodes replaces the status codes that are retried when retries are enabled. The
+// default is DefaultRetryableStatusCodes (500, 502, 503 and 504)
+func WithRetryableStatusCodes(codes ...int) ClientOption {
+	return func(c *Client) {
+		c.retryableStatusCodes = statusCodeSet(codes)
+	}
+}
+
+// WithRetryPredicate sets a predicate which can retry requests the default rules would not, for example
+// because of the error_id of an error response or a connection error, when there is no response and resp
+// is nil. It is called when the status code is not retryable, and requests are still retried at most as
+// many times as set with WithMaxRetries. The predicate must be side-effect-free and safe for concurrent use.
+// It can read the body of error responses (status 400 and above), which is buffered and restored for the
+// caller, but must not read or close other bodies
+func WithRetryPredicate(fn RetryPredicate) ClientOption {
+	return func(c *Client) {
+		c.retryPredicate = fn
+	}
+}
+
+const (
+	// DefaultLimitParam is the default name of the query parameter for the page size
+	DefaultLimitParam = "limit"
+	// DefaultOffsetParam is the default name of the query parameter for the page offset
+	DefaultOffsetParam = "offset"
+)
+
+// WithPaginationParams sets the names of the query parameters used to request a page size and offset
+// on paginated endpoints (secure file and metadata listing). The defaults are DefaultLimitParam and
+// DefaultOffsetParam
+func WithPaginationParams(limitParam, offsetParam string) ClientOption {
+	return func(c *Client) {
+		c.limitParam = limitParam
+		c.offsetParam = offsetParam
+	}
+}
+
+// WithMaxDownloadBytes limits the size of secure file downloads. Larger downloads fail with ErrorDownloadTooLarge.
+// The limit is enforced while reading, so it also applies when the server does not send a Content-Length
+func WithMaxDownloadBytes(n int64) ClientOption {
+	return func(c *Client) {
+		c.maxDownloadBytes = n
+	}
+}
+
+// WithMaxResponseBytes limits the size of the JSON responses parsed by the client, such as secure file and
+// metadata lists. Larger responses fail with ErrorResponseTooLarge instead of being read into memory. Downloads
+// are limited separately with WithMaxDownloadBytes, and secrets are read with the Vault client, which is not limited
+func WithMaxResponseBytes(n int64) ClientOption {
+	return func(c *Client) {
+		c.maxResponseBytes = n
+	}
+}
+
+// WithProgress sets a function called as secure files are downloaded to report progress
+func WithProgress(fn ProgressFunc) ClientOption {
+	return func(c *Client) {
+		c.progress = fn
+	}
+}
+
+// WithTransferMetrics sets a function called at the end of each secure file upload and download with the
+// number of bytes of file content transferred, for example to feed capacity planning or detect unexpectedly
+// large transfers. Multipart encoding and compression are not counted. Nothing is reported by default
+func WithTransferMetrics(fn TransferMetricsFunc) ClientOption {
+	return func(c *Client) {
+		c.transferMetrics = fn
+	}
+}
+
+// WithCurlLogging logs every request as a curl command that can be used to replay it, using the Logger set
+// with WithLogger. Authentication headers are redacted. File uploads are shown as a reference to a local
+// file with the same name. Request bodies are included, so this should only be used for debugging
+func WithCurlLogging(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.curlLogging = enabled
+	}
+}
+
+// WithSecurePathPrefix sets a prefix prepended to the secure file paths given to the SecureFile client, such as
+// the path of the SDB all operations are done in. Paths starting with AbsoluteSecurePathMarker ("//") are used
+// as is. The prefix and the path are joined with path.Join, so the result is cleaned: duplicate slashes are
+// removed and ".." elements are resolved, which means a path starting with ".." can point outside the prefix.
+// Paths in the summaries returned by the server are full paths, which include the prefix
+func WithSecurePathPrefix(prefix string) ClientOption {
+	return func(c *Client) {
+		c.securePathPrefix = prefix
+	}
+}
+
+// WithVerifyAfterUpload controls whether uploads are verified by downloading the file back and comparing its
+// SHA-256 with the one of the uploaded content. Put returns ErrorUploadVerificationFailed if they differ.
+// This doubles the bandwidth used by uploads, so it is disabled by default
+func WithVerifyAfterUpload(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.verifyAfterUpload = enabled
+	}
+}
+
+// WithMaxUploadBytes sets the maximum size in bytes of uploaded secure files. Uploads of larger files are
+// rejected with ErrorUploadTooLarge before anything is sent. The size of files is checked with Stat, and the
+// content of other readers is counted while it is read. 0, the default, disables the limit
+func WithMaxUploadBytes(max int64) ClientOption {
+	return func(c *Client) {
+		c.maxUploadBytes = max
+	}
+}
+
+// WithTreatMissingAsEmpty controls whether listing secure files in a folder which does not exist (404) returns
+// no files instead of an error. Other errors are still returned
+func WithTreatMissingAsEmpty(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.treatMissingAsEmpty = enabled
+	}
+}
+
+// WithDialTimeout sets the maximum time spent establishing a connection to Cerberus, DefaultDialTimeout if not
+// set. It only bounds connecting, not the whole request, so unreachable servers fail fast even when transfers
+// can take long. Secrets are read with the Vault client, which uses its own settings
+func WithDialTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.dialTimeout = timeout
+	}
+}
+
+// WithUploadCompression controls whether secure file uploads are gzip compressed and sent with a
+// Content-Encoding: gzip header, which saves bandwidth on large text files. It is disabled by default and
+// must only be enabled if the server, or a proxy in front of it, accepts compressed requests. Content which is
+// already compressed, like images or archives based on the Content-Type of the file, and bodies which would not
+// get smaller are sent as is
+func WithUploadCompression(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.compressUploads = enabled
+	}
+}
+
+// WithSharedTransport sets the transport used to send requests, so many clients can share the same connections.
+// By default, clients created with the same WithDialTimeout, WithDisableKeepAlives and WithHTTP2 options already
+// share a transport, so creating a client per operation doesn't open new connections each time. This option is
+// for sharing a transport with other HTTP clients of the application, or for tuning it, such as
+// MaxIdleConnsPerHost for many concurrent requests. The transport is used as is: WithDialTimeout,
+// WithDisableKeepAlives and WithHTTP2 have no effect. Secrets are read with the Vault client, which uses its own
+// transport
+func WithSharedTransport(t *http.Transport) ClientOption {
+	return func(c *Client) {
+		c.transport = t
+	}
+}
+
+// WithDisableKeepAlives controls whether connections are closed after each request instead of being kept
+// open for the next ones. This lets short-lived processes, such as one-shot CLI commands, exit without
+// lingering connections, but every request then pays for connecting and the TLS handshake, which hurts
+// throughput when many requests are made. It is meant for short-lived processes only. Secrets are read with
+// the Vault client, which uses its own settings
+func WithDisableKeepAlives(disabled bool) ClientOption {
+	return func(c *Client) {
+		c.disableKeepAlives = disabled
+	}
+}
+
+// WithHTTP2 sets whether requests are sent with HTTP/2 (see HTTP2ALPN and HTTP2PriorKnowledge). HTTP/2 sends
+// concurrent requests over a single connection per host, which saves connecting and TLS handshakes for
+// services making many concurrent small requests. The tradeoff is that large secure file uploads and downloads
+// share that connection: a lost packet stalls every request on it and a large transfer can slow down the small
+// requests sent meanwhile. Options limiting idle connections don't apply to HTTP/2 connections. Secrets are
+// read with the Vault client, which uses its own transport
+func WithHTTP2(mode HTTP2Mode) ClientOption {
+	return func(c *Client) {
+		c.http2 = mode
+	}
+}
+
+// WithUTF8Validation controls whether Secret.Write rejects data containing strings, or map keys, which are not
+// valid UTF-8. Such values usually come from binary data written as a string by mistake, and get mangled when they
+// are encoded as JSON. Binary data should be encoded with base64 instead. Writes are not validated by default
+func WithUTF8Validation(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.validateUTF8 = enabled
+	}
+}
+
+// WithSDBIndexTTL sets how long the SDBs listed to resolve SDB paths, such as by SDB.SplitSDBPath, are cached
+// before being listed again. The default is DefaultSDBIndexTTL. A TTL of 0 lists the SDBs every time. See
+// RefreshSDBIndex to refresh the cache on demand
+func WithSDBIndexTTL(ttl time.Duration) ClientOption {
+	return func(c *Client) {
+		c.sdbIndexTTL = ttl
+	}
+}
+
+// WithStrictEnvValues controls whether Secret.ReadAsEnv fails on secret values which are not strings, such as
+// numbers or nested objects, instead of encoding them as JSON
+func WithStrictEnvValues(strict bool) ClientOption {
+	return func(c *Client) {
+		c.strictEnvValues = strict
+	}
+}
+
+// WithNormalizeLineEndings controls whether GetText replaces Windows (\r\n) and old Mac (\r) line endings by \n
+func WithNormalizeLineEndings(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.normalizeLineEndings = enabled
+	}
+}
+
+// WithRefreshOnUnauthorized controls whether a request failing with a 401 is sent once more after refreshing the
+// token. Concurrent requests failing with the same expired token only cause one refresh, and all of them are
+// retried with the new token. Requests whose body cannot be replayed are never retried
+func WithRefreshOnUnauthorized(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.refreshOnUnauthorized = enabled
+	}
+}
+
+const (
+	// DefaultJSONAccept is the default Accept header of requests to JSON endpoints
+	DefaultJSONAccept = "application/json"
+	// DefaultDownloadAccept is the default Accept header of secure file downloads
+	DefaultDownloadAccept = "application/octet-stream"
+)
+
+// WithAcceptHeaders sets the Accept headers sent to JSON endpoints and when downloading secure files, which
+// override the one of the authentication method. An empty value keeps the default, DefaultJSONAccept or
+// DefaultDownloadAccept. Sending an explicit Accept header avoids getting another representation, such as an
+// HTML error page from a proxy
+func WithAcceptHeaders(jsonAccept, downloadAccept string) ClientOption {
+	return func(c *Client) {
+		if jsonAccept != "" {
+			c.jsonAccept = jsonAccept
+		}
+		if downloadAccept != "" {
+			c.downloadAccept = downloadAccept
+		}
+	}
+}
+
+// WithHost sets the Host header of requests, such as the name a shared ingress routes on, while still connecting
+// to the address of the Cerberus URL. By default the Host header is the host of the URL. Secrets are read with
+// the Vault client, which always uses the host of the URL
+func WithHost(host string) ClientOption {
+	return func(c *Client) {
+		c.host = host
+	}
+}
+
+// WithCorrelationIDs makes every request carry a new random correlation ID in the given header, or in
+// DefaultCorrelationIDHeader if it is empty. The ID is included in the request and response lines logged
+// with WithLogger, which ties them to the logs of the server. An ID set with ContextWithCorrelationID is
+// sent instead of a new one. Secrets are read with the Vault client, which does not send correlation IDs
+func WithCorrelationIDs(header string) ClientOption {
+	return func(c *Client) {
+		c.correlationIDs = true
+		if header != "" {
+			c.correlationIDHeader = header
+		}
+	}
+}
+
+// WithAutoRefreshErrorHandler sets a function called with the error of each failed background token refresh,
+// see StartAutoRefresh. It is called from the refresh goroutine, so it should not block
+func WithAutoRefreshErrorHandler(fn func(error)) ClientOption {
+	return func(c *Client) {
+		c.autoRefreshErrorHandler = fn
+	}
+}
+
+// WithTokenNearExpiry sets a function called before each request sent while the token expires within threshold,
+// with its remaining validity, so interactive tools can ask the user to log in again before a long operation
+// fails. The TTL of each new token is looked up once, and tokens which do not expire are never reported.
+// Secrets are read with the Vault client, whose requests are not checked
+func WithTokenNearExpiry(threshold time.Duration, fn TokenNearExpiryFunc) ClientOption {
+	return func(c *Client) {
+		c.tokenNearExpiryThreshold = threshold
+		c.tokenNearExpiry = fn
+	}
+}
+
+// WithFollowRedirects controls whether redirects are followed. By default they are not: a redirect response is
+// returned as a *RedirectError with its Location, since following it can drop the authentication headers or
+// land on a login page. Secrets are read with the Vault client, which uses its own settings
+func WithFollowRedirects(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.followRedirects = enabled
+	}
+}
+
+// WithClock sets the Clock used for everything depending on time, such as the wait between retries, background
+// token refreshes, Watch and the expiry of the SDB cache. It is meant for tests, to make these behaviors fast
+// and deterministic. The token expiry tracked by the authentication methods still uses the time package
+func WithClock(clock Clock) ClientOption {
+	return func(c *Client) {
+		c.clock = clock
+	}
+}
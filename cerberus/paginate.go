@@ -0,0 +1,103 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Nike-Inc/cerberus-go-client/api"
+)
+
+// maxPageErrorBody is the most of an error response body kept by pageStatusError
+const maxPageErrorBody = 64 * 1024
+
+// pageError is returned by paginate when a page cannot be requested, or is answered with another status
+// than 200, so callers can describe the failure in their own terms
+type pageError struct {
+	// err is the error of the request, if it could not be sent
+	err        error
+	statusCode int
+	// body is the start of the body of the response, for callers parsing API errors
+	body []byte
+}
+
+func (e *pageError) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+	return fmt.Sprintf("Got HTTP status code %d", e.statusCode)
+}
+
+// paginate gets all the pages of a paged endpoint, starting at offset 0, and calls each with the body of
+// every page in order until a page has has_next false. The offset parameter is set by paginate and is
+// added to query, which should hold the page size. Failed requests and responses with another status than
+// 200 stop paging with a *pageError. If each returns an error, paging stops and the error is returned. The
+// context error is returned if the context is done
+func (c *Client) paginate(ctx context.Context, p string, query map[string]string, each func(json.RawMessage) error) error {
+	var cursor api.PageCursor = "0"
+	for {
+		var params = make(map[string]string, len(query)+1)
+		for k, v := range query {
+			params[k] = v
+		}
+		params[c.offsetParam] = string(cursor)
+		raw, err := c.getPage(ctx, p, params)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		var page api.Page
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return fmt.Errorf("Error while decoding page: %v", err)
+		}
+		if err := each(raw); err != nil {
+			return err
+		}
+		if !page.HasNext {
+			return nil
+		}
+		cursor = nextCursor(page.NextCursor, page.NextOffset)
+	}
+}
+
+// getPage returns the body of a single page
+func (c *Client) getPage(ctx context.Context, p string, params map[string]string) (json.RawMessage, error) {
+	resp, err := c.doJSONRequest(ctx, http.MethodGet, p, params, nil)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, &pageError{err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxPageErrorBody))
+		return nil, &pageError{statusCode: resp.StatusCode, body: body}
+	}
+	// Decoding into a RawMessage checks that the reply is valid JSON
+	var raw json.RawMessage
+	if err := c.decodeResponse(resp.Body, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// pagesServer serves pages of a single number, following numeric and opaque cursors, and records the
+// query of every request
+func pagesServer(queries *[]map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var query = map[string]string{}
+		for k := range r.URL.Query() {
+			query[k] = r.URL.Query().Get(k)
+		}
+		*queries = append(*queries, query)
+		w.Header().Set("Content-Type", "application/json")
+		switch query["offset"] {
+		case "0":
+			fmt.Fprint(w, `{"has_next": true, "next_offset": 1, "n": 0}`)
+		case "1":
+			fmt.Fprint(w, `{"has_next": true, "next_offset": "opaque", "n": 1}`)
+		case "opaque":
+			fmt.Fprint(w, `{"has_next": false, "n": 2}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error_id": "bad-offset"}`)
+		}
+	}))
+}
+
+func TestPaginate(t *testing.T) {
+	Convey("A paged endpoint", t, func() {
+		var queries []map[string]string
+		ts := pagesServer(&queries)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		var numbers []int
+		collect := func(raw json.RawMessage) error {
+			var page struct{ N int }
+			if err := json.Unmarshal(raw, &page); err != nil {
+				return err
+			}
+			numbers = append(numbers, page.N)
+			return nil
+		}
+		Convey("Should get every page", func() {
+			err := cl.paginate(context.Background(), "/v1/pages", map[string]string{"limit": "1"}, collect)
+			So(err, ShouldBeNil)
+			So(numbers, ShouldResemble, []int{0, 1, 2})
+			So(queries, ShouldResemble, []map[string]string{
+				{"limit": "1", "offset": "0"},
+				{"limit": "1", "offset": "1"},
+				{"limit": "1", "offset": "opaque"},
+			})
+		})
+		Convey("Should stop when the callback fails", func() {
+			stop := fmt.Errorf("stop")
+			err := cl.paginate(context.Background(), "/v1/pages", map[string]string{}, func(json.RawMessage) error {
+				return stop
+			})
+			So(err, ShouldEqual, stop)
+			So(queries, ShouldHaveLength, 1)
+		})
+		Convey("Should stop when the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			err := cl.paginate(ctx, "/v1/pages", map[string]string{}, func(raw json.RawMessage) error {
+				cancel()
+				return nil
+			})
+			So(err, ShouldEqual, context.Canceled)
+			So(queries, ShouldHaveLength, 1)
+		})
+		Convey("Should return the status and body of a failed page", func() {
+			cl.offsetParam = "page"
+			err := cl.paginate(context.Background(), "/v1/pages", map[string]string{}, collect)
+			pageErr, ok := err.(*pageError)
+			So(ok, ShouldBeTrue)
+			So(pageErr.statusCode, ShouldEqual, http.StatusBadRequest)
+			So(string(pageErr.body), ShouldEqual, `{"error_id": "bad-offset"}`)
+			So(numbers, ShouldBeEmpty)
+		})
+	})
+
+	Convey("An unreachable endpoint", t, func() {
+		ts := httptest.NewServer(http.NotFoundHandler())
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		ts.Close()
+		Convey("Should return the request error", func() {
+			err := cl.paginate(context.Background(), "/v1/pages", map[string]string{}, func(json.RawMessage) error {
+				return nil
+			})
+			pageErr, ok := err.(*pageError)
+			So(ok, ShouldBeTrue)
+			So(pageErr.err, ShouldNotBeNil)
+		})
+	})
+}
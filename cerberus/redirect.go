@@ -0,0 +1,60 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// RedirectError is returned when Cerberus answers with a redirect and the client was not created
+// with WithFollowRedirects(true). Location is where the response redirected to
+type RedirectError struct {
+	StatusCode int
+	Location   string
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("Cerberus redirected the request to %q with HTTP status code %d. Redirects are not followed unless the client is created with WithFollowRedirects(true)",
+		e.Location, e.StatusCode)
+}
+
+// checkRedirect is the http.Client.CheckRedirect of the client. Unless redirects are followed, the
+// redirect response is returned as is so it can be turned into a RedirectError
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if !c.followRedirects {
+		return http.ErrUseLastResponse
+	}
+	// Same limit as the default policy of net/http
+	if len(via) >= 10 {
+		return fmt.Errorf("stopped after 10 redirects")
+	}
+	return nil
+}
+
+// redirectError returns a RedirectError if resp is a redirect which was not followed. The body is then
+// drained and closed so the connection can be reused
+func (c *Client) redirectError(resp *http.Response) error {
+	if c.followRedirects || resp.StatusCode < 300 || resp.StatusCode >= 400 || resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	return &RedirectError{StatusCode: resp.StatusCode, Location: resp.Header.Get("Location")}
+}
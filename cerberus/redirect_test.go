@@ -0,0 +1,55 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRedirects(t *testing.T) {
+	Convey("A gateway redirecting to a login page", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/login" {
+				w.Write([]byte("<html>Log in</html>"))
+				return
+			}
+			http.Redirect(w, r, "/login", http.StatusFound)
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		Convey("Should return a RedirectError by default", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", nil, nil)
+			So(resp, ShouldBeNil)
+			So(err, ShouldResemble, &RedirectError{StatusCode: http.StatusFound, Location: "/login"})
+			So(err.Error(), ShouldContainSubstring, `"/login"`)
+		})
+		Convey("Should follow the redirect when enabled", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithFollowRedirects(true))
+			resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", nil, nil)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Request.URL.Path, ShouldEqual, "/login")
+		})
+	})
+}
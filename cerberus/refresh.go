@@ -0,0 +1,134 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// tokenLookupSelfPath returns the details of the token used for the request
+var tokenLookupSelfPath = "/v1/auth/token/lookup-self"
+
+// noRefreshKey is the context key disabling token refreshes for a request
+type noRefreshKey struct{}
+
+// withoutRefresh returns a context whose requests never refresh the token
+func withoutRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRefreshKey{}, true)
+}
+
+// refreshAllowed returns whether the token can be refreshed for a request sent with ctx
+func refreshAllowed(ctx context.Context) bool {
+	disabled, _ := ctx.Value(noRefreshKey{}).(bool)
+	return !disabled
+}
+
+// ValidateToken returns whether Cerberus still accepts the current token by looking it up, which has no
+// side effects: the token is not refreshed, even if WithRefreshOnUnauthorized is enabled or the server
+// asks for it. A rejected token returns false without an error
+func (c *Client) ValidateToken(ctx context.Context) (bool, error) {
+	resp, err := c.doJSONRequest(withoutRefresh(ctx), http.MethodGet, tokenLookupSelfPath, map[string]string{}, nil)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return false, fmt.Errorf("Error while validating token: %v", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	// Vault rejects unknown tokens with a 403
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return false, nil
+	default:
+		return false, fmt.Errorf("Error while validating token. Got HTTP status code %d", resp.StatusCode)
+	}
+}
+
+// authHeaders returns a copy of the headers of the authentication method, so per request values
+// don't leak into the ones it holds
+func (c *Client) authHeaders() (http.Header, error) {
+	c.authLock.RLock()
+	defer c.authLock.RUnlock()
+	headers, err := c.Authentication.GetHeaders()
+	if err != nil {
+		return nil, err
+	}
+	var copied = make(http.Header, len(headers))
+	for k, v := range headers {
+		copied[k] = append([]string(nil), v...)
+	}
+	return copied, nil
+}
+
+// currentToken returns the latest token of the authentication method. Getting a token can authenticate
+// again, so the lock is held for writing
+func (c *Client) currentToken() (string, error) {
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+	return c.Authentication.GetToken(nil)
+}
+
+// refreshToken refreshes the token of the authentication method and returns the new one, along with the
+// error of the refresh if it failed. If the token is no longer stale, another request refreshed it in the
+// meantime and it is returned without refreshing again, so concurrent requests failing with the same
+// expired token cause a single refresh
+func (c *Client) refreshToken(stale string) (string, error) {
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+	if tok, err := c.Authentication.GetToken(nil); err == nil && tok != stale {
+		return tok, nil
+	}
+	refreshErr := c.Authentication.Refresh()
+	tok, err := c.Authentication.GetToken(nil)
+	if err != nil {
+		return "", err
+	}
+	return tok, refreshErr
+}
+
+// retryUnauthorized refreshes the token and sends the request again with the new token if it failed
+// with a 401 and WithRefreshOnUnauthorized is enabled. The original response is returned if the request
+// cannot be retried or the refresh fails
+func (c *Client) retryUnauthorized(req *http.Request, resp *http.Response) (*http.Response, error) {
+	if !c.refreshOnUnauthorized || resp.StatusCode != http.StatusUnauthorized || !refreshAllowed(req.Context()) {
+		return resp, nil
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+	tok, err := c.refreshToken(req.Header.Get("X-Vault-Token"))
+	if err != nil {
+		return resp, nil
+	}
+	// Drain the body so the connection can be reused
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = body
+	}
+	req.Header.Set("X-Vault-Token", tok)
+	return c.send(req)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// expiringTokenServer rejects every request which does not use the refreshed token
+func expiringTokenServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != refreshedToken {
+			// Keep the failing requests in flight long enough to overlap
+			time.Sleep(10 * time.Millisecond)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestRefreshOnUnauthorized(t *testing.T) {
+	Convey("A server rejecting an expired token", t, func() {
+		ts := expiringTokenServer()
+		defer ts.Close()
+		Convey("Should return the 401 by default", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", nil, nil)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+		})
+		Convey("Should retry with the refreshed token when enabled", func() {
+			m := GenerateMockAuth(ts.URL, "a-cool-token", false, false)
+			cl, _ := NewClient(m, nil, WithRefreshOnUnauthorized(true))
+			resp, err := cl.DoRequestWithBody(http.MethodPut, "/v1/blah", nil, "text/plain", strings.NewReader("a body"))
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.refreshes, ShouldEqual, 1)
+			r, err := cl.Secret().newRequest(http.MethodGet, "app/sdb/db")
+			So(err, ShouldBeNil)
+			So(r.ClientToken, ShouldEqual, refreshedToken)
+		})
+		Convey("Should return the 401 if the refresh fails", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, true), nil, WithRefreshOnUnauthorized(true))
+			resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", nil, nil)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+		})
+		Convey("Should refresh once for concurrent requests", func() {
+			m := GenerateMockAuth(ts.URL, "a-cool-token", false, false)
+			cl, _ := NewClient(m, nil, WithRefreshOnUnauthorized(true))
+			var wg sync.WaitGroup
+			var codes = make([]int, 20)
+			var errs = make([]error, 20)
+			for i := range codes {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", nil, nil)
+					errs[i] = err
+					if err == nil {
+						codes[i] = resp.StatusCode
+						resp.Body.Close()
+					}
+				}(i)
+			}
+			wg.Wait()
+			for i := range codes {
+				So(errs[i], ShouldBeNil)
+				So(codes[i], ShouldEqual, http.StatusOK)
+			}
+			So(m.refreshes, ShouldEqual, 1)
+		})
+	})
+
+	Convey("A retried request", t, func() {
+		f := &flakyServer{failures: 1, failCode: http.StatusServiceUnavailable}
+		ts := httptest.NewServer(f)
+		defer ts.Close()
+		m := GenerateMockAuth(ts.URL, "a-cool-token", false, false)
+		cl := newRetryTestClient(ts.URL, WithMaxRetries(1))
+		cl.Authentication = m
+		Convey("Should use the latest token on the retry", func() {
+			m.token = refreshedToken
+			resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", nil, nil)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(f.headers[0].Get("X-Vault-Token"), ShouldEqual, "a-cool-token")
+			So(f.headers[1].Get("X-Vault-Token"), ShouldEqual, refreshedToken)
+		})
+	})
+}
+
+func TestValidateToken(t *testing.T) {
+	Convey("A server looking up tokens", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/auth/token/lookup-self" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			switch r.Header.Get("X-Vault-Token") {
+			case "a-cool-token":
+				w.Header().Set("X-Refresh-Token", "true")
+				w.Write([]byte(`{"data": {"id": "a-cool-token"}}`))
+			case "a-broken-token":
+				w.WriteHeader(http.StatusInternalServerError)
+			default:
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		Convey("Should accept a valid token without refreshing it", func() {
+			m := GenerateMockAuth(ts.URL, "a-cool-token", false, false)
+			cl, _ := NewClient(m, nil)
+			valid, err := cl.ValidateToken(context.Background())
+			So(err, ShouldBeNil)
+			So(valid, ShouldBeTrue)
+			So(m.refreshes, ShouldEqual, 0)
+		})
+		Convey("Should reject an expired token without refreshing it", func() {
+			m := GenerateMockAuth(ts.URL, "an-expired-token", false, false)
+			cl, _ := NewClient(m, nil, WithRefreshOnUnauthorized(true))
+			valid, err := cl.ValidateToken(context.Background())
+			So(err, ShouldBeNil)
+			So(valid, ShouldBeFalse)
+			So(m.refreshes, ShouldEqual, 0)
+		})
+		Convey("Should error on a server error", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-broken-token", false, false), nil)
+			valid, err := cl.ValidateToken(context.Background())
+			So(err, ShouldNotBeNil)
+			So(valid, ShouldBeFalse)
+		})
+	})
+}
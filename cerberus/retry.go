@@ -0,0 +1,132 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// DefaultRetryableStatusCodes are the status codes retried when retries are enabled with WithMaxRetries
+var DefaultRetryableStatusCodes = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// defaultRetryWait is the delay before the first retry. It doubles on every following retry
+const defaultRetryWait = 100 * time.Millisecond
+
+// statusCodeSet builds a lookup set from a list of status codes
+func statusCodeSet(codes []int) map[int]bool {
+	var set = make(map[int]bool, len(codes))
+	for _, code := range codes {
+		set[code] = true
+	}
+	return set
+}
+
+// makeRewindable lets a request with a seekable body be retried by seeking back to where the body started.
+// Bodies which net/http can already replay are left alone. The body is not closed after the request,
+// so it can be read again
+func makeRewindable(req *http.Request, body io.Reader) {
+	seeker, ok := body.(io.ReadSeeker)
+	if !ok || req.GetBody != nil {
+		return
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	req.Body = ioutil.NopCloser(seeker)
+	req.GetBody = func() (io.ReadCloser, error) {
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(seeker), nil
+	}
+}
+
+// RetryPredicate decides whether a request is sent again given its response, or the error when there is no
+// response. See WithRetryPredicate
+type RetryPredicate func(resp *http.Response, err error) bool
+
+// shouldRetry returns whether the request which got resp or err should be retried, either because of its
+// status code or because the predicate set with WithRetryPredicate says so. The body of an error response
+// is buffered before calling the predicate, so the predicate can read it and it is still complete afterwards
+func (c *Client) shouldRetry(resp *http.Response, err error) bool {
+	if err == nil && c.retryableStatusCodes[resp.StatusCode] {
+		return true
+	}
+	if c.retryPredicate == nil {
+		return false
+	}
+	if resp == nil || resp.StatusCode < http.StatusBadRequest {
+		return c.retryPredicate(resp, err)
+	}
+	content, readErr := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(content))
+	if readErr != nil {
+		return false
+	}
+	retry := c.retryPredicate(resp, err)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(content))
+	return retry
+}
+
+// send performs the request and retries it, up to the configured number of retries, while the
+// response has a retryable status code or the retry predicate matches. Requests with a body that cannot
+// be replayed or rewound are not retried
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.httpClient.Do(req)
+		if attempt >= c.maxRetries || !c.shouldRetry(resp, err) {
+			return resp, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			return resp, err
+		}
+		if resp != nil {
+			// Drain the body so the connection can be reused
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-c.clock.After(c.retryWait << uint(attempt)):
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		// The token may have been refreshed by another request while waiting
+		if req.Header.Get("X-Vault-Token") != "" {
+			if tok, err := c.currentToken(); err == nil && tok != "" {
+				req.Header.Set("X-Vault-Token", tok)
+			}
+		}
+	}
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// flakyServer fails the given number of requests with failCode before succeeding with successCode (200 if not set)
+type flakyServer struct {
+	lock        sync.Mutex
+	failures    int
+	failCode    int
+	successCode int
+	requests    int
+	bodies      []string
+	headers     []http.Header
+}
+
+func (f *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	f.bodies = append(f.bodies, string(body))
+	f.headers = append(f.headers, r.Header)
+	f.requests++
+	if f.requests <= f.failures {
+		w.WriteHeader(f.failCode)
+		return
+	}
+	if f.successCode != 0 {
+		w.WriteHeader(f.successCode)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// newRetryTestClient returns a client with short retry waits
+func newRetryTestClient(url string, opts ...ClientOption) *Client {
+	cl, _ := NewClient(GenerateMockAuth(url, "a-cool-token", false, false), nil, opts...)
+	cl.retryWait = time.Millisecond
+	return cl
+}
+
+func TestRetries(t *testing.T) {
+	Convey("A server failing twice with a retryable status", t, func() {
+		server := &flakyServer{failures: 2, failCode: http.StatusServiceUnavailable}
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		Convey("Should succeed when enough retries are allowed", func() {
+			cl := newRetryTestClient(ts.URL, WithMaxRetries(2))
+			resp, err := cl.DoRequest(http.MethodPost, "/v1/blah", map[string]string{}, map[string]string{"a": "b"})
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(server.requests, ShouldEqual, 3)
+			Convey("And should replay the body on every attempt", func() {
+				So(server.bodies[2], ShouldEqual, server.bodies[0])
+				So(server.bodies[0], ShouldContainSubstring, `"a":"b"`)
+			})
+		})
+		Convey("Should return the failure when retries run out", func() {
+			cl := newRetryTestClient(ts.URL, WithMaxRetries(1))
+			resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+			So(server.requests, ShouldEqual, 2)
+		})
+		Convey("Should not retry by default", func() {
+			cl := newRetryTestClient(ts.URL)
+			resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+			So(server.requests, ShouldEqual, 1)
+		})
+	})
+
+	Convey("A server failing with a custom status", t, func() {
+		server := &flakyServer{failures: 1, failCode: 598}
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		Convey("Should not retry it by default", func() {
+			cl := newRetryTestClient(ts.URL, WithMaxRetries(2))
+			resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, 598)
+		})
+		Convey("Should retry it when configured", func() {
+			cl := newRetryTestClient(ts.URL, WithMaxRetries(2), WithRetryableStatusCodes(598))
+			resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		})
+	})
+
+	Convey("A retried request with a seekable body", t, func() {
+		server := &flakyServer{failures: 2, failCode: http.StatusServiceUnavailable}
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl := newRetryTestClient(ts.URL, WithMaxRetries(2))
+		Convey("Should rewind the body from where it started on every attempt", func() {
+			f, err := ioutil.TempFile("", "cerberus-retry")
+			So(err, ShouldBeNil)
+			defer os.Remove(f.Name())
+			defer f.Close()
+			f.WriteString("skipped-hello")
+			f.Seek(int64(len("skipped-")), io.SeekStart)
+			resp, err := cl.DoRequestWithBody(http.MethodPost, "/v1/blah", map[string]string{}, "text/plain", f)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(server.bodies, ShouldResemble, []string{"hello", "hello", "hello"})
+		})
+		Convey("Should not retry a body which cannot be rewound", func() {
+			resp, err := cl.DoRequestWithBody(http.MethodPost, "/v1/blah", map[string]string{}, "text/plain",
+				ioutil.NopCloser(strings.NewReader("hello")))
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+			So(server.requests, ShouldEqual, 1)
+		})
+	})
+
+	Convey("A retried upload with idempotency keys", t, func() {
+		server := &flakyServer{failures: 1, failCode: http.StatusBadGateway, successCode: http.StatusNoContent}
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl := newRetryTestClient(ts.URL, WithMaxRetries(1), WithIdempotencyKeys(true))
+		Convey("Should reuse the same key", func() {
+			err := cl.SecureFile().Put("/test/file/hello.txt", "hello.txt", getTestInputReader(t, "hello"))
+			So(err, ShouldBeNil)
+			So(server.requests, ShouldEqual, 2)
+			So(server.headers[0].Get(idempotencyKeyHeader), ShouldNotBeEmpty)
+			So(server.headers[1].Get(idempotencyKeyHeader), ShouldEqual, server.headers[0].Get(idempotencyKeyHeader))
+		})
+	})
+}
+
+func TestRetryPredicate(t *testing.T) {
+	Convey("A server failing with a retryable error ID", t, func() {
+		var lock sync.Mutex
+		var requests int
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			defer lock.Unlock()
+			requests++
+			w.WriteHeader(http.StatusConflict)
+			if requests == 1 {
+				w.Write([]byte(`{"error_id": "lock-timeout"}`))
+				return
+			}
+			w.Write([]byte(`{"error_id": "conflict"}`))
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		isLockTimeout := func(resp *http.Response, err error) bool {
+			if err != nil {
+				return false
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			return strings.Contains(string(body), "lock-timeout")
+		}
+		Convey("Should retry while the predicate matches and keep the final body", func() {
+			cl := newRetryTestClient(ts.URL, WithMaxRetries(3), WithRetryPredicate(isLockTimeout))
+			resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusConflict)
+			body, _ := ioutil.ReadAll(resp.Body)
+			So(string(body), ShouldEqual, `{"error_id": "conflict"}`)
+			So(requests, ShouldEqual, 2)
+		})
+		Convey("Should return the complete body when retries are exhausted", func() {
+			cl := newRetryTestClient(ts.URL, WithRetryPredicate(isLockTimeout))
+			resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			body, _ := ioutil.ReadAll(resp.Body)
+			So(string(body), ShouldEqual, `{"error_id": "lock-timeout"}`)
+			So(requests, ShouldEqual, 1)
+		})
+		Convey("Should not retry without a predicate", func() {
+			cl := newRetryTestClient(ts.URL, WithMaxRetries(3))
+			_, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			So(requests, ShouldEqual, 1)
+		})
+	})
+
+	Convey("A server dropping the first connection", t, func() {
+		var lock sync.Mutex
+		var requests int
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			requests++
+			first := requests == 1
+			lock.Unlock()
+			if first {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		Convey("Should retry connection errors matched by the predicate", func() {
+			cl := newRetryTestClient(ts.URL, WithMaxRetries(1), WithRetryPredicate(func(resp *http.Response, err error) bool {
+				return err != nil
+			}))
+			resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(requests, ShouldEqual, 2)
+		})
+	})
+}
@@ -44,7 +44,7 @@ func (r *Role) List() ([]*api.Role, error) {
 		return nil, fmt.Errorf("Error while trying to GET roles. Got HTTP status code %d", resp.StatusCode)
 	}
 	var roleList = []*api.Role{}
-	err = parseResponse(resp.Body, &roleList)
+	err = r.c.decodeResponse(resp.Body, &roleList)
 	if err != nil {
 		return nil, err
 	}
@@ -17,6 +17,7 @@ limitations under the License.
 package cerberus
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
@@ -54,6 +55,33 @@ func (s *SDB) GetByName(name string) (*api.SafeDepositBox, error) {
 	return nil, ErrorSafeDepositBoxNotFound
 }
 
+// SplitSDBPath is a helper method that finds the SDB a full secret or secure file path, such as
+// "app/my-sdb/db/password", belongs to among the SDBs the client has access to. It returns the path of the
+// SDB and the path relative to it, such as "app/my-sdb" and "db/password", without leading or trailing
+// slashes. If several SDB paths match, the longest one is used. The SDBs are cached, see RefreshSDBIndex.
+// Returns ErrorSafeDepositBoxNotFound if no SDB matches
+func (s *SDB) SplitSDBPath(fullPath string) (string, string, error) {
+	target := strings.Trim(fullPath, "/")
+	allSDB, err := s.c.indexedSDBs(context.Background())
+	if err != nil {
+		return "", "", err
+	}
+	var sdbPath string
+	for _, v := range allSDB {
+		p := strings.Trim(v.Path, "/")
+		if p == "" || len(p) <= len(sdbPath) {
+			continue
+		}
+		if target == p || strings.HasPrefix(target, p+"/") {
+			sdbPath = p
+		}
+	}
+	if sdbPath == "" {
+		return "", "", ErrorSafeDepositBoxNotFound
+	}
+	return sdbPath, strings.TrimPrefix(strings.TrimPrefix(target, sdbPath), "/"), nil
+}
+
 // Get returns a single SDB given an ID. Returns ErrorSafeDepositBoxNotFound
 // if the ID does not exist
 func (s *SDB) Get(id string) (*api.SafeDepositBox, error) {
@@ -75,7 +103,7 @@ func (s *SDB) Get(id string) (*api.SafeDepositBox, error) {
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("Error while trying to GET SDB. Got HTTP status code %d", resp.StatusCode)
 	}
-	err = parseResponse(resp.Body, returnedSDB)
+	err = s.c.decodeResponse(resp.Body, returnedSDB)
 	if err != nil {
 		return nil, err
 	}
@@ -84,8 +112,13 @@ func (s *SDB) Get(id string) (*api.SafeDepositBox, error) {
 
 // List returns a list of all SDBs the authenticated user is allowed to see
 func (s *SDB) List() ([]*api.SafeDepositBox, error) {
+	return s.list(context.Background())
+}
+
+// list is List with a context
+func (s *SDB) list(ctx context.Context) ([]*api.SafeDepositBox, error) {
 	sdbList := []*api.SafeDepositBox{}
-	resp, err := s.c.DoRequest(http.MethodGet, sdbBasePath, map[string]string{}, nil)
+	resp, err := s.c.doJSONRequest(ctx, http.MethodGet, sdbBasePath, map[string]string{}, nil)
 	if resp != nil {
 		defer resp.Body.Close()
 	}
@@ -96,7 +129,7 @@ func (s *SDB) List() ([]*api.SafeDepositBox, error) {
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("Error while trying to GET SDB list. Got HTTP status code %d", resp.StatusCode)
 	}
-	err = parseResponse(resp.Body, &sdbList)
+	err = s.c.decodeResponse(resp.Body, &sdbList)
 	if err != nil {
 		return nil, err
 	}
@@ -107,7 +140,7 @@ func (s *SDB) List() ([]*api.SafeDepositBox, error) {
 func (s *SDB) Create(newSDB *api.SafeDepositBox) (*api.SafeDepositBox, error) {
 	// Create the object we are returning
 	createdSDB := &api.SafeDepositBox{}
-	resp, err := s.c.DoRequest(http.MethodPost, sdbBasePath, map[string]string{}, newSDB)
+	resp, err := s.c.DoRequestWithJSON(http.MethodPost, sdbBasePath, map[string]string{}, newSDB)
 	if resp != nil {
 		defer resp.Body.Close()
 	}
@@ -127,8 +160,9 @@ func (s *SDB) Create(newSDB *api.SafeDepositBox) (*api.SafeDepositBox, error) {
 		}
 		return nil, apiErr
 	}
+	s.c.invalidateSDBIndex()
 	// Parse the created object
-	err = parseResponse(resp.Body, createdSDB)
+	err = s.c.decodeResponse(resp.Body, createdSDB)
 	if err != nil {
 		return nil, err
 	}
@@ -144,7 +178,7 @@ func (s *SDB) Update(id string, updatedSDB *api.SafeDepositBox) (*api.SafeDeposi
 		return nil, ErrorSafeDepositBoxNotFound
 	}
 	returnedSDB := &api.SafeDepositBox{}
-	resp, err := s.c.DoRequest(http.MethodPut, sdbBasePath+"/"+id, map[string]string{}, updatedSDB)
+	resp, err := s.c.DoRequestWithJSON(http.MethodPut, sdbBasePath+"/"+id, map[string]string{}, updatedSDB)
 	if resp != nil {
 		defer resp.Body.Close()
 	}
@@ -166,8 +200,9 @@ func (s *SDB) Update(id string, updatedSDB *api.SafeDepositBox) (*api.SafeDeposi
 		}
 		return nil, apiErr
 	}
+	s.c.invalidateSDBIndex()
 	// Parse the updated object
-	err = parseResponse(resp.Body, returnedSDB)
+	err = s.c.decodeResponse(resp.Body, returnedSDB)
 	if err != nil {
 		return nil, err
 	}
@@ -199,5 +234,6 @@ func (s *SDB) Delete(id string) error {
 		}
 		return apiErr
 	}
+	s.c.invalidateSDBIndex()
 	return nil
 }
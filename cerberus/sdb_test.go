@@ -304,6 +304,44 @@ func TestGetByName(t *testing.T) {
 	})
 }
 
+func TestSplitSDBPath(t *testing.T) {
+	var validResponse = `[
+		{"id": "a", "path": "app/web/"},
+		{"id": "b", "path": "app/web-admin/"},
+		{"id": "c", "path": "shared/onelogin"}
+	]`
+
+	Convey("A valid call to SplitSDBPath", t, WithTestServer(http.StatusOK, "/v2/safe-deposit-box", http.MethodGet, validResponse, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should split the path at the SDB path", func() {
+			sdbPath, relPath, err := cl.SDB().SplitSDBPath("/app/web-admin/db/password")
+			So(err, ShouldBeNil)
+			So(sdbPath, ShouldEqual, "app/web-admin")
+			So(relPath, ShouldEqual, "db/password")
+		})
+		Convey("Should accept the path of the SDB itself", func() {
+			sdbPath, relPath, err := cl.SDB().SplitSDBPath("shared/onelogin/")
+			So(err, ShouldBeNil)
+			So(sdbPath, ShouldEqual, "shared/onelogin")
+			So(relPath, ShouldEqual, "")
+		})
+		Convey("Should return an error if no SDB matches", func() {
+			_, _, err := cl.SDB().SplitSDBPath("app/webs/db")
+			So(err, ShouldEqual, ErrorSafeDepositBoxNotFound)
+		})
+	}))
+
+	Convey("A call to SplitSDBPath that encounters a server error", t, WithTestServer(http.StatusInternalServerError, "/v2/safe-deposit-box", http.MethodGet, validResponse, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return an error", func() {
+			_, _, err := cl.SDB().SplitSDBPath("app/web/db")
+			So(err, ShouldNotBeNil)
+		})
+	}))
+}
+
 func TestCreateSDB(t *testing.T) {
 	var id = "a7d703da-faac-11e5-a8a9-7fa3b294cd46"
 	var validResponse = `{
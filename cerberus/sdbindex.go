@@ -0,0 +1,77 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Nike-Inc/cerberus-go-client/api"
+)
+
+// DefaultSDBIndexTTL is how long the SDBs listed for SplitSDBPath are cached by default
+const DefaultSDBIndexTTL = 5 * time.Minute
+
+// sdbIndex caches the SDBs the token has access to. It is shared by a client and the clients scoped
+// with WithSDB
+type sdbIndex struct {
+	lock   sync.Mutex
+	boxes  []*api.SafeDepositBox
+	loaded time.Time
+}
+
+// RefreshSDBIndex lists the SDBs again to update the cache used to resolve SDB paths, such as by
+// SDB.SplitSDBPath. The cache is refreshed automatically once it is older than the TTL set with
+// WithSDBIndexTTL, and after an SDB is created, updated or deleted with this client, so this is only needed
+// to see changes made by other clients right away. The cache is left as is if listing fails
+func (c *Client) RefreshSDBIndex(ctx context.Context) error {
+	c.sdbIndex.lock.Lock()
+	defer c.sdbIndex.lock.Unlock()
+	return c.loadSDBIndex(ctx)
+}
+
+// loadSDBIndex lists the SDBs into the cache. The lock of the cache must be held
+func (c *Client) loadSDBIndex(ctx context.Context) error {
+	boxes, err := c.SDB().list(ctx)
+	if err != nil {
+		return err
+	}
+	c.sdbIndex.boxes = boxes
+	c.sdbIndex.loaded = c.clock.Now()
+	return nil
+}
+
+// indexedSDBs returns the cached SDBs, listing them first if the cache is empty or expired. Concurrent
+// callers wait for a single listing
+func (c *Client) indexedSDBs(ctx context.Context) ([]*api.SafeDepositBox, error) {
+	c.sdbIndex.lock.Lock()
+	defer c.sdbIndex.lock.Unlock()
+	if c.sdbIndex.boxes == nil || c.clock.Now().Sub(c.sdbIndex.loaded) >= c.sdbIndexTTL {
+		if err := c.loadSDBIndex(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return c.sdbIndex.boxes, nil
+}
+
+// invalidateSDBIndex empties the cache, so the SDBs are listed again the next time they are needed
+func (c *Client) invalidateSDBIndex() {
+	c.sdbIndex.lock.Lock()
+	defer c.sdbIndex.lock.Unlock()
+	c.sdbIndex.boxes = nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Nike-Inc/cerberus-go-client/api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// sdbListServer serves a list of SDBs, counting the list requests, and accepts SDB creations
+func sdbListServer(lists *int, lock *sync.Mutex) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "new", "path": "app/new/"}`))
+			return
+		}
+		lock.Lock()
+		*lists++
+		lock.Unlock()
+		w.Write([]byte(`[{"id": "a", "path": "app/web/"}]`))
+	}))
+}
+
+func TestSDBIndex(t *testing.T) {
+	Convey("A client resolving SDB paths", t, func() {
+		var lock sync.Mutex
+		var lists int
+		ts := sdbListServer(&lists, &lock)
+		Reset(func() {
+			ts.Close()
+		})
+		count := func() int {
+			lock.Lock()
+			defer lock.Unlock()
+			return lists
+		}
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		split := func(c *Client) {
+			sdbPath, _, err := c.SDB().SplitSDBPath("app/web/db")
+			So(err, ShouldBeNil)
+			So(sdbPath, ShouldEqual, "app/web")
+		}
+		Convey("Should list the SDBs once", func() {
+			split(cl)
+			split(cl)
+			split(cl.WithSDB("app/web"))
+			So(count(), ShouldEqual, 1)
+		})
+		Convey("Should list the SDBs once for concurrent calls", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					cl.SDB().SplitSDBPath("app/web/db")
+				}()
+			}
+			wg.Wait()
+			So(count(), ShouldEqual, 1)
+		})
+		Convey("Should list the SDBs again when refreshed", func() {
+			split(cl)
+			So(cl.RefreshSDBIndex(context.Background()), ShouldBeNil)
+			split(cl)
+			So(count(), ShouldEqual, 2)
+		})
+		Convey("Should list the SDBs again after creating one", func() {
+			split(cl)
+			_, err := cl.SDB().Create(&api.SafeDepositBox{Name: "new"})
+			So(err, ShouldBeNil)
+			split(cl)
+			So(count(), ShouldEqual, 2)
+		})
+		Convey("Should list the SDBs every time without a TTL", func() {
+			uncached, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithSDBIndexTTL(0))
+			split(uncached)
+			split(uncached)
+			So(count(), ShouldEqual, 2)
+		})
+	})
+
+	Convey("A client which cannot list SDBs", t, WithTestServer(http.StatusInternalServerError, "/v2/safe-deposit-box", http.MethodGet, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return an error when refreshing", func() {
+			So(cl.RefreshSDBIndex(context.Background()), ShouldNotBeNil)
+		})
+	}))
+}
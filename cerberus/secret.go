@@ -17,36 +17,389 @@ limitations under the License.
 package cerberus
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Nike-Inc/cerberus-go-client/api"
 	vault "github.com/hashicorp/vault/api"
 )
 
-// Note: This is not tested because it is a simple wrapper on top of Vault, which has its own tests
-
 // Secret wraps the vault.Logical client to make sure all paths are prefaced
 // with "secret". This does not expose Unwrap because it will not work with
-// Cerberus' path routing
+// Cerberus' path routing. Requests are sent with the current token of the
+// authentication method, which may have changed since the client was created
 type Secret struct {
-	v *vault.Logical
+	c *Client
+	// sdbPath is prepended to all paths when the client is scoped to an SDB (see Client.WithSDB)
+	sdbPath string
 }
 
 const pathPrefix = "secret/"
 
+// fullPath returns the vault path of the secret at the given path
+func (s *Secret) fullPath(path string) string {
+	if s.sdbPath == "" {
+		return pathPrefix + path
+	}
+	return pathPrefix + strings.Trim(s.sdbPath, "/") + "/" + path
+}
+
+// newRequest returns a request of the Vault client for the secret at the given path, like vault.Logical. The
+// token is set on the request rather than on the Vault client, which is shared by concurrent requests
+func (s *Secret) newRequest(method, path string) (*vault.Request, error) {
+	tok, err := s.c.currentToken()
+	if err != nil {
+		return nil, err
+	}
+	r := s.c.vaultClient.NewRequest(method, "/v1/"+s.fullPath(path))
+	r.ClientToken = tok
+	return r, nil
+}
+
 // Delete deletes the given path. Path should not be prefaced with a "/"
 func (s *Secret) Delete(path string) (*vault.Secret, error) {
-	return s.v.Delete(pathPrefix + path)
+	r, err := s.newRequest(http.MethodDelete, path)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.c.vaultClient.RawRequest(r)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return vault.ParseSecret(resp.Body)
+	}
+	return nil, nil
 }
 
 // List lists secrets at the given path. Path should not be prefaced with a "/"
 func (s *Secret) List(path string) (*vault.Secret, error) {
-	return s.v.List(pathPrefix + path)
+	r, err := s.newRequest(http.MethodGet, path)
+	if err != nil {
+		return nil, err
+	}
+	r.Params.Set("list", "true")
+	return s.readSecret(r)
 }
 
 // Read returns the secret at the given path. Path should not be prefaced with a "/"
 func (s *Secret) Read(path string) (*vault.Secret, error) {
-	return s.v.Read(pathPrefix + path)
+	r, err := s.newRequest(http.MethodGet, path)
+	if err != nil {
+		return nil, err
+	}
+	return s.readSecret(r)
+}
+
+// readSecret sends a read or list request, returning nil if nothing exists at its path
+func (s *Secret) readSecret(r *vault.Request) (*vault.Secret, error) {
+	resp, err := s.c.vaultClient.RawRequest(r)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return vault.ParseSecret(resp.Body)
+}
+
+// ErrorSecretKeyNotFound is returned when a secret, or the requested key of a secret, does not exist
+var ErrorSecretKeyNotFound = fmt.Errorf("Unable to find secret key")
+
+// ReadKey returns the value of a single key of the secret at the given path. String values are returned as
+// is and other values as JSON. Returns ErrorSecretKeyNotFound if the secret or the key does not exist.
+// Path should not be prefaced with a "/"
+func (s *Secret) ReadKey(path, key string) (string, error) {
+	secret, err := s.Read(path)
+	if err != nil {
+		return "", err
+	}
+	if secret == nil {
+		return "", ErrorSecretKeyNotFound
+	}
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", ErrorSecretKeyNotFound
+	}
+	if str, ok := value.(string); ok {
+		return str, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("Error while encoding secret key %s: %v", key, err)
+	}
+	return string(encoded), nil
 }
 
-// Write creates a new secret at the given path. Path should not be prefaced with a "/"
+// ReadBinaryKey returns the base64 decoded value of a single key of the secret at the given path, for binary
+// values stored with WriteBinaryKey. Returns ErrorSecretKeyNotFound if the secret or the key does not exist.
+// Path should not be prefaced with a "/"
+func (s *Secret) ReadBinaryKey(path, key string) ([]byte, error) {
+	value, err := s.ReadKey(path, key)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading secret key %s: the value is not valid base64: %v", key, err)
+	}
+	return decoded, nil
+}
+
+// WriteBinaryKey stores value base64 encoded under a single key of the secret at the given path, keeping
+// the other keys of the secret like Patch. Path should not be prefaced with a "/"
+func (s *Secret) WriteBinaryKey(path, key string, value []byte) error {
+	return s.Patch(path, map[string]interface{}{
+		key: base64.StdEncoding.EncodeToString(value),
+	})
+}
+
+// Write creates a new secret at the given path. Path should not be prefaced with a "/". The data is encoded
+// as JSON with the keys of all maps sorted, so the same data always produces the same request body. If the
+// client was created using WithUTF8Validation(true), data with strings which are not valid UTF-8 is rejected
 func (s *Secret) Write(path string, data map[string]interface{}) (*vault.Secret, error) {
-	return s.v.Write(pathPrefix+path, data)
+	if s.c.validateUTF8 {
+		if key, ok := invalidUTF8Key("", data); ok {
+			return nil, fmt.Errorf("Error while writing secret %s: the value of %s is not valid UTF-8. Encode binary data with base64", path, key)
+		}
+	}
+	// The body is encoded here rather than by vault.Logical, whose encoder ends it with a newline, so the
+	// request body is exactly the sorted JSON encoding of data
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("Error while encoding secret %s: %v", path, err)
+	}
+	r, err := s.newRequest(http.MethodPut, path)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = bytes.NewReader(body)
+	r.BodySize = int64(len(body))
+	resp, err := s.c.vaultClient.RawRequest(r)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return vault.ParseSecret(resp.Body)
+	}
+	return nil, nil
+}
+
+// invalidUTF8Key returns the key, prefixed with the keys of its parents, of the first string in value that is
+// not valid UTF-8, looking into nested maps and lists. Map keys are checked too
+func invalidUTF8Key(prefix string, value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return prefix, !utf8.ValidString(v)
+	case map[string]interface{}:
+		var keys = make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			if !utf8.ValidString(k) {
+				return key, true
+			}
+			if bad, ok := invalidUTF8Key(key, v[k]); ok {
+				return bad, true
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if bad, ok := invalidUTF8Key(fmt.Sprintf("%s[%d]", prefix, i), item); ok {
+				return bad, true
+			}
+		}
+	case []string:
+		for i, item := range v {
+			if !utf8.ValidString(item) {
+				return fmt.Sprintf("%s[%d]", prefix, i), true
+			}
+		}
+	}
+	return "", false
+}
+
+var secretVersionsBasePath = "/v1/secret-versions"
+
+// ErrorSecretVersionNotFound is returned when a specified version of a secret does not exist
+var ErrorSecretVersionNotFound = fmt.Errorf("Unable to find secret version")
+
+// Versions returns the version history of the secret at the given path, oldest first. Path should not be prefaced with a "/"
+func (s *Secret) Versions(secretPath string) ([]api.SecretVersion, error) {
+	var versions = []api.SecretVersion{}
+	err := s.c.paginate(context.Background(),
+		path.Join(secretVersionsBasePath, strings.TrimPrefix(s.fullPath(secretPath), pathPrefix)),
+		map[string]string{
+			s.c.limitParam: fmt.Sprintf("%d", listPageSize),
+		},
+		func(raw json.RawMessage) error {
+			page := &api.SecretVersionsResponse{}
+			if err := s.c.decodeResponse(bytes.NewReader(raw), page); err != nil {
+				return err
+			}
+			versions = append(versions, page.Versions...)
+			return nil
+		})
+	if pageErr, ok := err.(*pageError); ok {
+		if pageErr.err != nil {
+			return nil, fmt.Errorf("Error while getting secret versions: %v", pageErr.err)
+		}
+		return nil, fmt.Errorf("Error while getting secret versions. Got HTTP status code %d", pageErr.statusCode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].VersionCreated.Before(versions[j].VersionCreated)
+	})
+	for i := range versions {
+		versions[i].Version = i + 1
+	}
+	return versions, nil
+}
+
+// ErrorVersionConflict is returned by conditional writes and deletes when the secret or secure file changed since the expected version
+var ErrorVersionConflict = fmt.Errorf("Unable to complete request: the secret or secure file changed since the expected version")
+
+// DeleteIfVersion deletes the secret at the given path only if its latest version, as numbered by Versions, is
+// expectedVersion. It returns ErrorVersionConflict otherwise. Cerberus has no conditional delete, so the version
+// is checked right before deleting and a change made in between is not detected. Path should not be prefaced with a "/"
+func (s *Secret) DeleteIfVersion(secretPath string, expectedVersion int) error {
+	versions, err := s.Versions(secretPath)
+	if err != nil {
+		return err
+	}
+	if len(versions) != expectedVersion {
+		return ErrorVersionConflict
+	}
+	_, err = s.Delete(secretPath)
+	return err
+}
+
+// WriteCAS writes data to the secret at the given path only if its latest version, as numbered by Versions, is
+// expectedVersion, which is 0 for a secret without any history. It returns ErrorVersionConflict otherwise.
+// Cerberus has no compare-and-swap, so the version is checked right before writing: a write made by another
+// process in between is overwritten without being detected. Path should not be prefaced with a "/"
+func (s *Secret) WriteCAS(secretPath string, data map[string]interface{}, expectedVersion int) error {
+	versions, err := s.Versions(secretPath)
+	if err != nil {
+		return err
+	}
+	if len(versions) != expectedVersion {
+		return ErrorVersionConflict
+	}
+	_, err = s.Write(secretPath, data)
+	return err
+}
+
+// deletedKey is the type of DeleteSecretKey, so it cannot be mistaken for a value decoded from JSON
+type deletedKey struct{}
+
+// DeleteSecretKey is used as the value of a key given to Patch or PatchCAS to remove the key from the secret
+var DeleteSecretKey interface{} = deletedKey{}
+
+// Patch merges updates into the secret at the given path: the keys of updates are added or replaced, keys whose
+// value is DeleteSecretKey are removed and the other keys are kept. The secret is created if it does not exist.
+// The secret is read and written again, so a change made by another process in between is lost, see PatchCAS.
+// Path should not be prefaced with a "/"
+func (s *Secret) Patch(secretPath string, updates map[string]interface{}) error {
+	secret, err := s.Read(secretPath)
+	if err != nil {
+		return err
+	}
+	_, err = s.Write(secretPath, mergeSecret(secret, updates))
+	return err
+}
+
+// PatchCAS merges updates into the secret at the given path like Patch, writing it with WriteCAS to the version
+// it was read at. It returns ErrorVersionConflict if the secret changed since it was read, in which case
+// the patch can be applied again. Path should not be prefaced with a "/"
+func (s *Secret) PatchCAS(secretPath string, updates map[string]interface{}) error {
+	versions, err := s.Versions(secretPath)
+	if err != nil {
+		return err
+	}
+	secret, err := s.Read(secretPath)
+	if err != nil {
+		return err
+	}
+	return s.WriteCAS(secretPath, mergeSecret(secret, updates), len(versions))
+}
+
+// mergeSecret returns a copy of the data of secret, which may be nil, with updates applied as described in Patch
+func mergeSecret(secret *vault.Secret, updates map[string]interface{}) map[string]interface{} {
+	var data = map[string]interface{}{}
+	if secret != nil {
+		for k, v := range secret.Data {
+			data[k] = v
+		}
+	}
+	for k, v := range updates {
+		if v == DeleteSecretKey {
+			delete(data, k)
+			continue
+		}
+		data[k] = v
+	}
+	return data
+}
+
+// ReadVersion returns the data of the secret at the given path as it was in the given version, as numbered
+// by Versions. Returns ErrorSecretVersionNotFound if there is no such version. Path should not be prefaced with a "/"
+func (s *Secret) ReadVersion(secretPath string, version int) (map[string]interface{}, error) {
+	versions, err := s.Versions(secretPath)
+	if err != nil {
+		return nil, err
+	}
+	if version < 1 || version > len(versions) {
+		return nil, ErrorSecretVersionNotFound
+	}
+	resp, err := s.c.DoRequest(http.MethodGet,
+		"/v1/"+s.fullPath(secretPath),
+		map[string]string{
+			"versionId": versions[version-1].ID,
+		},
+		nil)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading secret version: %v", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrorSecretVersionNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error while reading secret version. Got HTTP status code %d", resp.StatusCode)
+	}
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := s.c.decodeResponse(resp.Body, &secret); err != nil {
+		return nil, err
+	}
+	return secret.Data, nil
 }
@@ -0,0 +1,493 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Nike-Inc/cerberus-go-client/api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// secretVersionsServer serves a history of two pages, newest first, and the data of each version
+func secretVersionsServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/secret-versions/app/sdb/db":
+			if r.URL.Query().Get("offset") == "0" {
+				w.Write([]byte(`{
+					"has_next": true,
+					"next_offset": 1,
+					"secure_data_version_summaries": [ {
+						"id": "version-c",
+						"path": "app/sdb/db",
+						"action": "UPDATE",
+						"version_created_by": "leia",
+						"version_created_ts": "2018-06-03T10:00:00Z"
+					} ]
+				}`))
+				return
+			}
+			w.Write([]byte(`{
+				"has_next": false,
+				"secure_data_version_summaries": [ {
+					"id": "version-b",
+					"path": "app/sdb/db",
+					"action": "UPDATE",
+					"version_created_by": "han",
+					"version_created_ts": "2018-06-02T10:00:00Z"
+				}, {
+					"id": "version-a",
+					"path": "app/sdb/db",
+					"action": "CREATE",
+					"version_created_by": "luke",
+					"version_created_ts": "2018-06-01T10:00:00Z"
+				} ]
+			}`))
+		case "/v1/secret/app/sdb/db":
+			switch id := r.URL.Query().Get("versionId"); id {
+			case "version-a", "version-b", "version-c":
+				fmt.Fprintf(w, `{"data": {"password": "%s"}}`, id)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestSecretVersions(t *testing.T) {
+	Convey("A secret with several versions", t, func() {
+		ts := secretVersionsServer()
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return the history oldest first", func() {
+			versions, err := cl.Secret().Versions("app/sdb/db")
+			So(err, ShouldBeNil)
+			So(versions, ShouldHaveLength, 3)
+			So(versions[0].Version, ShouldEqual, 1)
+			So(versions[0].ID, ShouldEqual, "version-a")
+			So(versions[0].VersionCreatedBy, ShouldEqual, "luke")
+			So(versions[0].Action, ShouldEqual, "CREATE")
+			So(versions[2].Version, ShouldEqual, 3)
+			So(versions[2].ID, ShouldEqual, "version-c")
+			So(versions[1].VersionCreated.Before(versions[2].VersionCreated), ShouldBeTrue)
+		})
+		Convey("Should read a given version", func() {
+			data, err := cl.Secret().ReadVersion("app/sdb/db", 2)
+			So(err, ShouldBeNil)
+			So(data["password"], ShouldEqual, "version-b")
+		})
+		Convey("Should read versions relative to a scoped SDB", func() {
+			data, err := cl.WithSDB("app/sdb").Secret().ReadVersion("db", 1)
+			So(err, ShouldBeNil)
+			So(data["password"], ShouldEqual, "version-a")
+		})
+		Convey("Should return ErrorSecretVersionNotFound for an unknown version", func() {
+			_, err := cl.Secret().ReadVersion("app/sdb/db", 4)
+			So(err, ShouldEqual, ErrorSecretVersionNotFound)
+			_, err = cl.Secret().ReadVersion("app/sdb/db", 0)
+			So(err, ShouldEqual, ErrorSecretVersionNotFound)
+		})
+		Convey("Should error for a secret without history", func() {
+			_, err := cl.Secret().Versions("app/sdb/missing")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestSecretReadKey(t *testing.T) {
+	var secretResponse = `{"data": {"password": "hunter2", "port": 5432, "hosts": ["a", "b"]}}`
+
+	Convey("A valid call to ReadKey", t, WithTestServer(http.StatusOK, "/v1/secret/app/sdb/db", http.MethodGet, secretResponse, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return a string value as is", func() {
+			value, err := cl.Secret().ReadKey("app/sdb/db", "password")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "hunter2")
+		})
+		Convey("Should return other values as JSON", func() {
+			port, err := cl.Secret().ReadKey("app/sdb/db", "port")
+			So(err, ShouldBeNil)
+			So(port, ShouldEqual, "5432")
+			hosts, err := cl.Secret().ReadKey("app/sdb/db", "hosts")
+			So(err, ShouldBeNil)
+			So(hosts, ShouldEqual, `["a","b"]`)
+		})
+		Convey("Should return an error for a missing key", func() {
+			_, err := cl.Secret().ReadKey("app/sdb/db", "username")
+			So(err, ShouldEqual, ErrorSecretKeyNotFound)
+		})
+	}))
+
+	Convey("A call to ReadKey for a missing secret", t, WithTestServer(http.StatusNotFound, "/v1/secret/app/sdb/db", http.MethodGet, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return an error", func() {
+			_, err := cl.Secret().ReadKey("app/sdb/db", "password")
+			So(err, ShouldEqual, ErrorSecretKeyNotFound)
+		})
+	}))
+}
+
+func TestSecretRotatedToken(t *testing.T) {
+	Convey("An authentication method whose token changes without a refresh", t, func() {
+		var tokens []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokens = append(tokens, r.Header.Get("X-Vault-Token"))
+			if r.Method == http.MethodGet {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		m := GenerateMockAuth(ts.URL, "a-cool-token", false, false)
+		cl, _ := NewClient(m, nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should send the current token with every secret request", func() {
+			_, err := cl.Secret().Read("app/sdb/db")
+			So(err, ShouldBeNil)
+			// Like an auth.AuthProvider handing out a new token
+			m.token = "a-rotated-token"
+			_, err = cl.Secret().Read("app/sdb/db")
+			So(err, ShouldBeNil)
+			_, err = cl.Secret().Write("app/sdb/db", map[string]interface{}{"a": "b"})
+			So(err, ShouldBeNil)
+			_, err = cl.Secret().List("app/sdb")
+			So(err, ShouldBeNil)
+			_, err = cl.Secret().Delete("app/sdb/db")
+			So(err, ShouldBeNil)
+			So(tokens, ShouldResemble, []string{"a-cool-token", "a-rotated-token", "a-rotated-token", "a-rotated-token", "a-rotated-token"})
+		})
+	})
+}
+
+func TestSecretConcurrentRotation(t *testing.T) {
+	Convey("Secrets read while the token is refreshed", t, func() {
+		var lock sync.Mutex
+		var tokens = map[string]int{}
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			tokens[r.Header.Get("X-Vault-Token")]++
+			lock.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data": {"password": "hunter2"}}`))
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should each be sent with the old or the new token", func() {
+			var wg sync.WaitGroup
+			var errs = make(chan error, 100)
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 10; j++ {
+						_, err := cl.Secret().Read("app/sdb/db")
+						errs <- err
+					}
+				}()
+			}
+			// Refreshing the refreshed token again makes every call refresh
+			stale := "a-cool-token"
+			for i := 0; i < 10; i++ {
+				_, err := cl.refreshToken(stale)
+				So(err, ShouldBeNil)
+				stale = refreshedToken
+				time.Sleep(time.Millisecond)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				So(err, ShouldBeNil)
+			}
+			_, err := cl.Secret().Read("app/sdb/db")
+			So(err, ShouldBeNil)
+			lock.Lock()
+			defer lock.Unlock()
+			So(tokens["a-cool-token"]+tokens[refreshedToken], ShouldEqual, 101)
+			So(tokens[refreshedToken], ShouldBeGreaterThan, 0)
+		})
+	})
+}
+
+func TestSecretWriteBody(t *testing.T) {
+	Convey("Writing the same secret twice", t, func() {
+		var bodies []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		data := map[string]interface{}{
+			"zone":     "us-west-2",
+			"password": "hunter2",
+			"db":       map[string]interface{}{"port": 5432, "host": "db.local", "name": "app"},
+			"admin":    "bob",
+		}
+		Convey("Should send the same body with sorted keys", func() {
+			for i := 0; i < 5; i++ {
+				_, err := cl.Secret().Write("app/sdb/db", data)
+				So(err, ShouldBeNil)
+			}
+			So(bodies, ShouldHaveLength, 5)
+			for _, body := range bodies {
+				So(body, ShouldEqual, `{"admin":"bob","db":{"host":"db.local","name":"app","port":5432},"password":"hunter2","zone":"us-west-2"}`)
+			}
+		})
+	})
+}
+
+func TestSecretWriteUTF8Validation(t *testing.T) {
+	Convey("Writing a secret with invalid UTF-8", t, func() {
+		var requests int
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		data := map[string]interface{}{
+			"name": "valid",
+			"tls":  map[string]interface{}{"hosts": []interface{}{"a.local", "b\xff.local"}},
+		}
+		Convey("Should be sent by default", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			_, err := cl.Secret().Write("app/sdb/db", data)
+			So(err, ShouldBeNil)
+			So(requests, ShouldEqual, 1)
+		})
+		Convey("Should be rejected when validation is enabled", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithUTF8Validation(true))
+			_, err := cl.Secret().Write("app/sdb/db", data)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "tls.hosts[1] is not valid UTF-8")
+			So(err.Error(), ShouldContainSubstring, "base64")
+			So(requests, ShouldEqual, 0)
+		})
+		Convey("Should reject invalid keys when validation is enabled", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithUTF8Validation(true))
+			_, err := cl.Secret().Write("app/sdb/db", map[string]interface{}{"\xfe": "value"})
+			So(err, ShouldNotBeNil)
+			So(requests, ShouldEqual, 0)
+		})
+		Convey("Should accept valid data when validation is enabled", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithUTF8Validation(true))
+			_, err := cl.Secret().Write("app/sdb/db", map[string]interface{}{"name": "héllo wörld ✓", "port": 5432})
+			So(err, ShouldBeNil)
+			So(requests, ShouldEqual, 1)
+		})
+	})
+}
+
+func TestSecretWriteCAS(t *testing.T) {
+	Convey("A secret with several versions", t, func() {
+		var written map[string]interface{}
+		versions := secretVersionsServer()
+		target, _ := url.Parse(versions.URL)
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPut && r.URL.Path == "/v1/secret/app/sdb/db" {
+				json.NewDecoder(r.Body).Decode(&written)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			proxy.ServeHTTP(w, r)
+		}))
+		Reset(func() {
+			ts.Close()
+			versions.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should write it at the expected version", func() {
+			So(cl.Secret().WriteCAS("app/sdb/db", map[string]interface{}{"password": "hunter2"}, 3), ShouldBeNil)
+			So(written, ShouldResemble, map[string]interface{}{"password": "hunter2"})
+		})
+		Convey("Should return ErrorVersionConflict at another version", func() {
+			So(cl.Secret().WriteCAS("app/sdb/db", map[string]interface{}{"password": "hunter2"}, 2), ShouldEqual, ErrorVersionConflict)
+			So(written, ShouldBeNil)
+		})
+	})
+}
+
+func TestSecretDeleteIfVersion(t *testing.T) {
+	Convey("A secret with several versions", t, func() {
+		var deletes int
+		versions := secretVersionsServer()
+		target, _ := url.Parse(versions.URL)
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodDelete && r.URL.Path == "/v1/secret/app/sdb/db" {
+				deletes++
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			proxy.ServeHTTP(w, r)
+		}))
+		Reset(func() {
+			ts.Close()
+			versions.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should delete it at the expected version", func() {
+			So(cl.Secret().DeleteIfVersion("app/sdb/db", 3), ShouldBeNil)
+			So(deletes, ShouldEqual, 1)
+		})
+		Convey("Should return ErrorVersionConflict at another version", func() {
+			So(cl.Secret().DeleteIfVersion("app/sdb/db", 2), ShouldEqual, ErrorVersionConflict)
+			So(deletes, ShouldEqual, 0)
+		})
+		Convey("Should error for a secret without history", func() {
+			So(cl.Secret().DeleteIfVersion("app/sdb/missing", 0), ShouldNotBeNil)
+			So(deletes, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestSecretBinaryKey(t *testing.T) {
+	Convey("A secret with binary values", t, func() {
+		fake := &fakeSDBServer{
+			secrets: map[string]map[string]interface{}{
+				"app/sdb/keys": {"user": "admin", "signing": "AAEC/w==", "broken": "not base64!"},
+			},
+			files: newFakeSecureFileServer(map[string]string{}),
+		}
+		ts := httptest.NewServer(fake)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should decode a base64 value", func() {
+			value, err := cl.Secret().ReadBinaryKey("app/sdb/keys", "signing")
+			So(err, ShouldBeNil)
+			So(value, ShouldResemble, []byte{0, 1, 2, 255})
+		})
+		Convey("Should return an error for a value that is not base64", func() {
+			_, err := cl.Secret().ReadBinaryKey("app/sdb/keys", "broken")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "not valid base64")
+		})
+		Convey("Should return an error for a missing key", func() {
+			_, err := cl.Secret().ReadBinaryKey("app/sdb/keys", "missing")
+			So(err, ShouldEqual, ErrorSecretKeyNotFound)
+		})
+		Convey("Should encode a value and keep the other keys", func() {
+			So(cl.Secret().WriteBinaryKey("app/sdb/keys", "signing", []byte{255, 0}), ShouldBeNil)
+			So(fake.secrets["app/sdb/keys"]["signing"], ShouldEqual, "/wA=")
+			So(fake.secrets["app/sdb/keys"]["user"], ShouldEqual, "admin")
+			value, err := cl.Secret().ReadBinaryKey("app/sdb/keys", "signing")
+			So(err, ShouldBeNil)
+			So(value, ShouldResemble, []byte{255, 0})
+		})
+		Convey("Should create a missing secret", func() {
+			So(cl.Secret().WriteBinaryKey("app/sdb/new", "blob", []byte("hi")), ShouldBeNil)
+			So(fake.secrets["app/sdb/new"], ShouldResemble, map[string]interface{}{"blob": "aGk="})
+		})
+	})
+}
+
+func TestSecretPatch(t *testing.T) {
+	Convey("A secret patched", t, func() {
+		fake := &fakeSDBServer{
+			secrets: map[string]map[string]interface{}{
+				"app/sdb/db": {"user": "admin", "password": "hunter2", "port": float64(5432)},
+			},
+			files: newFakeSecureFileServer(map[string]string{}),
+		}
+		// Every write adds a version, and concurrent writes are simulated by adding one when the secret is read
+		var lock sync.Mutex
+		var versions, concurrentWrites int
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			if r.URL.Path == "/v1/secret-versions/app/sdb/db" {
+				var summaries = []api.SecretVersion{}
+				for i := 0; i < versions; i++ {
+					summaries = append(summaries, api.SecretVersion{ID: fmt.Sprintf("v%d", i)})
+				}
+				lock.Unlock()
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]interface{}{"has_next": false, "secure_data_version_summaries": summaries})
+				return
+			}
+			switch r.Method {
+			case http.MethodPut:
+				versions++
+			case http.MethodGet:
+				versions += concurrentWrites
+			}
+			lock.Unlock()
+			fake.ServeHTTP(w, r)
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		updates := map[string]interface{}{"password": "hunter3", "host": "db.local", "port": DeleteSecretKey}
+		expected := map[string]interface{}{"user": "admin", "password": "hunter3", "host": "db.local"}
+		Convey("Should merge the updates and keep the other keys", func() {
+			So(cl.Secret().Patch("app/sdb/db", updates), ShouldBeNil)
+			So(fake.secrets["app/sdb/db"], ShouldResemble, expected)
+		})
+		Convey("Should create a missing secret", func() {
+			So(cl.Secret().Patch("app/sdb/new", map[string]interface{}{"key": "value", "gone": DeleteSecretKey}), ShouldBeNil)
+			So(fake.secrets["app/sdb/new"], ShouldResemble, map[string]interface{}{"key": "value"})
+		})
+		Convey("Should merge the updates with CAS", func() {
+			versions = 2
+			So(cl.Secret().PatchCAS("app/sdb/db", updates), ShouldBeNil)
+			So(fake.secrets["app/sdb/db"], ShouldResemble, expected)
+			So(versions, ShouldEqual, 3)
+		})
+		Convey("Should return ErrorVersionConflict if the secret changes while patching with CAS", func() {
+			versions = 2
+			concurrentWrites = 1
+			So(cl.Secret().PatchCAS("app/sdb/db", updates), ShouldEqual, ErrorVersionConflict)
+			So(fake.secrets["app/sdb/db"]["password"], ShouldEqual, "hunter2")
+		})
+	})
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// maxSecretTreeDepth is the deepest folder nesting read by Secret.Tree
+const maxSecretTreeDepth = 64
+
+// errSecretAccessDenied is returned when the token is not allowed to list or read a secret path
+var errSecretAccessDenied = fmt.Errorf("Permission denied")
+
+// Tree reads all secrets under rootPath into a nested map: each folder is a map of its content and each
+// secret is its data. If a folder has the same name as a secret next to it, the folder is stored under its
+// name with a trailing slash. Folders and secrets the token is not allowed to read are skipped and reported
+// to the Logger set with WithLogger, if any. Path should not be prefaced with a "/"
+func (s *Secret) Tree(rootPath string) (map[string]interface{}, error) {
+	root := strings.Trim(strings.TrimPrefix(s.fullPath(strings.Trim(rootPath, "/")), pathPrefix), "/")
+	tree, err := s.tree(context.Background(), root, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading secret tree under %s: %v", root, err)
+	}
+	return tree, nil
+}
+
+// tree returns the nested map of the secrets under dir, depth folders below the root
+func (s *Secret) tree(ctx context.Context, dir string, depth int) (map[string]interface{}, error) {
+	if depth > maxSecretTreeDepth {
+		return nil, fmt.Errorf("%s is nested deeper than %d folders", dir, maxSecretTreeDepth)
+	}
+	keys, err := s.c.listSecretKeys(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	var secrets = map[string]bool{}
+	for _, key := range keys {
+		if !strings.HasSuffix(key, "/") {
+			secrets[key] = true
+		}
+	}
+	var node = map[string]interface{}{}
+	for _, key := range keys {
+		p := path.Join(dir, key)
+		var value map[string]interface{}
+		if strings.HasSuffix(key, "/") {
+			value, err = s.tree(ctx, p, depth+1)
+			if name := strings.TrimSuffix(key, "/"); !secrets[name] {
+				key = name
+			}
+		} else {
+			value, err = s.c.readSecretData(ctx, p)
+		}
+		if err == errSecretAccessDenied {
+			if s.c.logger != nil {
+				s.c.logger.Printf("cerberus: skipping %s in secret tree: permission denied", p)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// The secret may have been deleted since the folder was listed
+		if value != nil {
+			node[key] = value
+		}
+	}
+	return node, nil
+}
+
+// listSecretKeys returns the keys directly under dir, a vault path without the "secret/" prefix. Keys of
+// folders end with a slash. A path without any secret has no keys
+func (c *Client) listSecretKeys(ctx context.Context, dir string) ([]string, error) {
+	resp, err := c.doJSONRequest(ctx, http.MethodGet, path.Join(secretBasePath, dir)+"/", map[string]string{
+		"list": "true",
+	}, nil)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error while listing secrets under %s: %v", dir, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	// Vault returns a not found when listing a path without any secret
+	case http.StatusNotFound:
+		return []string{}, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, errSecretAccessDenied
+	default:
+		return nil, fmt.Errorf("Error while listing secrets under %s. Got HTTP status code %d", dir, resp.StatusCode)
+	}
+	var list struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+	if err := c.decodeResponse(resp.Body, &list); err != nil {
+		return nil, err
+	}
+	return list.Data.Keys, nil
+}
+
+// readSecretData returns the data of the secret at p, a vault path without the "secret/" prefix, or nil if
+// there is no secret at p
+func (c *Client) readSecretData(ctx context.Context, p string) (map[string]interface{}, error) {
+	resp, err := c.doJSONRequest(ctx, http.MethodGet, path.Join(secretBasePath, p), map[string]string{}, nil)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading secret %s: %v", p, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, errSecretAccessDenied
+	default:
+		return nil, fmt.Errorf("Error while reading secret %s. Got HTTP status code %d", p, resp.StatusCode)
+	}
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := c.decodeResponse(resp.Body, &secret); err != nil {
+		return nil, err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string]interface{}{}
+	}
+	return secret.Data, nil
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// secretTreeServer serves a tree of secrets with a forbidden folder and a folder named like a secret
+func secretTreeServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		list := r.URL.Query().Get("list") == "true"
+		switch {
+		case list && r.URL.Path == "/v1/secret/app/sdb/":
+			w.Write([]byte(`{"data": {"keys": ["db", "db/", "nested/", "private/", "gone"]}}`))
+		case list && r.URL.Path == "/v1/secret/app/sdb/db/":
+			w.Write([]byte(`{"data": {"keys": ["replica"]}}`))
+		case list && r.URL.Path == "/v1/secret/app/sdb/nested/":
+			w.Write([]byte(`{"data": {"keys": ["api", "empty/"]}}`))
+		case list && r.URL.Path == "/v1/secret/app/sdb/nested/empty/":
+			w.WriteHeader(http.StatusNotFound)
+		case list && r.URL.Path == "/v1/secret/app/sdb/private/":
+			w.WriteHeader(http.StatusForbidden)
+		case !list && r.URL.Path == "/v1/secret/app/sdb/db":
+			w.Write([]byte(`{"data": {"password": "hunter2"}}`))
+		case !list && r.URL.Path == "/v1/secret/app/sdb/db/replica":
+			w.Write([]byte(`{"data": {"host": "replica.local"}}`))
+		case !list && r.URL.Path == "/v1/secret/app/sdb/nested/api":
+			w.Write([]byte(`{"data": {"key": "abc"}}`))
+		case !list && r.URL.Path == "/v1/secret/app/sdb/gone":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+}
+
+func TestSecretTree(t *testing.T) {
+	Convey("A tree of secrets", t, func() {
+		ts := secretTreeServer()
+		Reset(func() {
+			ts.Close()
+		})
+		logger := &recordingLogger{}
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithLogger(logger))
+		So(cl, ShouldNotBeNil)
+		Convey("Should read all the secrets into a nested map", func() {
+			tree, err := cl.Secret().Tree("app/sdb")
+			So(err, ShouldBeNil)
+			So(tree, ShouldResemble, map[string]interface{}{
+				"db": map[string]interface{}{"password": "hunter2"},
+				"db/": map[string]interface{}{
+					"replica": map[string]interface{}{"host": "replica.local"},
+				},
+				"nested": map[string]interface{}{
+					"api":   map[string]interface{}{"key": "abc"},
+					"empty": map[string]interface{}{},
+				},
+			})
+		})
+		Convey("Should report the skipped folders", func() {
+			_, err := cl.Secret().Tree("app/sdb/")
+			So(err, ShouldBeNil)
+			So(logger.String(), ShouldContainSubstring, "skipping app/sdb/private in secret tree: permission denied")
+		})
+		Convey("Should read a subtree", func() {
+			tree, err := cl.Secret().Tree("app/sdb/nested")
+			So(err, ShouldBeNil)
+			So(tree, ShouldContainKey, "api")
+		})
+		Convey("Should error if the root cannot be listed", func() {
+			tree, err := cl.Secret().Tree("app/sdb/private")
+			So(err, ShouldNotBeNil)
+			So(tree, ShouldBeNil)
+		})
+		Convey("Should error on a server error", func() {
+			tree, err := cl.Secret().Tree("app/other")
+			So(err, ShouldNotBeNil)
+			So(tree, ShouldBeNil)
+		})
+	})
+}
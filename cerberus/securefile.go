@@ -18,11 +18,22 @@ package cerberus
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"hash"
 	"io"
+	"io/ioutil"
+	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"os"
 	"path"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/Nike-Inc/cerberus-go-client/api"
 )
@@ -35,11 +46,38 @@ type SecureFile struct {
 var secureFileBasePath = "/v1/secure-file"
 var secureFileListBasePath = "/v1/secure-files"
 
-// List returns a list of secure files
+// ErrorSecureFileNotFound is returned when a specified secure file is not found
+var ErrorSecureFileNotFound = fmt.Errorf("unable to find secure file")
+
+// idempotencyKeyHeader is the header used to identify a single logical upload
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// AbsoluteSecurePathMarker starts a path which is used as is, without the prefix set with WithSecurePathPrefix
+const AbsoluteSecurePathMarker = "//"
+
+// resolvePath returns the secure path to use for the given path, adding the prefix of the client
+// unless the path starts with AbsoluteSecurePathMarker
+func (r *SecureFile) resolvePath(secureFilePath string) string {
+	if strings.HasPrefix(secureFilePath, AbsoluteSecurePathMarker) {
+		return strings.TrimPrefix(secureFilePath, AbsoluteSecurePathMarker)
+	}
+	if r.c.securePathPrefix == "" {
+		return secureFilePath
+	}
+	return path.Join(r.c.securePathPrefix, secureFilePath)
+}
+
+// absoluteSecurePath marks a path so it is used as is. Paths returned by the server are absolute
+func absoluteSecurePath(secureFilePath string) string {
+	return AbsoluteSecurePathMarker + strings.TrimLeft(secureFilePath, "/")
+}
+
+// List returns a list of secure files. When there are no files, Summaries is an empty slice, never nil.
+// Listing a folder which does not exist is an error unless the client was created using WithTreatMissingAsEmpty(true)
 func (r *SecureFile) List(rootpath string) (*api.SecureFilesResponse, error) {
 	resp, err := r.c.DoRequest(http.MethodGet,
 		// path.Join will remove last '/' but cerberus expect a / suffix => Let's add it
-		path.Join(secureFileListBasePath, rootpath)+"/",
+		path.Join(secureFileListBasePath, r.resolvePath(rootpath))+"/",
 		map[string]string{
 			"list": "true",
 		},
@@ -51,53 +89,469 @@ func (r *SecureFile) List(rootpath string) (*api.SecureFilesResponse, error) {
 		return nil, fmt.Errorf("error while trying to get secure files: %v", err)
 	}
 
+	if resp.StatusCode == http.StatusNotFound && r.c.treatMissingAsEmpty {
+		return &api.SecureFilesResponse{Summaries: []api.SecureFileSummary{}}, nil
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("error while trying to list secure files. Got HTTP status code %d",
 			resp.StatusCode)
 	}
 	sfr := &api.SecureFilesResponse{}
-	err = parseResponse(resp.Body, sfr)
+	err = r.c.decodeResponse(resp.Body, sfr)
 	if err != nil {
 		return nil, err
 	}
+	// The server may omit the summaries or send null for an empty folder
+	if sfr.Summaries == nil {
+		sfr.Summaries = []api.SecureFileSummary{}
+	}
 	return sfr, nil
 }
 
-// Get downloads a secure file under localfile. File will be saved in output
-func (r *SecureFile) Get(secureFilePath string, output io.Writer) error {
+// ListRaw returns the undecoded reply of List, so fields not modeled by api.SecureFilesResponse can be read.
+// Like List, a missing folder is an error unless the client was created using WithTreatMissingAsEmpty(true),
+// in which case it returns an empty list
+func (r *SecureFile) ListRaw(rootpath string) (json.RawMessage, error) {
 	resp, err := r.c.DoRequest(http.MethodGet,
-		path.Join(secureFileBasePath, secureFilePath),
-		map[string]string{},
+		path.Join(secureFileListBasePath, r.resolvePath(rootpath))+"/",
+		map[string]string{
+			"list": "true",
+		},
 		nil)
 	if resp != nil {
 		defer resp.Body.Close()
 	}
 	if err != nil {
-		return fmt.Errorf("error while downloading secure file: %v", err)
+		return nil, fmt.Errorf("error while trying to get secure files: %v", err)
 	}
 
+	if resp.StatusCode == http.StatusNotFound && r.c.treatMissingAsEmpty {
+		return json.Marshal(api.SecureFilesResponse{Summaries: []api.SecureFileSummary{}})
+	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("error while trying to download secure file %s. Got HTTP status code %d",
+		return nil, fmt.Errorf("error while trying to list secure files. Got HTTP status code %d",
+			resp.StatusCode)
+	}
+	// Decoding into a RawMessage checks that the reply is valid JSON
+	var raw json.RawMessage
+	if err := r.c.decodeResponse(resp.Body, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// listPageSize is the number of secure files requested per page by ListAll
+var listPageSize = 1000
+
+// MaxListPageSize is the largest page size accepted by IterateWithPageSize and ListChanWithPageSize. It is
+// the page size used by ListAll, Iterate and ListChan
+const MaxListPageSize = 1000
+
+// validatePageSize returns an error if pageSize is not between 1 and MaxListPageSize
+func validatePageSize(pageSize int) error {
+	if pageSize < 1 || pageSize > MaxListPageSize {
+		return fmt.Errorf("invalid page size %d, it must be between 1 and %d", pageSize, MaxListPageSize)
+	}
+	return nil
+}
+
+// ListAll returns the summaries of all secure files under rootpath, requesting
+// as many pages as needed
+func (r *SecureFile) ListAll(rootpath string) ([]api.SecureFileSummary, error) {
+	var summaries = []api.SecureFileSummary{}
+	err := r.Iterate(rootpath, func(summary api.SecureFileSummary) error {
+		summaries = append(summaries, summary)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// ListModifiedSince returns the summaries of the secure files under rootpath last updated after since. Cerberus
+// has no filter for the update time, so all pages are requested and the summaries are filtered by the client
+func (r *SecureFile) ListModifiedSince(rootpath string, since time.Time) ([]api.SecureFileSummary, error) {
+	var summaries = []api.SecureFileSummary{}
+	err := r.Iterate(rootpath, func(summary api.SecureFileSummary) error {
+		if summary.LastUpdated.After(since) {
+			summaries = append(summaries, summary)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// Iterate calls fn for each secure file under rootpath. Pages are only requested when the previous
+// one has been processed. If fn returns an error, iteration stops and the error is returned
+func (r *SecureFile) Iterate(rootpath string, fn func(api.SecureFileSummary) error) error {
+	return r.iterate(context.Background(), rootpath, fn)
+}
+
+// IterateWithPageSize is Iterate, requesting pageSize secure files per page instead of MaxListPageSize.
+// Smaller pages hold fewer summaries in memory at once, at the cost of more requests. pageSize must be
+// between 1 and MaxListPageSize
+func (r *SecureFile) IterateWithPageSize(rootpath string, pageSize int, fn func(api.SecureFileSummary) error) error {
+	if err := validatePageSize(pageSize); err != nil {
+		return err
+	}
+	return r.iteratePages(context.Background(), rootpath, pageSize, fn)
+}
+
+// iterate is Iterate with a context
+func (r *SecureFile) iterate(ctx context.Context, rootpath string, fn func(api.SecureFileSummary) error) error {
+	return r.iteratePages(ctx, rootpath, listPageSize, fn)
+}
+
+// iteratePages calls fn for each secure file under rootpath, requesting pages of pageSize secure files
+func (r *SecureFile) iteratePages(ctx context.Context, rootpath string, pageSize int, fn func(api.SecureFileSummary) error) error {
+	err := r.c.paginate(ctx,
+		// path.Join will remove last '/' but cerberus expect a / suffix => Let's add it
+		path.Join(secureFileListBasePath, r.resolvePath(rootpath))+"/",
+		map[string]string{
+			"list":         "true",
+			r.c.limitParam: fmt.Sprintf("%d", pageSize),
+		},
+		func(raw json.RawMessage) error {
+			sfr := &api.SecureFilesResponse{}
+			if err := r.c.decodeResponse(bytes.NewReader(raw), sfr); err != nil {
+				return err
+			}
+			for _, summary := range sfr.Summaries {
+				if err := fn(summary); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	pageErr, ok := err.(*pageError)
+	switch {
+	case !ok:
+		return err
+	case pageErr.err != nil:
+		return fmt.Errorf("error while trying to get secure files: %v", pageErr.err)
+	case pageErr.statusCode == http.StatusNotFound && r.c.treatMissingAsEmpty:
+		return nil
+	default:
+		return fmt.Errorf("error while trying to list secure files. Got HTTP status code %d", pageErr.statusCode)
+	}
+}
+
+// ListChan sends the summaries of all secure files under rootpath on the returned channel, requesting pages
+// as the channel is consumed. Both channels are closed once listing is done. At most one error is sent on
+// the error channel, including the context error if the context is canceled before listing is done
+func (r *SecureFile) ListChan(ctx context.Context, rootpath string) (<-chan api.SecureFileSummary, <-chan error) {
+	return r.listChan(ctx, rootpath, listPageSize)
+}
+
+// ListChanWithPageSize is ListChan, requesting pageSize secure files per page instead of MaxListPageSize.
+// pageSize must be between 1 and MaxListPageSize, otherwise the error is sent on the error channel and
+// nothing is listed
+func (r *SecureFile) ListChanWithPageSize(ctx context.Context, rootpath string, pageSize int) (<-chan api.SecureFileSummary, <-chan error) {
+	if err := validatePageSize(pageSize); err != nil {
+		summaries := make(chan api.SecureFileSummary)
+		errs := make(chan error, 1)
+		errs <- err
+		close(summaries)
+		close(errs)
+		return summaries, errs
+	}
+	return r.listChan(ctx, rootpath, pageSize)
+}
+
+// listChan sends the summaries of all secure files under rootpath on the returned channel, requesting pages
+// of pageSize secure files
+func (r *SecureFile) listChan(ctx context.Context, rootpath string, pageSize int) (<-chan api.SecureFileSummary, <-chan error) {
+	summaries := make(chan api.SecureFileSummary)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(summaries)
+		defer close(errs)
+		err := r.iteratePages(ctx, rootpath, pageSize, func(summary api.SecureFileSummary) error {
+			select {
+			case summaries <- summary:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+	return summaries, errs
+}
+
+// nextCursor returns the cursor of the next page. Responses built by the client only have the offset set
+func nextCursor(cursor api.PageCursor, offset int) api.PageCursor {
+	if cursor == "" {
+		return api.PageCursor(fmt.Sprintf("%d", offset))
+	}
+	return cursor
+}
+
+// errStatFound stops the listing of Stat once the secure file is found
+var errStatFound = fmt.Errorf("secure file found")
+
+// Stat returns the summary of a single secure file. It is looked up by listing the folder
+// containing the file, page by page until it is found. Returns ErrorSecureFileNotFound if the file
+// does not exist
+func (r *SecureFile) Stat(secureFilePath string) (*api.SecureFileSummary, error) {
+	resolved := r.resolvePath(secureFilePath)
+	target := strings.Trim(resolved, "/")
+	var found *api.SecureFileSummary
+	err := r.iterate(context.Background(), absoluteSecurePath(path.Dir(resolved)), func(summary api.SecureFileSummary) error {
+		if strings.Trim(summary.Path, "/") == target {
+			found = &summary
+			return errStatFound
+		}
+		return nil
+	})
+	if err == errStatFound {
+		return found, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, ErrorSecureFileNotFound
+}
+
+// Watch polls a secure file every interval and calls fn with the new content each time the file
+// changes (based on its size and last updated time). The state of the file when Watch is called is
+// used as the starting point, so fn is only called for later changes. Watch blocks until the context
+// is canceled or an error occurs. The interval must be positive
+func (r *SecureFile) Watch(ctx context.Context, secureFilePath string, interval time.Duration, fn func([]byte)) error {
+	if interval <= 0 {
+		return fmt.Errorf("error while watching secure file %s: the interval must be positive, got %v", secureFilePath, interval)
+	}
+	last, err := r.Stat(secureFilePath)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.c.clock.After(interval):
+		}
+		current, err := r.Stat(secureFilePath)
+		if err != nil {
+			return err
+		}
+		if current.Size == last.Size && current.LastUpdated.Equal(last.LastUpdated) {
+			continue
+		}
+		var content bytes.Buffer
+		if err := r.Get(secureFilePath, &content); err != nil {
+			return err
+		}
+		last = current
+		fn(content.Bytes())
+	}
+}
+
+// URL returns the full URL used to download or upload the secure file at the given path
+func (r *SecureFile) URL(secureFilePath string) string {
+	return r.c.buildURL(path.Join(secureFileBasePath, r.resolvePath(secureFilePath)), nil).String()
+}
+
+// Get downloads a secure file under localfile. File will be saved in output
+func (r *SecureFile) Get(secureFilePath string, output io.Writer) error {
+	_, err := r.GetWithType(secureFilePath, output)
+	return err
+}
+
+// GetWithType downloads a secure file into output like Get, and returns the Content-Type sent by the
+// server, which is empty if there is none
+func (r *SecureFile) GetWithType(secureFilePath string, output io.Writer) (string, error) {
+	resp, err := r.openDownload(secureFilePath)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := r.copyDownload(secureFilePath, output, resp); err != nil {
+		return "", err
+	}
+	return resp.Header.Get("Content-Type"), nil
+}
+
+// GetReaderWithType returns the content of a secure file as it is downloaded, along with the Content-Type
+// sent by the server, which is empty if there is none. The reader must be closed. The maximum download size
+// set with WithMaxDownloadBytes is enforced while reading, but progress is not reported. Transfer metrics
+// are reported when the reader is closed
+func (r *SecureFile) GetReaderWithType(secureFilePath string) (io.ReadCloser, string, error) {
+	resp, err := r.openDownload(secureFilePath)
+	if err != nil {
+		return nil, "", err
+	}
+	body, err := r.limitDownload(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, "", err
+	}
+	if r.c.transferMetrics != nil {
+		return &reportingReadCloser{
+			countingReader: &countingReader{r: body},
+			Closer:         resp.Body,
+			report: func(n int64) {
+				r.c.reportTransfer(TransferDownload, secureFilePath, n)
+			},
+		}, resp.Header.Get("Content-Type"), nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{body, resp.Body}, resp.Header.Get("Content-Type"), nil
+}
+
+// openDownload sends the request downloading a secure file and returns the response if it succeeded.
+// The body of the response must be closed
+func (r *SecureFile) openDownload(secureFilePath string) (*http.Response, error) {
+	resp, err := r.c.doRequest(context.Background(), http.MethodGet,
+		path.Join(secureFileBasePath, r.resolvePath(secureFilePath)),
+		map[string]string{},
+		http.Header{"Accept": []string{r.c.downloadAccept}},
+		"",
+		nil)
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return nil, fmt.Errorf("error while downloading secure file: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error while trying to download secure file %s. Got HTTP status code %d",
 			secureFilePath,
 			resp.StatusCode)
 	}
+	return resp, nil
+}
+
+// Download saves a secure file in the download directory of the client (see WithDownloadDir), using
+// the name of the secure file. It returns the path of the local file
+func (r *SecureFile) Download(secureFilePath string) (string, error) {
+	localpath := r.defaultLocalPath(secureFilePath)
+	if err := r.GetToFile(secureFilePath, localpath); err != nil {
+		return "", err
+	}
+	return localpath, nil
+}
+
+// defaultLocalPath returns where a secure file is saved when no local path is given
+func (r *SecureFile) defaultLocalPath(secureFilePath string) string {
+	return filepath.Join(r.c.downloadDir, path.Base(secureFilePath))
+}
 
-	// Copy
-	_, err = io.Copy(output, resp.Body)
+// GetToFile downloads a secure file and saves it under localpath. If localpath is empty, the file is
+// saved in the download directory of the client (see Download). The directory containing localpath
+// must exist unless the client was created using WithCreateDirs(true), in which case it is created.
+// The file is readable and writable by the current user only, see WithDownloadFileMode
+func (r *SecureFile) GetToFile(secureFilePath string, localpath string) error {
+	if localpath == "" {
+		localpath = r.defaultLocalPath(secureFilePath)
+	}
+	dir := filepath.Dir(localpath)
+	if r.c.createDirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error while creating directory %s: %v", dir, err)
+		}
+	} else if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("error while downloading secure file %s: directory %s does not exist", secureFilePath, dir)
+	}
+
+	f, err := r.createLocalFile(localpath)
 	if err != nil {
+		return fmt.Errorf("error while creating local file %s: %v", localpath, err)
+	}
+	if err := r.Get(secureFilePath, f); err != nil {
+		f.Close()
+		// Don't leave a partial file behind
+		os.Remove(localpath)
 		return err
 	}
+	return f.Close()
+}
 
-	return nil
+// createLocalFile creates or truncates the local file at localpath for a download, with the mode set with
+// WithDownloadFileMode. The mode of an existing file is changed too, so secrets are never written to a
+// file readable by others
+func (r *SecureFile) createLocalFile(localpath string) (*os.File, error) {
+	f, err := os.OpenFile(localpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, r.c.downloadFileMode)
+	if err != nil {
+		return nil, err
+	}
+	// The mode given to OpenFile is reduced by the umask and ignored for existing files
+	if err := f.Chmod(r.c.downloadFileMode); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// GetTempFile downloads a secure file into a new file of the temporary directory of the OS, created with
+// permissions 0600 so only the current user can read it, and returns its path along with a function removing it. The caller
+// must call cleanup once done with the file, and calling it again does nothing
+func (r *SecureFile) GetTempFile(secureFilePath string) (localpath string, cleanup func() error, err error) {
+	f, err := ioutil.TempFile("", "cerberus-secure-file-")
+	if err != nil {
+		return "", nil, fmt.Errorf("error while creating temporary file: %v", err)
+	}
+	localpath = f.Name()
+	cleanup = func() error {
+		if err := os.Remove(localpath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := r.Get(secureFilePath, f); err != nil {
+		f.Close()
+		cleanup()
+		return "", nil, err
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return localpath, cleanup, nil
+}
+
+// defaultPartContentType is the Content-Type of an uploaded file when it cannot be detected from its name
+const defaultPartContentType = "application/octet-stream"
+
+// detectContentType returns the MIME type of a file based on its extension
+func detectContentType(filename string) string {
+	if t := mime.TypeByExtension(filepath.Ext(filename)); t != "" {
+		return t
+	}
+	return defaultPartContentType
 }
 
-// getUploadFileBodyWriter create a reader containing an encoded multipart file. It returns a reader, a content-type and/or possible error
-func getUploadFileBodyWriter(filename string, input io.Reader) (io.Reader, string, error) {
+// quoteEscaper escapes file names in the Content-Disposition of a part. Non-ASCII characters are sent as is
+// in UTF-8, as described in RFC 7578, which forbids the RFC 5987 filename* parameter. Line breaks would end
+// the header, so they are percent-encoded like browsers do
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"", "\r", "%0D", "\n", "%0A")
+
+// getUploadFileBodyWriter create a reader containing an encoded multipart file. It returns a reader, a content-type and/or possible error.
+// The file part is sent with partContentType, or a type detected from the file name if it is empty
+func getUploadFileBodyWriter(filename, partContentType string, input io.Reader) (io.Reader, string, error) {
 	// Create mpart
 	var b bytes.Buffer
 	w := multipart.NewWriter(&b)
 
-	part, err := w.CreateFormFile("file-content", filename)
+	if partContentType == "" {
+		partContentType = detectContentType(filename)
+	}
+	// Same as CreateFormFile, which always uses application/octet-stream
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition",
+		fmt.Sprintf(`form-data; name="file-content"; filename="%s"`, quoteEscaper.Replace(filename)))
+	h.Set("Content-Type", partContentType)
+	part, err := w.CreatePart(h)
 	if err != nil {
 		return nil, "", err
 	}
@@ -117,33 +571,330 @@ func getUploadFileBodyWriter(filename string, input io.Reader) (io.Reader, strin
 	return &b, contentType, nil
 }
 
-// Put uploads a secure file to a given location localfile
+// Put uploads a secure file to a given location localfile. filename is the name the file is stored under,
+// sent in the Content-Disposition of the upload, so it does not have to be the name of a local file read by
+// input. It must not contain a path separator. The Content-Type of the file is detected from its name,
+// defaulting to application/octet-stream
 func (r *SecureFile) Put(secureFilePath string, filename string, input io.Reader) error {
+	return r.PutWithContentType(secureFilePath, filename, "", input)
+}
+
+// stdin is the input of PutStdin
+var stdin io.Reader = os.Stdin
+
+// PutStdin uploads the standard input of the process as a secure file like Put, reading it until EOF, so a
+// shell pipeline can upload without a temporary file. Standard input cannot be read twice, but the content is
+// buffered in the request body, which is sent again as is when the upload is retried
+func (r *SecureFile) PutStdin(secureFilePath, filename string) error {
+	return r.Put(secureFilePath, filename, stdin)
+}
+
+// PutString uploads content as a secure file like Put
+func (r *SecureFile) PutString(secureFilePath, filename, content string) error {
+	return r.Put(secureFilePath, filename, strings.NewReader(content))
+}
+
+// PutFile uploads the local file at localfile as a secure file named after the last element of
+// secureFilePath, in a single request like Put. Cerberus has no chunked or resumable upload endpoint
+func (r *SecureFile) PutFile(secureFilePath, localfile string) error {
+	f, err := os.Open(localfile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return r.Put(secureFilePath, path.Base(secureFilePath), f)
+}
+
+// PutWithContentType uploads a secure file like Put, declaring the file with the given Content-Type.
+// If contentType is empty, it is detected like in Put. If the server rejects the upload, the error is an *UploadError
+func (r *SecureFile) PutWithContentType(secureFilePath, filename, contentType string, input io.Reader) error {
+	_, err := r.put(secureFilePath, filename, contentType, nil, input)
+	return err
+}
+
+// reservedMetadataHeaders are set by the client and cannot be overridden by upload metadata
+var reservedMetadataHeaders = map[string]bool{
+	"Accept":             true,
+	"Content-Type":       true,
+	"Content-Length":     true,
+	"Content-Encoding":   true,
+	"Host":               true,
+	idempotencyKeyHeader: true,
+	"X-Vault-Token":      true,
+	"X-Cerberus-Client":  true,
+}
+
+// PutWithMetadata uploads a secure file like Put, sending each metadata entry as a request header, such as
+// X-Cerberus-File-Tag. Servers which don't support metadata ignore the headers. Headers set by the client,
+// such as X-Vault-Token or Content-Type, cannot be used as metadata
+func (r *SecureFile) PutWithMetadata(secureFilePath, filename string, metadata map[string]string, input io.Reader) error {
+	for name := range metadata {
+		canonical := http.CanonicalHeaderKey(name)
+		if reservedMetadataHeaders[canonical] || canonical == http.CanonicalHeaderKey(r.c.correlationIDHeader) {
+			return fmt.Errorf("error while uploading secure file %s: header %s cannot be used as metadata", secureFilePath, name)
+		}
+	}
+	_, err := r.put(secureFilePath, filename, "", metadata, input)
+	return err
+}
+
+// put uploads a secure file with the given Content-Type, detected if empty, and metadata headers. It returns
+// the summary of the stored file if the server sent one
+func (r *SecureFile) put(secureFilePath, filename, contentType string, metadata map[string]string, input io.Reader) (*api.SecureFileSummary, error) {
+	if strings.ContainsAny(filename, `/\`) {
+		return nil, fmt.Errorf("error while uploading secure file %s: file name %q must not contain a path separator", secureFilePath, filename)
+	}
+	if r.c.maxUploadBytes > 0 {
+		// Files are rejected before their content is read, other readers while it is read
+		if tooLarge(input, r.c.maxUploadBytes) {
+			return nil, ErrorUploadTooLarge
+		}
+		input = &maxBytesReader{r: input, max: r.c.maxUploadBytes}
+	}
+	// Count the content for the transfer metrics, reusing the counter of the caller if there is one
+	counter, ok := input.(*countingReader)
+	if !ok {
+		counter = &countingReader{r: input}
+	}
+	input = counter
+	// Compute the checksum of the content while it is read if it has to be verified
+	var checksum hash.Hash
+	if r.c.verifyAfterUpload {
+		checksum = sha256.New()
+		input = io.TeeReader(input, checksum)
+	}
 	// Create multipart body and content type
-	body, contentType, err := getUploadFileBodyWriter(filename, input)
+	body, bodyContentType, err := getUploadFileBodyWriter(filename, contentType, input)
+	if err == ErrorUploadTooLarge {
+		return nil, err
+	}
 	if err != nil {
-		return fmt.Errorf("error creating upload body: %v", err)
+		return nil, fmt.Errorf("error creating upload body: %v", err)
+	}
+
+	var headers = http.Header{}
+	for name, value := range metadata {
+		headers.Set(name, value)
+	}
+	if contentType == "" {
+		contentType = detectContentType(filename)
+	}
+	if buf, ok := body.(*bytes.Buffer); ok && r.c.compressUploads && !isCompressed(filename, contentType) {
+		compressed, smaller, err := gzipBody(buf)
+		if err != nil {
+			return nil, fmt.Errorf("error compressing upload body: %v", err)
+		}
+		if smaller {
+			body = compressed
+			headers.Set("Content-Encoding", "gzip")
+		}
+	}
+	// The same key must be used if this upload is sent again
+	if r.c.idempotencyKeys {
+		key, err := newUUID()
+		if err != nil {
+			return nil, fmt.Errorf("error generating idempotency key: %v", err)
+		}
+		headers.Set(idempotencyKeyHeader, key)
 	}
 
 	// Send request
-	resp, err := r.c.DoRequestWithBody(http.MethodPost,
-		path.Join(secureFileBasePath, secureFilePath),
+	resp, err := r.c.doRequest(context.Background(), http.MethodPost,
+		path.Join(secureFileBasePath, r.resolvePath(secureFilePath)),
 		map[string]string{},
-		contentType,
+		headers,
+		bodyContentType,
 		body)
+	r.c.reportTransfer(TransferUpload, secureFilePath, counter.n)
 	if resp != nil {
 		defer resp.Body.Close()
 	}
 	if err != nil {
-		return fmt.Errorf("error while downloading secure file: %v", err)
+		return nil, fmt.Errorf("error while downloading secure file: %v", err)
+	}
+
+	// Cerberus replies with "no content", but a server may also reply with the summary of the stored file
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return nil, newUploadError(secureFilePath, resp.StatusCode, resp.Body)
+	}
+	summary, err := r.decodeUploadResponse(secureFilePath, resp)
+	if err != nil {
+		return nil, err
 	}
 
-	// expected sucess reply is "no content"
-	if resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("error while trying to download secure file %s. Got HTTP status code %d",
+	if checksum != nil {
+		if err := r.verifyUpload(secureFilePath, checksum.Sum(nil)); err != nil {
+			return nil, err
+		}
+	}
+	return summary, nil
+}
+
+// decodeUploadResponse returns the summary sent in the body of a successful upload response, or nil if the
+// body is empty or not JSON, such as a plain "OK". JSON which is not a summary is an error
+func (r *SecureFile) decodeUploadResponse(secureFilePath string, resp *http.Response) (*api.SecureFileSummary, error) {
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	var summary = &api.SecureFileSummary{}
+	err := r.c.decodeResponse(resp.Body, summary)
+	if _, ok := err.(*NonJSONResponseError); ok || err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error while reading the response to the upload of secure file %s: %v", secureFilePath, err)
+	}
+	return summary, nil
+}
+
+// ErrorUploadVerificationFailed is returned when the content of an uploaded secure file does not match what was sent
+var ErrorUploadVerificationFailed = fmt.Errorf("uploaded secure file does not match the source")
+
+// verifyUpload downloads a secure file and compares its SHA-256 to the expected one
+func (r *SecureFile) verifyUpload(secureFilePath string, expected []byte) error {
+	remote := sha256.New()
+	if err := r.Get(secureFilePath, remote); err != nil {
+		return fmt.Errorf("error while verifying upload of secure file %s: %v", secureFilePath, err)
+	}
+	if !bytes.Equal(remote.Sum(nil), expected) {
+		return ErrorUploadVerificationFailed
+	}
+	return nil
+}
+
+// ErrorUploadSizeMismatch is returned when the size of an uploaded secure file reported by the server is not the size of what was sent
+var ErrorUploadSizeMismatch = fmt.Errorf("size of uploaded secure file does not match the source")
+
+// ErrorUploadTooLarge is returned when a secure file is larger than the limit set with WithMaxUploadBytes
+var ErrorUploadTooLarge = fmt.Errorf("file exceeds max upload size")
+
+// tooLarge returns whether input is a regular file, such as an *os.File, of more than max bytes
+func tooLarge(input io.Reader, max int64) bool {
+	file, ok := input.(interface {
+		Stat() (os.FileInfo, error)
+	})
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode().IsRegular() && info.Size() > max
+}
+
+// maxBytesReader fails with ErrorUploadTooLarge once more than max bytes are read through it
+type maxBytesReader struct {
+	r   io.Reader
+	max int64
+	n   int64
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	m.n += int64(n)
+	if m.n > m.max {
+		return n, ErrorUploadTooLarge
+	}
+	return n, err
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// reportingReadCloser counts the bytes read through it and reports them the first time it is closed
+type reportingReadCloser struct {
+	*countingReader
+	io.Closer
+	report   func(n int64)
+	reported bool
+}
+
+func (r *reportingReadCloser) Close() error {
+	if !r.reported {
+		r.reported = true
+		r.report(r.n)
+	}
+	return r.Closer.Close()
+}
+
+// PutWithResult uploads a secure file like Put and returns the summary of the stored file sent by the server
+// in the response, or nil if the server did not send one. Cerberus replies without one, see PutAndStat
+func (r *SecureFile) PutWithResult(secureFilePath, filename string, input io.Reader) (*api.SecureFileSummary, error) {
+	return r.put(secureFilePath, filename, "", nil, input)
+}
+
+// PutAndStat uploads a secure file like Put and returns its summary, so the size and other fields stored by the
+// server can be checked. Unless the server replies with the summary, the file is looked up with Stat, which
+// costs an extra request that Put does not make. If the size reported by the server is not the number of bytes
+// read from input, the summary is returned along with ErrorUploadSizeMismatch
+func (r *SecureFile) PutAndStat(secureFilePath, filename string, input io.Reader) (*api.SecureFileSummary, error) {
+	counter := &countingReader{r: input}
+	summary, err := r.put(secureFilePath, filename, "", nil, counter)
+	if err != nil {
+		return nil, err
+	}
+	if summary == nil || summary.Path == "" {
+		if summary, err = r.Stat(secureFilePath); err != nil {
+			return nil, err
+		}
+	}
+	if int64(summary.Size) != counter.n {
+		return summary, ErrorUploadSizeMismatch
+	}
+	return summary, nil
+}
+
+// Delete deletes the secure file at the given path. Returns ErrorSecureFileNotFound if the file does not exist
+func (r *SecureFile) Delete(secureFilePath string) error {
+	return r.delete(context.Background(), secureFilePath)
+}
+
+// DeleteIfUnchanged deletes the secure file at the given path only if its size and last updated time are still
+// the ones of expected, typically a summary returned by Stat or List. Secure files have no versions, so this is
+// used instead of a version check. It returns ErrorVersionConflict if the file changed. Like Secret.DeleteIfVersion,
+// the check is done right before deleting and a change made in between is not detected
+func (r *SecureFile) DeleteIfUnchanged(secureFilePath string, expected api.SecureFileSummary) error {
+	current, err := r.Stat(secureFilePath)
+	if err != nil {
+		return err
+	}
+	if current.Size != expected.Size || !current.LastUpdated.Equal(expected.LastUpdated) {
+		return ErrorVersionConflict
+	}
+	return r.Delete(secureFilePath)
+}
+
+// delete is Delete with a context
+func (r *SecureFile) delete(ctx context.Context, secureFilePath string) error {
+	resp, err := r.c.doJSONRequest(ctx, http.MethodDelete,
+		path.Join(secureFileBasePath, r.resolvePath(secureFilePath)),
+		map[string]string{},
+		nil)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	// The context error is returned as is, so a batch stopped by its deadline can be told from a failure
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("error while deleting secure file: %v", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrorSecureFileNotFound
+	}
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error while trying to delete secure file %s. Got HTTP status code %d",
 			secureFilePath,
 			resp.StatusCode)
 	}
-
 	return nil
 }
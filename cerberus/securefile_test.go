@@ -18,10 +18,18 @@ package cerberus
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -126,6 +134,143 @@ func TestSecureFileList(t *testing.T) {
 	})
 }
 
+var secureFileEmptyListReply = `{
+	"has_next" : false,
+	"next_offset" : null,
+	"limit" : 1000,
+	"offset" : 0,
+	"file_count_in_result" : 0,
+	"total_file_count" : 0,
+	"secure_file_summaries" : [ ]
+  }`
+
+func TestSecureFileListEmpty(t *testing.T) {
+	Convey("A List of an empty folder", t, WithTestServer(http.StatusOK, "/v1/secure-files/my/sdb", http.MethodGet, secureFileEmptyListReply, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return an empty, non nil list", func() {
+			files, err := cl.SecureFile().List("my/sdb")
+			So(err, ShouldBeNil)
+			So(files.Summaries, ShouldNotBeNil)
+			So(files.Summaries, ShouldBeEmpty)
+		})
+	}))
+
+	Convey("A List without summaries in the reply", t, WithTestServer(http.StatusOK, "/v1/secure-files/my/sdb", http.MethodGet, `{"has_next": false, "secure_file_summaries": null}`, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return an empty, non nil list", func() {
+			files, err := cl.SecureFile().List("my/sdb")
+			So(err, ShouldBeNil)
+			So(files.Summaries, ShouldNotBeNil)
+			So(files.Summaries, ShouldBeEmpty)
+		})
+	}))
+}
+
+func TestSecureFileListMissing(t *testing.T) {
+	Convey("A List of a missing folder", t, WithTestServer(http.StatusNotFound, "/v1/secure-files/my/sdb", http.MethodGet, "", func(ts *httptest.Server) {
+		Convey("Should error by default", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			_, err := cl.SecureFile().List("my/sdb")
+			So(err, ShouldNotBeNil)
+			_, err = cl.SecureFile().ListAll("my/sdb")
+			So(err, ShouldNotBeNil)
+		})
+		Convey("Should return no files when treating missing folders as empty", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithTreatMissingAsEmpty(true))
+			files, err := cl.SecureFile().List("my/sdb")
+			So(err, ShouldBeNil)
+			So(files.Summaries, ShouldNotBeNil)
+			So(files.Summaries, ShouldBeEmpty)
+			all, err := cl.SecureFile().ListAll("my/sdb")
+			So(err, ShouldBeNil)
+			So(all, ShouldNotBeNil)
+			So(all, ShouldBeEmpty)
+		})
+	}))
+
+	Convey("A List failing with another status", t, WithTestServer(http.StatusForbidden, "/v1/secure-files/my/sdb", http.MethodGet, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithTreatMissingAsEmpty(true))
+		Convey("Should still error", func() {
+			_, err := cl.SecureFile().List("my/sdb")
+			So(err, ShouldNotBeNil)
+			_, err = cl.SecureFile().ListAll("my/sdb")
+			So(err, ShouldNotBeNil)
+		})
+	}))
+}
+
+func TestSecureFileListRaw(t *testing.T) {
+	Convey("A ListRaw with fields unknown to the client", t, WithTestServer(http.StatusOK, "/v1/secure-files/my/sdb", http.MethodGet, `{"has_next": false, "secure_file_summaries": [], "new_field": 42}`, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return the whole reply", func() {
+			raw, err := cl.SecureFile().ListRaw("my/sdb")
+			So(err, ShouldBeNil)
+			var reply map[string]interface{}
+			So(json.Unmarshal(raw, &reply), ShouldBeNil)
+			So(reply["new_field"], ShouldEqual, 42)
+		})
+	}))
+
+	Convey("A ListRaw with an invalid reply", t, WithTestServer(http.StatusOK, "/v1/secure-files/my/sdb", http.MethodGet, `{"has_next": fal`, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		Convey("Should error", func() {
+			_, err := cl.SecureFile().ListRaw("my/sdb")
+			So(err, ShouldNotBeNil)
+		})
+	}))
+
+	Convey("A ListRaw of a missing folder", t, WithTestServer(http.StatusNotFound, "/v1/secure-files/my/sdb", http.MethodGet, "", func(ts *httptest.Server) {
+		Convey("Should error by default", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			_, err := cl.SecureFile().ListRaw("my/sdb")
+			So(err, ShouldNotBeNil)
+		})
+		Convey("Should return an empty list when treating missing folders as empty", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithTreatMissingAsEmpty(true))
+			raw, err := cl.SecureFile().ListRaw("my/sdb")
+			So(err, ShouldBeNil)
+			var reply api.SecureFilesResponse
+			So(json.Unmarshal(raw, &reply), ShouldBeNil)
+			So(reply.Summaries, ShouldBeEmpty)
+		})
+	}))
+}
+
+func TestSecureFileListMaxResponseBytes(t *testing.T) {
+	Convey("A large list response", t, func() {
+		files := map[string]string{}
+		for i := 0; i < 50; i++ {
+			files[fmt.Sprintf("app/sdb/file-%d.txt", i)] = "hello"
+		}
+		server := newFakeSecureFileServer(files)
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		Convey("Should fail with ErrorResponseTooLarge over the limit", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithMaxResponseBytes(1024))
+			resp, err := cl.SecureFile().List("app/sdb")
+			So(err, ShouldEqual, ErrorResponseTooLarge)
+			So(resp, ShouldBeNil)
+		})
+		Convey("Should be parsed under the limit", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithMaxResponseBytes(1<<20))
+			resp, err := cl.SecureFile().List("app/sdb")
+			So(err, ShouldBeNil)
+			So(resp.Summaries, ShouldHaveLength, 50)
+		})
+		Convey("Should not limit downloads", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithMaxResponseBytes(1))
+			var content bytes.Buffer
+			So(cl.SecureFile().Get("app/sdb/file-1.txt", &content), ShouldBeNil)
+			So(content.String(), ShouldEqual, "hello")
+		})
+	})
+}
+
 func TestSecureFileGet(t *testing.T) {
 	var fileBuffer bytes.Buffer
 
@@ -171,6 +316,51 @@ func TestSecureFileGet(t *testing.T) {
 	})
 }
 
+func TestSecureFileGetWithType(t *testing.T) {
+	Convey("A download with a content type", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/secure-file/app/sdb/conf.json" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"port": 8080}`))
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return the content type with the content", func() {
+			var content bytes.Buffer
+			contentType, err := cl.SecureFile().GetWithType("app/sdb/conf.json", &content)
+			So(err, ShouldBeNil)
+			So(contentType, ShouldEqual, "application/json")
+			So(content.String(), ShouldEqual, `{"port": 8080}`)
+		})
+		Convey("Should return a reader with the content type", func() {
+			body, contentType, err := cl.SecureFile().GetReaderWithType("app/sdb/conf.json")
+			So(err, ShouldBeNil)
+			defer body.Close()
+			So(contentType, ShouldEqual, "application/json")
+			content, err := ioutil.ReadAll(body)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, `{"port": 8080}`)
+		})
+		Convey("Should limit the reader to the maximum download size", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithMaxDownloadBytes(4))
+			_, _, err := cl.SecureFile().GetReaderWithType("app/sdb/conf.json")
+			So(err, ShouldEqual, ErrorDownloadTooLarge)
+		})
+		Convey("Should error on a missing file", func() {
+			body, contentType, err := cl.SecureFile().GetReaderWithType("app/sdb/missing.json")
+			So(err, ShouldNotBeNil)
+			So(body, ShouldBeNil)
+			So(contentType, ShouldBeEmpty)
+		})
+	})
+}
+
 func getTestInputReader(t *testing.T, content string) io.Reader {
 	var buf bytes.Buffer
 	if _, err := buf.WriteString(content); err != nil {
@@ -228,3 +418,1157 @@ func TestSecureFilePut(t *testing.T) {
 		})
 	})
 }
+
+func TestSecureFilePutString(t *testing.T) {
+	Convey("A string uploaded as a secure file", t, func() {
+		server := newFakeSecureFileServer(map[string]string{})
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should be stored with the same content", func() {
+			So(cl.SecureFile().PutString("app/sdb/app.conf", "app.conf", "port = 8080\n"), ShouldBeNil)
+			So(string(server.files["app/sdb/app.conf"]), ShouldEqual, "port = 8080\n")
+		})
+	})
+}
+
+func TestSecureFilePutWithMetadata(t *testing.T) {
+	Convey("An upload with metadata", t, func() {
+		var headers http.Header
+		server := newFakeSecureFileServer(map[string]string{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers = r.Header
+			server.ServeHTTP(w, r)
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithIdempotencyKeys(true))
+		So(cl, ShouldNotBeNil)
+		Convey("Should send the metadata as headers", func() {
+			metadata := map[string]string{"X-Cerberus-File-Tag": "env=prod", "x-owner": "team-a"}
+			So(cl.SecureFile().PutWithMetadata("app/sdb/app.conf", "app.conf", metadata, strings.NewReader("port = 8080")), ShouldBeNil)
+			So(headers.Get("X-Cerberus-File-Tag"), ShouldEqual, "env=prod")
+			So(headers.Get("X-Owner"), ShouldEqual, "team-a")
+			So(headers.Get("X-Vault-Token"), ShouldEqual, "a-cool-token")
+			So(headers.Get(idempotencyKeyHeader), ShouldNotBeEmpty)
+			So(string(server.files["app/sdb/app.conf"]), ShouldEqual, "port = 8080")
+		})
+		Convey("Should refuse headers set by the client", func() {
+			metadata := map[string]string{"x-vault-token": "another-token"}
+			So(cl.SecureFile().PutWithMetadata("app/sdb/app.conf", "app.conf", metadata, strings.NewReader("port = 8080")), ShouldNotBeNil)
+			So(server.uploads, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestSecureFilePutResult(t *testing.T) {
+	Convey("A server replying to uploads with a 200", t, func() {
+		var reply string
+		var requests int
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(reply))
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return the summary of the stored file", func() {
+			reply = `{"path": "app/sdb/a.txt", "name": "a.txt", "size_in_bytes": 5}`
+			summary, err := cl.SecureFile().PutWithResult("app/sdb/a.txt", "a.txt", strings.NewReader("hello"))
+			So(err, ShouldBeNil)
+			So(summary.Path, ShouldEqual, "app/sdb/a.txt")
+			So(summary.Size, ShouldEqual, 5)
+			Convey("And PutAndStat should not look the file up", func() {
+				summary, err := cl.SecureFile().PutAndStat("app/sdb/a.txt", "a.txt", strings.NewReader("hello"))
+				So(err, ShouldBeNil)
+				So(summary.Size, ShouldEqual, 5)
+				So(requests, ShouldEqual, 2)
+			})
+		})
+		Convey("Should succeed without a body", func() {
+			reply = ""
+			So(cl.SecureFile().Put("app/sdb/a.txt", "a.txt", strings.NewReader("hello")), ShouldBeNil)
+			summary, err := cl.SecureFile().PutWithResult("app/sdb/a.txt", "a.txt", strings.NewReader("hello"))
+			So(err, ShouldBeNil)
+			So(summary, ShouldBeNil)
+		})
+		Convey("Should succeed without a summary for a body which is not JSON", func() {
+			for _, reply = range []string{"OK", "<html>Upload complete</html>", " \n"} {
+				summary, err := cl.SecureFile().PutWithResult("app/sdb/a.txt", "a.txt", strings.NewReader("hello"))
+				So(err, ShouldBeNil)
+				So(summary, ShouldBeNil)
+			}
+		})
+		Convey("Should still verify the upload for a body which is not JSON", func() {
+			reply = "OK"
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithVerifyAfterUpload(true))
+			err := cl.SecureFile().Put("app/sdb/a.txt", "a.txt", strings.NewReader("hello"))
+			So(err, ShouldEqual, ErrorUploadVerificationFailed)
+			So(requests, ShouldEqual, 2)
+		})
+		Convey("Should error on JSON which is not a summary", func() {
+			reply = `{"size_in_bytes": "five"}`
+			So(cl.SecureFile().Put("app/sdb/a.txt", "a.txt", strings.NewReader("hello")), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestSecureFilePutFile(t *testing.T) {
+	Convey("A local file", t, func() {
+		server := newFakeSecureFileServer(map[string]string{})
+		ts := httptest.NewServer(server)
+		dir, err := ioutil.TempDir("", "cerberus-test")
+		So(err, ShouldBeNil)
+		writeTestFiles(t, dir, map[string]string{"big.bin": "0123456789"})
+		Reset(func() {
+			ts.Close()
+			os.RemoveAll(dir)
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should be uploaded in a single request", func() {
+			So(cl.SecureFile().PutFile("app/sdb/big.bin", filepath.Join(dir, "big.bin")), ShouldBeNil)
+			So(server.uploads, ShouldEqual, 1)
+			So(string(server.files["app/sdb/big.bin"]), ShouldEqual, "0123456789")
+		})
+		Convey("Should error if the local file does not exist", func() {
+			So(cl.SecureFile().PutFile("app/sdb/big.bin", filepath.Join(dir, "missing.bin")), ShouldNotBeNil)
+			So(server.uploads, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestSecureFilePutMaxUploadBytes(t *testing.T) {
+	Convey("A client with a max upload size", t, func() {
+		server := newFakeSecureFileServer(map[string]string{})
+		ts := httptest.NewServer(server)
+		dir, err := ioutil.TempDir("", "cerberus-test")
+		So(err, ShouldBeNil)
+		writeTestFiles(t, dir, map[string]string{"big.bin": "0123456789", "small.bin": "01234"})
+		Reset(func() {
+			ts.Close()
+			os.RemoveAll(dir)
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithMaxUploadBytes(5))
+		So(cl, ShouldNotBeNil)
+		Convey("Should reject a larger file before reading it", func() {
+			f, err := os.Open(filepath.Join(dir, "big.bin"))
+			So(err, ShouldBeNil)
+			defer f.Close()
+			So(cl.SecureFile().Put("app/sdb/big.bin", "big.bin", f), ShouldEqual, ErrorUploadTooLarge)
+			So(server.uploads, ShouldEqual, 0)
+			offset, _ := f.Seek(0, io.SeekCurrent)
+			So(offset, ShouldEqual, 0)
+		})
+		Convey("Should reject a larger stream", func() {
+			So(cl.SecureFile().PutString("app/sdb/big.bin", "big.bin", "0123456789"), ShouldEqual, ErrorUploadTooLarge)
+			So(server.uploads, ShouldEqual, 0)
+		})
+		Convey("Should upload files and streams up to the limit", func() {
+			So(cl.SecureFile().PutFile("app/sdb/small.bin", filepath.Join(dir, "small.bin")), ShouldBeNil)
+			So(cl.SecureFile().PutString("app/sdb/other.bin", "other.bin", "abcde"), ShouldBeNil)
+			So(server.uploads, ShouldEqual, 2)
+			So(string(server.files["app/sdb/other.bin"]), ShouldEqual, "abcde")
+		})
+	})
+}
+
+func TestSecureFilePutStdin(t *testing.T) {
+	Convey("A put from standard input", t, func() {
+		server := newFakeSecureFileServer(map[string]string{})
+		ts := httptest.NewServer(server)
+		saved := stdin
+		Reset(func() {
+			ts.Close()
+			stdin = saved
+		})
+		Convey("Should upload the piped content", func() {
+			pr, pw := io.Pipe()
+			go func() {
+				pw.Write([]byte(`{"a": `))
+				pw.Write([]byte(`1}`))
+				pw.Close()
+			}()
+			stdin = pr
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			So(cl.SecureFile().PutStdin("app/sdb/config.json", "config.json"), ShouldBeNil)
+			So(string(server.files["app/sdb/config.json"]), ShouldEqual, `{"a": 1}`)
+		})
+		Convey("Should send the buffered content again on retries", func() {
+			var bodies []string
+			flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				bodies = append(bodies, string(body))
+				if len(bodies) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer flaky.Close()
+			stdin = strings.NewReader("piped")
+			cl := newRetryTestClient(flaky.URL, WithMaxRetries(1))
+			So(cl.SecureFile().PutStdin("app/sdb/piped.txt", "piped.txt"), ShouldBeNil)
+			So(bodies, ShouldHaveLength, 2)
+			So(bodies[1], ShouldEqual, bodies[0])
+			So(bodies[1], ShouldContainSubstring, "piped")
+		})
+	})
+}
+
+func TestSecureFilePutIdempotencyKey(t *testing.T) {
+	Convey("A put with idempotency keys enabled", t, func() {
+		var keys []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys = append(keys, r.Header.Get(idempotencyKeyHeader))
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer ts.Close()
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithIdempotencyKeys(true))
+		So(cl, ShouldNotBeNil)
+		Convey("Should send a different key for each upload", func() {
+			So(cl.SecureFile().Put("/test/file/hello.txt", "hello.txt", getTestInputReader(t, "hello")), ShouldBeNil)
+			So(cl.SecureFile().Put("/test/file/hello.txt", "hello.txt", getTestInputReader(t, "hello")), ShouldBeNil)
+			So(keys, ShouldHaveLength, 2)
+			So(keys[0], ShouldNotBeEmpty)
+			So(keys[1], ShouldNotBeEmpty)
+			So(keys[0], ShouldNotEqual, keys[1])
+		})
+	})
+
+	Convey("A put with idempotency keys disabled", t, func() {
+		var key string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key = r.Header.Get(idempotencyKeyHeader)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer ts.Close()
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should not send a key", func() {
+			So(cl.SecureFile().Put("/test/file/hello.txt", "hello.txt", getTestInputReader(t, "hello")), ShouldBeNil)
+			So(key, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestSecureFilePutContentType(t *testing.T) {
+	Convey("A put", t, func() {
+		var partType string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, header, err := r.FormFile("file-content")
+			if err == nil {
+				partType = header.Header.Get("Content-Type")
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer ts.Close()
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should detect the type from the file name", func() {
+			So(cl.SecureFile().Put("/test/file/config.json", "config.json", getTestInputReader(t, "{}")), ShouldBeNil)
+			So(partType, ShouldEqual, "application/json")
+		})
+		Convey("Should default to application/octet-stream", func() {
+			So(cl.SecureFile().Put("/test/file/hello", "hello", getTestInputReader(t, "hello")), ShouldBeNil)
+			So(partType, ShouldEqual, "application/octet-stream")
+		})
+		Convey("Should use an explicit type over the detected one", func() {
+			So(cl.SecureFile().PutWithContentType("/test/file/config.json", "config.json", "application/x-pem-file",
+				getTestInputReader(t, "{}")), ShouldBeNil)
+			So(partType, ShouldEqual, "application/x-pem-file")
+		})
+		Convey("Should detect the type when the explicit type is empty", func() {
+			So(cl.SecureFile().PutWithContentType("/test/file/config.json", "config.json", "",
+				getTestInputReader(t, "{}")), ShouldBeNil)
+			So(partType, ShouldEqual, "application/json")
+		})
+	})
+}
+
+func TestSecureFilePutFilename(t *testing.T) {
+	Convey("A put", t, func() {
+		var filename string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, header, err := r.FormFile("file-content")
+			if err == nil {
+				filename = header.Filename
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer ts.Close()
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		for _, name := range []string{"my secret file.txt", "résumé 日本語.pem", `a "quoted" name.txt`} {
+			Convey("Should send the file name "+name, func() {
+				So(cl.SecureFile().Put("/test/file/f", name, getTestInputReader(t, "hello")), ShouldBeNil)
+				So(filename, ShouldEqual, name)
+			})
+		}
+		Convey("Should send a name different from the one of the local file", func() {
+			f, err := ioutil.TempFile("", "cerberus-test-")
+			So(err, ShouldBeNil)
+			defer os.Remove(f.Name())
+			defer f.Close()
+			So(cl.SecureFile().Put("/test/file/f", "config.json", f), ShouldBeNil)
+			So(filename, ShouldEqual, "config.json")
+		})
+		for _, name := range []string{"dir/name.txt", `dir\name.txt`, "../name.txt"} {
+			Convey("Should reject the file name "+name, func() {
+				err := cl.SecureFile().Put("/test/file/f", name, getTestInputReader(t, "hello"))
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "must not contain a path separator")
+				So(filename, ShouldBeEmpty)
+			})
+		}
+		Convey("Should not let a line break end the header", func() {
+			So(cl.SecureFile().Put("/test/file/f", "bad\r\nX-Injected: 1.txt", getTestInputReader(t, "hello")), ShouldBeNil)
+			So(filename, ShouldEqual, "bad%0D%0AX-Injected: 1.txt")
+		})
+	})
+}
+
+// corruptingServer stores the uploaded files with their last byte changed
+type corruptingServer struct {
+	*fakeSecureFileServer
+}
+
+func (c corruptingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.fakeSecureFileServer.ServeHTTP(w, r)
+	if r.Method == http.MethodPost {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		for _, content := range c.files {
+			content[len(content)-1]++
+		}
+	}
+}
+
+func TestSecureFilePutVerify(t *testing.T) {
+	Convey("A put with verification enabled", t, func() {
+		server := newFakeSecureFileServer(map[string]string{})
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithVerifyAfterUpload(true))
+		Convey("Should download the file back", func() {
+			So(cl.SecureFile().Put("/test/file/hello.txt", "hello.txt", getTestInputReader(t, "hello")), ShouldBeNil)
+			So(server.downloads, ShouldEqual, 1)
+		})
+	})
+
+	Convey("A put with verification disabled", t, func() {
+		server := newFakeSecureFileServer(map[string]string{})
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		Convey("Should not download the file", func() {
+			So(cl.SecureFile().Put("/test/file/hello.txt", "hello.txt", getTestInputReader(t, "hello")), ShouldBeNil)
+			So(server.downloads, ShouldEqual, 0)
+		})
+	})
+
+	Convey("A put to a server corrupting files", t, func() {
+		ts := httptest.NewServer(corruptingServer{newFakeSecureFileServer(map[string]string{})})
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithVerifyAfterUpload(true))
+		Convey("Should fail verification", func() {
+			err := cl.SecureFile().Put("/test/file/hello.txt", "hello.txt", getTestInputReader(t, "hello"))
+			So(err, ShouldEqual, ErrorUploadVerificationFailed)
+		})
+	})
+}
+
+// secureFileListFor returns a list reply containing a single file with the given size and update time
+func secureFileListFor(filePath string, size int, updated string) string {
+	return fmt.Sprintf(`{
+	"has_next" : false,
+	"limit" : 1000,
+	"offset" : 0,
+	"file_count_in_result" : 1,
+	"total_file_count" : 1,
+	"secure_file_summaries" : [ {
+	  "path" : "%s",
+	  "size_in_bytes" : %d,
+	  "name" : "%s",
+	  "created_ts" : "2018-06-14T10:34:55.057Z",
+	  "last_updated_ts" : "%s"
+	} ]
+}`, filePath, size, path.Base(filePath), updated)
+}
+
+// truncatingServer stores the uploaded files without their last byte
+type truncatingServer struct {
+	*fakeSecureFileServer
+}
+
+func (t truncatingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.fakeSecureFileServer.ServeHTTP(w, r)
+	if r.Method == http.MethodPost {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+		for p, content := range t.files {
+			t.files[p] = content[:len(content)-1]
+		}
+	}
+}
+
+func TestSecureFilePutAndStat(t *testing.T) {
+	Convey("A put followed by a stat", t, func() {
+		server := newFakeSecureFileServer(map[string]string{})
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return the summary of the stored file", func() {
+			summary, err := cl.SecureFile().PutAndStat("app/sdb/a.txt", "a.txt", getTestInputReader(t, "hello"))
+			So(err, ShouldBeNil)
+			So(summary.Path, ShouldEqual, "app/sdb/a.txt")
+			So(summary.Size, ShouldEqual, 5)
+		})
+	})
+
+	Convey("A server storing incomplete files", t, func() {
+		server := truncatingServer{newFakeSecureFileServer(map[string]string{})}
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return ErrorUploadSizeMismatch with the summary", func() {
+			summary, err := cl.SecureFile().PutAndStat("app/sdb/a.txt", "a.txt", getTestInputReader(t, "hello"))
+			So(err, ShouldEqual, ErrorUploadSizeMismatch)
+			So(summary.Size, ShouldEqual, 4)
+		})
+	})
+}
+
+func TestSecureFileStat(t *testing.T) {
+	Convey("A valid call to Stat", t, WithTestServer(http.StatusOK, "/v1/secure-files/test/file/", http.MethodGet, secureFileListFor("test/file/hello.txt", 11, "2018-06-14T10:34:55.057Z"), func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return the summary of the file", func() {
+			summary, err := cl.SecureFile().Stat("/test/file/hello.txt")
+			So(err, ShouldBeNil)
+			So(summary.Name, ShouldEqual, "hello.txt")
+			So(summary.Size, ShouldEqual, 11)
+		})
+		Convey("Should return not found for a missing file", func() {
+			summary, err := cl.SecureFile().Stat("/test/file/other.txt")
+			So(err, ShouldEqual, ErrorSecureFileNotFound)
+			So(summary, ShouldBeNil)
+		})
+	}))
+
+	Convey("A folder listed in several pages", t, func() {
+		var offsets []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			offsets = append(offsets, r.URL.Query().Get("offset"))
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Query().Get("offset") {
+			case "0":
+				fmt.Fprint(w, `{"has_next": true, "next_offset": 1, "secure_file_summaries": [{"path": "test/file/first.txt", "name": "first.txt", "size_in_bytes": 1}]}`)
+			case "1":
+				fmt.Fprint(w, `{"has_next": false, "secure_file_summaries": [{"path": "test/file/hello.txt", "name": "hello.txt", "size_in_bytes": 11}]}`)
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should find a file on the second page", func() {
+			summary, err := cl.SecureFile().Stat("/test/file/hello.txt")
+			So(err, ShouldBeNil)
+			So(summary.Size, ShouldEqual, 11)
+			So(offsets, ShouldResemble, []string{"0", "1"})
+		})
+		Convey("Should stop listing once the file is found", func() {
+			summary, err := cl.SecureFile().Stat("/test/file/first.txt")
+			So(err, ShouldBeNil)
+			So(summary.Size, ShouldEqual, 1)
+			So(offsets, ShouldResemble, []string{"0"})
+		})
+		Convey("Should return not found after the last page", func() {
+			_, err := cl.SecureFile().Stat("/test/file/other.txt")
+			So(err, ShouldEqual, ErrorSecureFileNotFound)
+			So(offsets, ShouldHaveLength, 2)
+		})
+	})
+
+	Convey("A Stat to a non-responsive server", t, func() {
+		cl, _ := NewClient(GenerateMockAuth("http://127.0.0.1:32876", "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return an error", func() {
+			summary, err := cl.SecureFile().Stat("/test/file/hello.txt")
+			So(err, ShouldNotBeNil)
+			So(summary, ShouldBeNil)
+		})
+	})
+}
+
+func TestSecureFileWatch(t *testing.T) {
+	Convey("Watching a file that changes", t, func() {
+		var lock sync.Mutex
+		var listCalls int
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			defer lock.Unlock()
+			if strings.HasPrefix(r.URL.Path, secureFileListBasePath) {
+				listCalls++
+				updated := "2018-06-14T10:34:55.057Z"
+				// Change the file after the initial Stat
+				if listCalls > 1 {
+					updated = "2018-06-15T10:34:55.057Z"
+				}
+				w.Write([]byte(secureFileListFor("test/file/hello.txt", 11, updated)))
+				return
+			}
+			w.Write([]byte("new content"))
+		}))
+		defer ts.Close()
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should call the function with the new content", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var received []byte
+			err := cl.SecureFile().Watch(ctx, "/test/file/hello.txt", 10*time.Millisecond, func(b []byte) {
+				received = b
+				cancel()
+			})
+			So(err, ShouldEqual, context.Canceled)
+			So(string(received), ShouldEqual, "new content")
+		})
+		Convey("Should refuse an interval which is not positive without polling", func() {
+			for _, interval := range []time.Duration{0, -time.Second} {
+				err := cl.SecureFile().Watch(context.Background(), "/test/file/hello.txt", interval, func(b []byte) {})
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "interval must be positive")
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			So(listCalls, ShouldEqual, 0)
+		})
+	})
+
+	Convey("Watching a file that does not exist", t, WithTestServer(http.StatusOK, "/v1/secure-files/test/file/", http.MethodGet, secureFileListFor("test/file/hello.txt", 11, "2018-06-14T10:34:55.057Z"), func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return an error", func() {
+			err := cl.SecureFile().Watch(context.Background(), "/test/file/other.txt", 10*time.Millisecond, func(b []byte) {})
+			So(err, ShouldEqual, ErrorSecureFileNotFound)
+		})
+	}))
+}
+
+func TestSecureFileGetToFile(t *testing.T) {
+	Convey("A download to a local file", t, withBinaryTestServer(http.StatusOK,
+		"/v1/secure-file/test/file/hello.txt",
+		http.MethodGet,
+		"hello.txt",
+		[]byte("hello world"),
+		func(ts *httptest.Server) {
+			dir, err := ioutil.TempDir("", "cerberus-test")
+			So(err, ShouldBeNil)
+			Reset(func() {
+				os.RemoveAll(dir)
+			})
+			Convey("Should save the file in an existing directory", func() {
+				cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+				So(cl, ShouldNotBeNil)
+				localpath := filepath.Join(dir, "hello.txt")
+				So(cl.SecureFile().GetToFile("/test/file/hello.txt", localpath), ShouldBeNil)
+				content, err := ioutil.ReadFile(localpath)
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, "hello world")
+			})
+			Convey("Should create the file readable by the current user only", func() {
+				cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+				So(cl, ShouldNotBeNil)
+				localpath := filepath.Join(dir, "hello.txt")
+				So(cl.SecureFile().GetToFile("/test/file/hello.txt", localpath), ShouldBeNil)
+				info, err := os.Stat(localpath)
+				So(err, ShouldBeNil)
+				So(info.Mode().Perm(), ShouldEqual, os.FileMode(0600))
+			})
+			Convey("Should set the mode of an existing file", func() {
+				cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+				So(cl, ShouldNotBeNil)
+				localpath := filepath.Join(dir, "hello.txt")
+				So(ioutil.WriteFile(localpath, []byte("a longer previous content"), 0644), ShouldBeNil)
+				So(os.Chmod(localpath, 0644), ShouldBeNil)
+				So(cl.SecureFile().GetToFile("/test/file/hello.txt", localpath), ShouldBeNil)
+				info, err := os.Stat(localpath)
+				So(err, ShouldBeNil)
+				So(info.Mode().Perm(), ShouldEqual, os.FileMode(0600))
+				content, _ := ioutil.ReadFile(localpath)
+				So(string(content), ShouldEqual, "hello world")
+			})
+			Convey("Should use the mode set with WithDownloadFileMode", func() {
+				cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithDownloadFileMode(0644))
+				So(cl, ShouldNotBeNil)
+				localpath := filepath.Join(dir, "hello.txt")
+				So(cl.SecureFile().GetToFile("/test/file/hello.txt", localpath), ShouldBeNil)
+				info, err := os.Stat(localpath)
+				So(err, ShouldBeNil)
+				So(info.Mode().Perm(), ShouldEqual, os.FileMode(0644))
+			})
+			Convey("Should error when the directory is missing", func() {
+				cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+				So(cl, ShouldNotBeNil)
+				err := cl.SecureFile().GetToFile("/test/file/hello.txt", filepath.Join(dir, "missing", "hello.txt"))
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "does not exist")
+			})
+			Convey("Should create the directory when enabled", func() {
+				cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithCreateDirs(true))
+				So(cl, ShouldNotBeNil)
+				localpath := filepath.Join(dir, "missing", "hello.txt")
+				So(cl.SecureFile().GetToFile("/test/file/hello.txt", localpath), ShouldBeNil)
+				content, err := ioutil.ReadFile(localpath)
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, "hello world")
+			})
+		}))
+
+	Convey("A failed download to a local file", t, withBinaryTestServer(http.StatusInternalServerError,
+		"/v1/secure-file/test/file/hello.txt",
+		http.MethodGet,
+		"hello.txt",
+		[]byte("hello world"),
+		func(ts *httptest.Server) {
+			dir, err := ioutil.TempDir("", "cerberus-test")
+			So(err, ShouldBeNil)
+			Reset(func() {
+				os.RemoveAll(dir)
+			})
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			So(cl, ShouldNotBeNil)
+			Convey("Should error and not leave a file behind", func() {
+				localpath := filepath.Join(dir, "hello.txt")
+				So(cl.SecureFile().GetToFile("/test/file/hello.txt", localpath), ShouldNotBeNil)
+				_, err := os.Stat(localpath)
+				So(os.IsNotExist(err), ShouldBeTrue)
+			})
+		}))
+}
+
+// fakeSecureFileServer is an in memory implementation of the secure file endpoints
+type fakeSecureFileServer struct {
+	lock      sync.Mutex
+	files     map[string][]byte
+	downloads int
+	uploads   int
+	// updated is the last updated time of every file
+	updated time.Time
+}
+
+func newFakeSecureFileServer(files map[string]string) *fakeSecureFileServer {
+	f := &fakeSecureFileServer{files: map[string][]byte{}}
+	for k, v := range files {
+		f.files[k] = []byte(v)
+	}
+	return f
+}
+
+func (f *fakeSecureFileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	switch {
+	case strings.HasPrefix(r.URL.Path, secureFileListBasePath+"/"):
+		prefix := strings.Trim(strings.TrimPrefix(r.URL.Path, secureFileListBasePath), "/")
+		resp := api.SecureFilesResponse{Summaries: []api.SecureFileSummary{}}
+		for p, content := range f.files {
+			if prefix == "" || strings.HasPrefix(p, prefix+"/") {
+				resp.Summaries = append(resp.Summaries, api.SecureFileSummary{
+					Name:        path.Base(p),
+					Path:        p,
+					Size:        len(content),
+					LastUpdated: f.updated,
+				})
+			}
+		}
+		resp.ResultCount = len(resp.Summaries)
+		resp.TotalCount = len(resp.Summaries)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	case strings.HasPrefix(r.URL.Path, secureFileBasePath+"/"):
+		p := strings.Trim(strings.TrimPrefix(r.URL.Path, secureFileBasePath), "/")
+		switch r.Method {
+		case http.MethodGet:
+			content, ok := f.files[p]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			f.downloads++
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(content)
+		case http.MethodPost:
+			file, _, err := r.FormFile("file-content")
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			content, _ := ioutil.ReadAll(file)
+			f.files[p] = content
+			f.uploads++
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			if _, ok := f.files[p]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(f.files, p)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestSecureFileGetTempFile(t *testing.T) {
+	Convey("A secure file downloaded to a temporary file", t, func() {
+		server := newFakeSecureFileServer(map[string]string{"app/sdb/key.pem": "a private key"})
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should be readable by the current user only until cleaned up", func() {
+			localpath, cleanup, err := cl.SecureFile().GetTempFile("app/sdb/key.pem")
+			So(err, ShouldBeNil)
+			So(filepath.Dir(localpath), ShouldEqual, filepath.Clean(os.TempDir()))
+			content, err := ioutil.ReadFile(localpath)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "a private key")
+			info, err := os.Stat(localpath)
+			So(err, ShouldBeNil)
+			So(info.Mode().Perm(), ShouldEqual, os.FileMode(0600))
+			So(cleanup(), ShouldBeNil)
+			_, err = os.Stat(localpath)
+			So(os.IsNotExist(err), ShouldBeTrue)
+			So(cleanup(), ShouldBeNil)
+		})
+		Convey("Should not leave a file behind when the download fails", func() {
+			before, _ := filepath.Glob(filepath.Join(os.TempDir(), "cerberus-secure-file-*"))
+			localpath, cleanup, err := cl.SecureFile().GetTempFile("app/sdb/missing.pem")
+			So(err, ShouldNotBeNil)
+			So(localpath, ShouldBeEmpty)
+			So(cleanup, ShouldBeNil)
+			after, _ := filepath.Glob(filepath.Join(os.TempDir(), "cerberus-secure-file-*"))
+			So(after, ShouldResemble, before)
+		})
+	})
+}
+
+func TestSecureFilePathPrefix(t *testing.T) {
+	Convey("A client with a secure path prefix", t, func() {
+		server := newFakeSecureFileServer(map[string]string{
+			"app/my-sdb/hello.txt":    "hello",
+			"app/other-sdb/world.txt": "world",
+		})
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithSecurePathPrefix("app/my-sdb"))
+		So(cl, ShouldNotBeNil)
+		Convey("Should prefix the path of Get", func() {
+			var buf bytes.Buffer
+			So(cl.SecureFile().Get("hello.txt", &buf), ShouldBeNil)
+			So(buf.String(), ShouldEqual, "hello")
+		})
+		Convey("Should prefix the path of Put", func() {
+			So(cl.SecureFile().Put("/new.txt", "new.txt", getTestInputReader(t, "new")), ShouldBeNil)
+			So(string(server.files["app/my-sdb/new.txt"]), ShouldEqual, "new")
+		})
+		Convey("Should prefix the path of List", func() {
+			files, err := cl.SecureFile().List("")
+			So(err, ShouldBeNil)
+			So(files.Summaries, ShouldHaveLength, 1)
+			So(files.Summaries[0].Path, ShouldEqual, "app/my-sdb/hello.txt")
+		})
+		Convey("Should prefix the path of Stat", func() {
+			summary, err := cl.SecureFile().Stat("hello.txt")
+			So(err, ShouldBeNil)
+			So(summary.Size, ShouldEqual, 5)
+		})
+		Convey("Should prefix the path of Delete", func() {
+			So(cl.SecureFile().Delete("hello.txt"), ShouldBeNil)
+			So(server.files, ShouldNotContainKey, "app/my-sdb/hello.txt")
+		})
+		Convey("Should prefix the URL", func() {
+			So(cl.SecureFile().URL("hello.txt"), ShouldEqual, ts.URL+"/v1/secure-file/app/my-sdb/hello.txt")
+		})
+		Convey("Should not prefix absolute paths", func() {
+			var buf bytes.Buffer
+			So(cl.SecureFile().Get("//app/other-sdb/world.txt", &buf), ShouldBeNil)
+			So(buf.String(), ShouldEqual, "world")
+			summary, err := cl.SecureFile().Stat("//app/other-sdb/world.txt")
+			So(err, ShouldBeNil)
+			So(summary.Path, ShouldEqual, "app/other-sdb/world.txt")
+		})
+		Convey("Should clean the joined path", func() {
+			So(cl.SecureFile().URL("../other-sdb/./world.txt"), ShouldEqual, ts.URL+"/v1/secure-file/app/other-sdb/world.txt")
+		})
+	})
+}
+
+func TestSecureFileListAll(t *testing.T) {
+	Convey("A folder with several pages of files", t, func() {
+		var offsets []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			offsets = append(offsets, r.FormValue("offset"))
+			if r.FormValue("offset") == "0" {
+				w.Write([]byte(`{"has_next": true, "next_offset": 1, "secure_file_summaries": [{"path": "app/sdb/a.txt"}]}`))
+				return
+			}
+			w.Write([]byte(`{"has_next": false, "secure_file_summaries": [{"path": "app/sdb/b.txt"}]}`))
+		}))
+		defer ts.Close()
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return the files of all pages", func() {
+			files, err := cl.SecureFile().ListAll("app/sdb")
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 2)
+			So(files[1].Path, ShouldEqual, "app/sdb/b.txt")
+			So(offsets, ShouldResemble, []string{"0", "1"})
+		})
+	})
+
+	Convey("An invalid call to ListAll", t, WithTestServer(http.StatusInternalServerError, "/v1/secure-files/my/sdb", http.MethodGet, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should error", func() {
+			files, err := cl.SecureFile().ListAll("my/sdb")
+			So(err, ShouldNotBeNil)
+			So(files, ShouldBeNil)
+		})
+	}))
+}
+
+var secureFileModifiedReply = `{
+	"has_next" : false,
+	"secure_file_summaries" : [
+	  { "path" : "my/sdb/old.txt", "name" : "old.txt", "last_updated_ts" : "2018-06-14T10:34:55Z" },
+	  { "path" : "my/sdb/new.txt", "name" : "new.txt", "last_updated_ts" : "2018-06-15T08:00:00Z" }
+	]
+  }`
+
+func TestSecureFileListModifiedSince(t *testing.T) {
+	Convey("A call to ListModifiedSince", t, WithTestServer(http.StatusOK, "/v1/secure-files/my/sdb", http.MethodGet, secureFileModifiedReply, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return the files updated after the time", func() {
+			files, err := cl.SecureFile().ListModifiedSince("my/sdb", time.Date(2018, 6, 14, 12, 0, 0, 0, time.UTC))
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 1)
+			So(files[0].Path, ShouldEqual, "my/sdb/new.txt")
+		})
+		Convey("Should not return files updated at the time", func() {
+			files, err := cl.SecureFile().ListModifiedSince("my/sdb", time.Date(2018, 6, 15, 8, 0, 0, 0, time.UTC))
+			So(err, ShouldBeNil)
+			So(files, ShouldBeEmpty)
+		})
+	}))
+
+	Convey("A failing call to ListModifiedSince", t, WithTestServer(http.StatusInternalServerError, "/v1/secure-files/my/sdb", http.MethodGet, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should error", func() {
+			files, err := cl.SecureFile().ListModifiedSince("my/sdb", time.Time{})
+			So(err, ShouldNotBeNil)
+			So(files, ShouldBeNil)
+		})
+	}))
+}
+
+func TestSecureFileDownload(t *testing.T) {
+	Convey("A download with a default download directory", t, withBinaryTestServer(http.StatusOK,
+		"/v1/secure-file/test/file/hello.txt",
+		http.MethodGet,
+		"hello.txt",
+		[]byte("hello world"),
+		func(ts *httptest.Server) {
+			dir, err := ioutil.TempDir("", "cerberus-test")
+			So(err, ShouldBeNil)
+			Reset(func() {
+				os.RemoveAll(dir)
+			})
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithDownloadDir(dir))
+			So(cl, ShouldNotBeNil)
+			Convey("Should save the file in the download directory", func() {
+				localpath, err := cl.SecureFile().Download("/test/file/hello.txt")
+				So(err, ShouldBeNil)
+				So(localpath, ShouldEqual, filepath.Join(dir, "hello.txt"))
+				content, err := ioutil.ReadFile(localpath)
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, "hello world")
+			})
+			Convey("Should use the download directory when no local path is given", func() {
+				So(cl.SecureFile().GetToFile("/test/file/hello.txt", ""), ShouldBeNil)
+				_, err := os.Stat(filepath.Join(dir, "hello.txt"))
+				So(err, ShouldBeNil)
+			})
+			Convey("Should prefer an explicit local path", func() {
+				localpath := filepath.Join(dir, "other.txt")
+				So(cl.SecureFile().GetToFile("/test/file/hello.txt", localpath), ShouldBeNil)
+				_, err := os.Stat(localpath)
+				So(err, ShouldBeNil)
+				_, err = os.Stat(filepath.Join(dir, "hello.txt"))
+				So(os.IsNotExist(err), ShouldBeTrue)
+			})
+		}))
+}
+
+func TestSecureFileDelete(t *testing.T) {
+	Convey("A valid call to Delete", t, WithTestServer(http.StatusNoContent, "/v1/secure-file/test/file/hello.txt", http.MethodDelete, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should not error", func() {
+			So(cl.SecureFile().Delete("/test/file/hello.txt"), ShouldBeNil)
+		})
+	}))
+
+	Convey("A Delete of a missing file", t, WithTestServer(http.StatusNotFound, "/v1/secure-file/test/file/hello.txt", http.MethodDelete, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return not found", func() {
+			So(cl.SecureFile().Delete("/test/file/hello.txt"), ShouldEqual, ErrorSecureFileNotFound)
+		})
+	}))
+
+	Convey("An invalid call to Delete", t, WithTestServer(http.StatusInternalServerError, "/v1/secure-file/test/file/hello.txt", http.MethodDelete, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should error", func() {
+			So(cl.SecureFile().Delete("/test/file/hello.txt"), ShouldNotBeNil)
+		})
+	}))
+}
+
+// pagedListServer returns one file per page, for the given number of pages
+func pagedListServer(pages int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var offset int
+		fmt.Sscanf(r.FormValue("offset"), "%d", &offset)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"has_next": %t, "next_offset": %d, "secure_file_summaries": [{"path": "app/sdb/%d.txt"}]}`,
+			offset+1 < pages, offset+1, offset)
+	}))
+}
+
+func TestSecureFileDeleteIfUnchanged(t *testing.T) {
+	Convey("A secure file", t, func() {
+		server := newFakeSecureFileServer(map[string]string{"app/sdb/a.txt": "hello"})
+		server.updated = time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		summary, err := cl.SecureFile().Stat("app/sdb/a.txt")
+		So(err, ShouldBeNil)
+		Convey("Should delete the file if it did not change", func() {
+			So(cl.SecureFile().DeleteIfUnchanged("app/sdb/a.txt", *summary), ShouldBeNil)
+			So(server.files, ShouldNotContainKey, "app/sdb/a.txt")
+		})
+		Convey("Should return ErrorVersionConflict if it was updated", func() {
+			server.updated = server.updated.Add(time.Minute)
+			So(cl.SecureFile().DeleteIfUnchanged("app/sdb/a.txt", *summary), ShouldEqual, ErrorVersionConflict)
+			So(server.files, ShouldContainKey, "app/sdb/a.txt")
+		})
+		Convey("Should return ErrorVersionConflict if its size changed", func() {
+			server.files["app/sdb/a.txt"] = []byte("hello world")
+			So(cl.SecureFile().DeleteIfUnchanged("app/sdb/a.txt", *summary), ShouldEqual, ErrorVersionConflict)
+		})
+		Convey("Should return ErrorSecureFileNotFound if it was deleted", func() {
+			delete(server.files, "app/sdb/a.txt")
+			So(cl.SecureFile().DeleteIfUnchanged("app/sdb/a.txt", *summary), ShouldEqual, ErrorSecureFileNotFound)
+		})
+	})
+}
+
+func TestSecureFileListChan(t *testing.T) {
+	Convey("A folder with several pages of files", t, func() {
+		ts := pagedListServer(3)
+		defer ts.Close()
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should send every file on the channel", func() {
+			summaries, errs := cl.SecureFile().ListChan(context.Background(), "app/sdb")
+			var paths []string
+			for summary := range summaries {
+				paths = append(paths, summary.Path)
+			}
+			So(<-errs, ShouldBeNil)
+			So(paths, ShouldResemble, []string{"app/sdb/0.txt", "app/sdb/1.txt", "app/sdb/2.txt"})
+		})
+		Convey("Should stop when the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			summaries, errs := cl.SecureFile().ListChan(ctx, "app/sdb")
+			first := <-summaries
+			So(first.Path, ShouldEqual, "app/sdb/0.txt")
+			cancel()
+			for range summaries {
+			}
+			So(<-errs, ShouldEqual, context.Canceled)
+		})
+	})
+
+	Convey("An invalid call to ListChan", t, WithTestServer(http.StatusInternalServerError, "/v1/secure-files/my/sdb", http.MethodGet, "", func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should send the error", func() {
+			summaries, errs := cl.SecureFile().ListChan(context.Background(), "my/sdb")
+			for range summaries {
+			}
+			So(<-errs, ShouldNotBeNil)
+		})
+	}))
+}
+
+func TestSecureFileIterate(t *testing.T) {
+	Convey("Iterating over several pages of files", t, func() {
+		ts := pagedListServer(3)
+		defer ts.Close()
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should stop when the function returns an error", func() {
+			var count int
+			stop := fmt.Errorf("stop")
+			err := cl.SecureFile().Iterate("app/sdb", func(summary api.SecureFileSummary) error {
+				count++
+				return stop
+			})
+			So(err, ShouldEqual, stop)
+			So(count, ShouldEqual, 1)
+		})
+	})
+}
+
+func TestSecureFileListPageSize(t *testing.T) {
+	Convey("A server recording the requested page sizes", t, func() {
+		var limits []string
+		var lock sync.Mutex
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			limits = append(limits, r.FormValue("limit"))
+			lock.Unlock()
+			var offset int
+			fmt.Sscanf(r.FormValue("offset"), "%d", &offset)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"has_next": %t, "next_offset": %d, "secure_file_summaries": [{"path": "app/sdb/%d.txt"}]}`,
+				offset+1 < 2, offset+1, offset)
+		}))
+		defer ts.Close()
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should request pages of the given size when iterating", func() {
+			var count int
+			err := cl.SecureFile().IterateWithPageSize("app/sdb", 25, func(summary api.SecureFileSummary) error {
+				count++
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 2)
+			So(limits, ShouldResemble, []string{"25", "25"})
+		})
+		Convey("Should request pages of the given size on a channel", func() {
+			summaries, errs := cl.SecureFile().ListChanWithPageSize(context.Background(), "app/sdb", 1)
+			var count int
+			for range summaries {
+				count++
+			}
+			So(<-errs, ShouldBeNil)
+			So(count, ShouldEqual, 2)
+			lock.Lock()
+			defer lock.Unlock()
+			So(limits, ShouldResemble, []string{"1", "1"})
+		})
+		Convey("Should use the maximum page size by default", func() {
+			So(cl.SecureFile().Iterate("app/sdb", func(api.SecureFileSummary) error { return nil }), ShouldBeNil)
+			So(limits[0], ShouldEqual, fmt.Sprintf("%d", MaxListPageSize))
+		})
+		Convey("Should reject invalid page sizes without listing", func() {
+			err := cl.SecureFile().IterateWithPageSize("app/sdb", 0, func(api.SecureFileSummary) error { return nil })
+			So(err, ShouldNotBeNil)
+			summaries, errs := cl.SecureFile().ListChanWithPageSize(context.Background(), "app/sdb", MaxListPageSize+1)
+			for range summaries {
+			}
+			So(<-errs, ShouldNotBeNil)
+			So(limits, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestSecureFileListCursor(t *testing.T) {
+	Convey("A server paging with opaque cursors", t, func() {
+		var cursors []string
+		var lock sync.Mutex
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			cursors = append(cursors, r.FormValue("offset"))
+			lock.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			if r.FormValue("offset") == "0" {
+				fmt.Fprint(w, `{"has_next": true, "next_offset": "b64+/token==", "secure_file_summaries": [{"path": "app/sdb/0.txt"}]}`)
+				return
+			}
+			fmt.Fprint(w, `{"has_next": false, "next_offset": null, "secure_file_summaries": [{"path": "app/sdb/1.txt"}]}`)
+		}))
+		defer ts.Close()
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should pass the cursor back verbatim", func() {
+			resp, err := cl.SecureFile().ListAll("app/sdb")
+			So(err, ShouldBeNil)
+			So(summaryPaths(resp), ShouldResemble, []string{"app/sdb/0.txt", "app/sdb/1.txt"})
+			So(cursors, ShouldResemble, []string{"0", "b64+/token=="})
+		})
+	})
+}
+
+func TestSecureFileURL(t *testing.T) {
+	Convey("A client", t, func() {
+		cl, _ := NewClient(GenerateMockAuth("https://cerberus.example.com", "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return the full URL of a secure file", func() {
+			So(cl.SecureFile().URL("/app/sdb//sub/../hello.txt"), ShouldEqual, "https://cerberus.example.com/v1/secure-file/app/sdb/hello.txt")
+		})
+	})
+}
+
+func TestSecureFilePaginationParams(t *testing.T) {
+	Convey("A ListAll with custom pagination parameters", t, WithServer(http.StatusOK, false, "/v1/secure-files/app/sdb", http.MethodGet, "", map[string]string{"per_page": "1000", "page": "0"}, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithPaginationParams("per_page", "page"))
+		So(cl, ShouldNotBeNil)
+		Convey("Should use the configured parameter names", func() {
+			_, err := cl.SecureFile().ListAll("app/sdb")
+			So(err, ShouldBeNil)
+		})
+	}))
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Nike-Inc/cerberus-go-client/api"
+)
+
+// SortField is a field secure files can be sorted by with ListSorted
+type SortField int
+
+const (
+	// SortByName sorts secure files by name
+	SortByName SortField = iota
+	// SortBySize sorts secure files by size
+	SortBySize
+	// SortByLastUpdated sorts secure files by their last updated time
+	SortByLastUpdated
+)
+
+// ListSorted returns the summaries of all secure files under rootpath sorted by the given field, in
+// descending order if desc is true. Cerberus does not support sorting, so all pages are requested and
+// sorted by the client. Files with the same value are sorted by path in the same direction, so the order
+// is always the same
+func (r *SecureFile) ListSorted(rootpath string, by SortField, desc bool) ([]api.SecureFileSummary, error) {
+	var less func(a, b *api.SecureFileSummary) bool
+	switch by {
+	case SortByName:
+		less = func(a, b *api.SecureFileSummary) bool { return a.Name < b.Name }
+	case SortBySize:
+		less = func(a, b *api.SecureFileSummary) bool { return a.Size < b.Size }
+	case SortByLastUpdated:
+		less = func(a, b *api.SecureFileSummary) bool { return a.LastUpdated.Before(b.LastUpdated) }
+	default:
+		return nil, fmt.Errorf("unknown sort field %d", by)
+	}
+	summaries, err := r.ListAll(rootpath)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := &summaries[i], &summaries[j]
+		if desc {
+			a, b = b, a
+		}
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return a.Path < b.Path
+	})
+	return summaries, nil
+}
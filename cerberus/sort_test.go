@@ -0,0 +1,77 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Nike-Inc/cerberus-go-client/api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func summaryPaths(summaries []api.SecureFileSummary) []string {
+	var paths = []string{}
+	for _, s := range summaries {
+		paths = append(paths, s.Path)
+	}
+	return paths
+}
+
+func TestListSorted(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2018, 6, d, 0, 0, 0, 0, time.UTC)
+	}
+	Convey("A folder of secure files", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(api.SecureFilesResponse{Summaries: []api.SecureFileSummary{
+				{Name: "b.txt", Path: "app/sdb/b.txt", Size: 10, LastUpdated: day(3)},
+				{Name: "c.txt", Path: "app/sdb/c.txt", Size: 5, LastUpdated: day(1)},
+				{Name: "a.txt", Path: "app/sdb/sub/a.txt", Size: 10, LastUpdated: day(2)},
+				{Name: "a.txt", Path: "app/sdb/a.txt", Size: 20, LastUpdated: day(2)},
+			}})
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should sort by name, then path", func() {
+			files, err := cl.SecureFile().ListSorted("app/sdb", SortByName, false)
+			So(err, ShouldBeNil)
+			So(summaryPaths(files), ShouldResemble, []string{"app/sdb/a.txt", "app/sdb/sub/a.txt", "app/sdb/b.txt", "app/sdb/c.txt"})
+		})
+		Convey("Should sort by size in descending order", func() {
+			files, err := cl.SecureFile().ListSorted("app/sdb", SortBySize, true)
+			So(err, ShouldBeNil)
+			So(summaryPaths(files), ShouldResemble, []string{"app/sdb/a.txt", "app/sdb/sub/a.txt", "app/sdb/b.txt", "app/sdb/c.txt"})
+		})
+		Convey("Should sort by last updated time", func() {
+			files, err := cl.SecureFile().ListSorted("app/sdb", SortByLastUpdated, false)
+			So(err, ShouldBeNil)
+			So(summaryPaths(files), ShouldResemble, []string{"app/sdb/c.txt", "app/sdb/a.txt", "app/sdb/sub/a.txt", "app/sdb/b.txt"})
+		})
+		Convey("Should reject an unknown field", func() {
+			_, err := cl.SecureFile().ListSorted("app/sdb", SortField(42), false)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
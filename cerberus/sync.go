@@ -0,0 +1,493 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Nike-Inc/cerberus-go-client/api"
+)
+
+// SyncOptions controls how GetDir and PutDir transfer files
+type SyncOptions struct {
+	// SkipUnchanged skips files whose local and remote sizes are the same
+	SkipUnchanged bool
+	// CompareChecksums also compares the SHA-256 of files with the same size before skipping them.
+	// Cerberus does not expose checksums, so the remote file is downloaded to compute it. This
+	// saves local writes in GetDir and uploads in PutDir, but not downloads
+	CompareChecksums bool
+	// Ignore lists patterns of local paths PutDir does not upload, matched with path.Match against the
+	// slash separated path relative to the local directory. Like in a .gitignore, a pattern without a
+	// slash matches a file or directory name at any depth, such as ".git" or "*.swp". Ignoring a
+	// directory ignores everything under it
+	Ignore []string
+	// SkipDotfiles makes PutDir skip files and directories whose name starts with a dot
+	SkipDotfiles bool
+}
+
+// ignored returns whether PutDir skips the file or directory at rel, relative to the local directory
+func (opts SyncOptions) ignored(rel string) (bool, error) {
+	name := path.Base(rel)
+	if opts.SkipDotfiles && strings.HasPrefix(name, ".") {
+		return true, nil
+	}
+	for _, pattern := range opts.Ignore {
+		target := rel
+		if !strings.Contains(pattern, "/") {
+			target = name
+		}
+		matched, err := path.Match(strings.TrimPrefix(pattern, "/"), target)
+		if err != nil {
+			return false, fmt.Errorf("invalid ignore pattern %q: %v", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SyncResult is the outcome of GetDir or PutDir for each file. Files are identified by their source path:
+// the secure file path for GetDir and the local path for PutDir
+type SyncResult struct {
+	// Transferred lists the files that were downloaded or uploaded
+	Transferred []string
+	// Skipped lists the files left as is because they were unchanged, see SyncOptions.SkipUnchanged
+	Skipped []string
+	// Failed holds the error of each file that could not be transferred
+	Failed map[string]error
+}
+
+func newSyncResult() *SyncResult {
+	return &SyncResult{
+		Transferred: []string{},
+		Skipped:     []string{},
+		Failed:      map[string]error{},
+	}
+}
+
+// add records the outcome for a file
+func (s *SyncResult) add(p string, transferred bool, err error) {
+	switch {
+	case err != nil:
+		s.Failed[p] = err
+	case transferred:
+		s.Transferred = append(s.Transferred, p)
+	default:
+		s.Skipped = append(s.Skipped, p)
+	}
+}
+
+// Changed returns whether any file was transferred
+func (s *SyncResult) Changed() bool {
+	return len(s.Transferred) > 0
+}
+
+// Err returns an error describing all failed files, or nil if there are none. The error of each file
+// can be matched with errors.Is and errors.As
+func (s *SyncResult) Err() error {
+	var merr = &multiError{total: len(s.Transferred) + len(s.Skipped) + len(s.Failed)}
+	for p, err := range s.Failed {
+		merr.add(p, err)
+	}
+	return merr.errorOrNil()
+}
+
+// GetDir downloads all secure files under secureRootPath into localDir, keeping their path
+// relative to secureRootPath. Missing directories are created. All files are attempted even if
+// some fail; the returned error then lists the failures
+func (r *SecureFile) GetDir(secureRootPath, localDir string, opts SyncOptions) error {
+	result, err := r.GetDirWithResult(secureRootPath, localDir, opts)
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
+
+// GetDirWithResult is GetDir, returning what happened to each file. The error is only set if the files
+// could not be listed; the failures of single files are in the result
+func (r *SecureFile) GetDirWithResult(secureRootPath, localDir string, opts SyncOptions) (*SyncResult, error) {
+	root := r.resolvePath(secureRootPath)
+	summaries, err := r.ListAll(absoluteSecurePath(root))
+	if err != nil {
+		return nil, err
+	}
+	var result = newSyncResult()
+	for _, summary := range summaries {
+		rel, err := relativeSecurePath(root, summary.Path)
+		if err != nil {
+			result.add(summary.Path, false, err)
+			continue
+		}
+		localpath := filepath.Join(localDir, filepath.FromSlash(rel))
+		transferred, err := r.getDirFile(summary, localpath, opts)
+		result.add(summary.Path, transferred, err)
+	}
+	return result, nil
+}
+
+// getDirFile downloads a single file for GetDir unless it can be skipped, and returns whether it was written
+func (r *SecureFile) getDirFile(summary api.SecureFileSummary, localpath string, opts SyncOptions) (bool, error) {
+	if opts.SkipUnchanged {
+		info, err := os.Stat(localpath)
+		if err == nil && info.Size() == int64(summary.Size) {
+			if !opts.CompareChecksums {
+				return false, nil
+			}
+			var content bytes.Buffer
+			if err := r.Get(absoluteSecurePath(summary.Path), &content); err != nil {
+				return false, err
+			}
+			same, err := sameChecksum(localpath, content.Bytes())
+			if err != nil || same {
+				return false, err
+			}
+			return true, r.writeLocalFile(localpath, content.Bytes())
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(localpath), 0755); err != nil {
+		return false, err
+	}
+	f, err := r.createLocalFile(localpath)
+	if err != nil {
+		return false, err
+	}
+	if err := r.Get(absoluteSecurePath(summary.Path), f); err != nil {
+		f.Close()
+		// Don't leave a partial file behind
+		os.Remove(localpath)
+		return false, err
+	}
+	return true, f.Close()
+}
+
+// writeLocalFile writes content to the local file at localpath like a download
+func (r *SecureFile) writeLocalFile(localpath string, content []byte) error {
+	f, err := r.createLocalFile(localpath)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// GetIfNewer downloads a secure file to destFilePath like GetToFile, unless the local file was modified
+// after the secure file was last updated. It returns whether the file was downloaded. The modification time
+// of the downloaded file is set to the last updated time of the secure file, so it is not downloaded again
+// until the secure file changes, even if the local and server clocks differ
+func (r *SecureFile) GetIfNewer(secureFilePath, destFilePath string) (bool, error) {
+	summary, err := r.Stat(secureFilePath)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(destFilePath)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if err == nil && !summary.LastUpdated.IsZero() && !summary.LastUpdated.After(info.ModTime()) {
+		return false, nil
+	}
+	if err := r.GetToFile(secureFilePath, destFilePath); err != nil {
+		return false, err
+	}
+	if !summary.LastUpdated.IsZero() {
+		if err := os.Chtimes(destFilePath, summary.LastUpdated, summary.LastUpdated); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// PutDir uploads all regular files under localDir to secureBasePath, keeping their path
+// relative to localDir. Files can be excluded with the Ignore and SkipDotfiles options. All files are
+// attempted even if some fail; the returned error then lists the failures
+func (r *SecureFile) PutDir(localDir, secureBasePath string, opts SyncOptions) error {
+	result, err := r.PutDirWithResult(localDir, secureBasePath, opts)
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
+
+// PutDirWithResult is PutDir, returning what happened to each file. Ignored files are not part of the
+// result. The error is only set if the files could not be listed; the failures of single files are in the result
+func (r *SecureFile) PutDirWithResult(localDir, secureBasePath string, opts SyncOptions) (*SyncResult, error) {
+	base := r.resolvePath(secureBasePath)
+	var remote = map[string]api.SecureFileSummary{}
+	if opts.SkipUnchanged {
+		summaries, err := r.ListAll(absoluteSecurePath(base))
+		if err != nil {
+			return nil, err
+		}
+		for _, summary := range summaries {
+			remote[strings.Trim(summary.Path, "/")] = summary
+		}
+	}
+	var result = newSyncResult()
+	err := filepath.Walk(localDir, func(localpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localDir, localpath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." {
+			ignored, err := opts.ignored(rel)
+			if err != nil {
+				return err
+			}
+			if ignored && info.IsDir() {
+				return filepath.SkipDir
+			}
+			if ignored {
+				return nil
+			}
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		transferred, err := r.putDirFile(localpath, path.Join(base, rel), info, remote, opts)
+		result.add(localpath, transferred, err)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// VerifyDir checks that all regular files under localDir were uploaded to secureBasePath, for example after
+// PutDir. It returns the files missing from secureBasePath and the ones whose secure file has a different
+// size, both as paths relative to localDir using slashes, sorted. Secure files without a local file are not
+// reported. Only sizes are compared, so files changed without changing size are not detected
+func (r *SecureFile) VerifyDir(secureBasePath, localDir string) ([]string, []string, error) {
+	base := r.resolvePath(secureBasePath)
+	summaries, err := r.ListAll(absoluteSecurePath(base))
+	if err != nil {
+		return nil, nil, err
+	}
+	var remote = make(map[string]api.SecureFileSummary, len(summaries))
+	for _, summary := range summaries {
+		remote[strings.Trim(summary.Path, "/")] = summary
+	}
+	var missing = []string{}
+	var sizeMismatch = []string{}
+	err = filepath.Walk(localDir, func(localpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(localDir, localpath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		summary, ok := remote[strings.Trim(path.Join(base, rel), "/")]
+		switch {
+		case !ok:
+			missing = append(missing, rel)
+		case int64(summary.Size) != info.Size():
+			sizeMismatch = append(sizeMismatch, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error while verifying %s: %v", localDir, err)
+	}
+	sort.Strings(missing)
+	sort.Strings(sizeMismatch)
+	return missing, sizeMismatch, nil
+}
+
+// putDirFile uploads a single file for PutDir unless it can be skipped, and returns whether it was uploaded
+func (r *SecureFile) putDirFile(localpath, secureFilePath string, info os.FileInfo, remote map[string]api.SecureFileSummary, opts SyncOptions) (bool, error) {
+	if summary, ok := remote[strings.Trim(secureFilePath, "/")]; ok && int64(summary.Size) == info.Size() {
+		skip := true
+		if opts.CompareChecksums {
+			var content bytes.Buffer
+			if err := r.Get(absoluteSecurePath(secureFilePath), &content); err != nil {
+				return false, err
+			}
+			var err error
+			if skip, err = sameChecksum(localpath, content.Bytes()); err != nil {
+				return false, err
+			}
+		}
+		if skip {
+			return false, nil
+		}
+	}
+	f, err := os.Open(localpath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	return true, r.Put(absoluteSecurePath(secureFilePath), info.Name(), f)
+}
+
+// ErrorBulkDeleteDisabled is returned by DeletePrefix when the client was not created with WithBulkDelete(true)
+var ErrorBulkDeleteDisabled = fmt.Errorf("bulk delete is disabled. Create the client using WithBulkDelete(true) to enable it")
+
+// DeletePrefix deletes all secure files under rootpath using up to concurrency parallel requests and returns
+// the number of deleted files. As a safety measure, the client must be created using WithBulkDelete(true)
+// and rootpath cannot be empty. All files are attempted even if some fail; the returned error then lists the failures
+func (r *SecureFile) DeletePrefix(rootpath string, concurrency int) (int, error) {
+	if !r.c.bulkDelete {
+		return 0, ErrorBulkDeleteDisabled
+	}
+	if strings.Trim(r.resolvePath(rootpath), "/") == "" {
+		return 0, fmt.Errorf("refusing to delete all secure files: rootpath cannot be empty")
+	}
+	summaries, err := r.ListAll(rootpath)
+	if err != nil {
+		return 0, err
+	}
+	var paths = make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		paths = append(paths, absoluteSecurePath(summary.Path))
+	}
+	result := r.DeleteBatch(paths, concurrency)
+	return len(result.Succeeded), result.Err()
+}
+
+// relativeSecurePath returns the path of a secure file relative to a root path. Paths which would escape
+// the root once cleaned, such as root/../file, are rejected so they cannot be written outside a local directory
+func relativeSecurePath(rootpath, secureFilePath string) (string, error) {
+	root := strings.Trim(rootpath, "/")
+	p := strings.Trim(secureFilePath, "/")
+	if root != "" {
+		if !strings.HasPrefix(p, root+"/") {
+			return "", fmt.Errorf("secure file %s is not under %s", secureFilePath, rootpath)
+		}
+		p = strings.TrimPrefix(p, root+"/")
+	}
+	rel := path.Clean(p)
+	if rel == "." || rel == ".." || path.IsAbs(rel) || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("secure file %s is not under %s", secureFilePath, rootpath)
+	}
+	return rel, nil
+}
+
+// PutIfChanged uploads the local file at localfile as a secure file named after the last element of
+// secureFilePath, unless the secure file already has the same content. It returns whether the file was
+// uploaded. Cerberus does not expose checksums, so files of the same size are considered unchanged, unless the
+// server sends the MD5 or SHA-256 of the secure file as the ETag of a HEAD request, which is then compared to
+// the checksum of the local file
+func (r *SecureFile) PutIfChanged(secureFilePath, localfile string) (bool, error) {
+	f, err := os.Open(localfile)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	summary, err := r.Stat(secureFilePath)
+	if err != nil && err != ErrorSecureFileNotFound {
+		return false, err
+	}
+	if summary != nil && int64(summary.Size) == info.Size() {
+		same := true
+		if etag := r.checksumETag(secureFilePath); etag != "" {
+			if same, err = matchesChecksum(f, etag); err != nil {
+				return false, err
+			}
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return false, err
+			}
+		}
+		if same {
+			return false, nil
+		}
+	}
+	return true, r.Put(secureFilePath, path.Base(secureFilePath), f)
+}
+
+// checksumETag returns the ETag sent by the server for a HEAD request of the secure file if it is a hex
+// encoded MD5 or SHA-256, and an empty string otherwise, including when the request fails
+func (r *SecureFile) checksumETag(secureFilePath string) string {
+	resp, err := r.c.doRequest(context.Background(), http.MethodHead,
+		path.Join(secureFileBasePath, r.resolvePath(secureFilePath)),
+		map[string]string{},
+		nil,
+		"",
+		nil)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	// Weak ETags don't identify the content
+	etag := resp.Header.Get("ETag")
+	if strings.HasPrefix(etag, "W/") {
+		return ""
+	}
+	etag = strings.ToLower(strings.Trim(etag, `"`))
+	if _, err := hex.DecodeString(etag); err != nil || (len(etag) != 2*md5.Size && len(etag) != 2*sha256.Size) {
+		return ""
+	}
+	return etag
+}
+
+// matchesChecksum returns whether the content read from r has the checksum etag, as returned by checksumETag
+func matchesChecksum(r io.Reader, etag string) (bool, error) {
+	var h hash.Hash = sha256.New()
+	if len(etag) == 2*md5.Size {
+		h = md5.New()
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		return false, err
+	}
+	return hex.EncodeToString(h.Sum(nil)) == etag, nil
+}
+
+// sameChecksum returns whether the local file has the same SHA-256 as the given content
+func sameChecksum(localpath string, content []byte) (bool, error) {
+	f, err := os.Open(localpath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	local := sha256.New()
+	if _, err := io.Copy(local, f); err != nil {
+		return false, err
+	}
+	remote := sha256.Sum256(content)
+	return bytes.Equal(local.Sum(nil), remote[:]), nil
+}
@@ -0,0 +1,511 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		localpath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(localpath), 0755); err != nil {
+			t.Fatalf("Error creating test directory: %v", err)
+		}
+		if err := ioutil.WriteFile(localpath, []byte(content), 0644); err != nil {
+			t.Fatalf("Error creating test file: %v", err)
+		}
+	}
+}
+
+func TestGetDir(t *testing.T) {
+	Convey("A folder of secure files", t, func() {
+		server := newFakeSecureFileServer(map[string]string{
+			"app/sdb/a.txt":     "hello",
+			"app/sdb/sub/b.txt": "world",
+			"app/other/c.txt":   "ignored",
+		})
+		ts := httptest.NewServer(server)
+		dir, err := ioutil.TempDir("", "cerberus-test")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			ts.Close()
+			os.RemoveAll(dir)
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should download every file under the root", func() {
+			So(cl.SecureFile().GetDir("app/sdb", dir, SyncOptions{}), ShouldBeNil)
+			a, err := ioutil.ReadFile(filepath.Join(dir, "a.txt"))
+			So(err, ShouldBeNil)
+			So(string(a), ShouldEqual, "hello")
+			b, err := ioutil.ReadFile(filepath.Join(dir, "sub", "b.txt"))
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, "world")
+			_, err = os.Stat(filepath.Join(dir, "c.txt"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+		Convey("Should skip files with the same size when skipping unchanged files", func() {
+			writeTestFiles(t, dir, map[string]string{"a.txt": "HELLO"})
+			So(cl.SecureFile().GetDir("app/sdb", dir, SyncOptions{SkipUnchanged: true}), ShouldBeNil)
+			So(server.downloads, ShouldEqual, 1)
+			a, _ := ioutil.ReadFile(filepath.Join(dir, "a.txt"))
+			So(string(a), ShouldEqual, "HELLO")
+		})
+		Convey("Should replace files with a different checksum when comparing checksums", func() {
+			writeTestFiles(t, dir, map[string]string{"a.txt": "HELLO"})
+			So(cl.SecureFile().GetDir("app/sdb", dir, SyncOptions{SkipUnchanged: true, CompareChecksums: true}), ShouldBeNil)
+			a, _ := ioutil.ReadFile(filepath.Join(dir, "a.txt"))
+			So(string(a), ShouldEqual, "hello")
+		})
+		Convey("Should write files readable by the current user only", func() {
+			So(cl.SecureFile().GetDir("app/sdb", dir, SyncOptions{}), ShouldBeNil)
+			info, err := os.Stat(filepath.Join(dir, "sub", "b.txt"))
+			So(err, ShouldBeNil)
+			So(info.Mode().Perm(), ShouldEqual, DefaultDownloadFileMode)
+		})
+		Convey("Should set the mode of replaced files when comparing checksums", func() {
+			writeTestFiles(t, dir, map[string]string{"a.txt": "HELLO"})
+			So(os.Chmod(filepath.Join(dir, "a.txt"), 0644), ShouldBeNil)
+			So(cl.SecureFile().GetDir("app/sdb", dir, SyncOptions{SkipUnchanged: true, CompareChecksums: true}), ShouldBeNil)
+			info, err := os.Stat(filepath.Join(dir, "a.txt"))
+			So(err, ShouldBeNil)
+			So(info.Mode().Perm(), ShouldEqual, DefaultDownloadFileMode)
+		})
+		Convey("Should resolve the root with the secure path prefix", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithSecurePathPrefix("app"))
+			So(cl.SecureFile().GetDir("sdb", dir, SyncOptions{}), ShouldBeNil)
+			b, err := ioutil.ReadFile(filepath.Join(dir, "sub", "b.txt"))
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, "world")
+		})
+		Convey("Should compare checksums with the secure path prefix", func() {
+			writeTestFiles(t, dir, map[string]string{"a.txt": "HELLO"})
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithSecurePathPrefix("app"))
+			So(cl.SecureFile().GetDir("sdb", dir, SyncOptions{SkipUnchanged: true, CompareChecksums: true}), ShouldBeNil)
+			a, err := ioutil.ReadFile(filepath.Join(dir, "a.txt"))
+			So(err, ShouldBeNil)
+			So(string(a), ShouldEqual, "hello")
+		})
+		Convey("Should report the transferred and skipped files", func() {
+			writeTestFiles(t, dir, map[string]string{"a.txt": "HELLO"})
+			result, err := cl.SecureFile().GetDirWithResult("app/sdb", dir, SyncOptions{SkipUnchanged: true})
+			So(err, ShouldBeNil)
+			So(result.Transferred, ShouldResemble, []string{"app/sdb/sub/b.txt"})
+			So(result.Skipped, ShouldResemble, []string{"app/sdb/a.txt"})
+			So(result.Failed, ShouldBeEmpty)
+			So(result.Changed(), ShouldBeTrue)
+			So(result.Err(), ShouldBeNil)
+		})
+		Convey("Should download the other files when one fails", func() {
+			So(os.MkdirAll(filepath.Join(dir, "a.txt"), 0755), ShouldBeNil)
+			err := cl.SecureFile().GetDir("app/sdb", dir, SyncOptions{})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "1 of 2 operations failed: app/sdb/a.txt: ")
+			So(err.(*multiError).Unwrap(), ShouldHaveLength, 1)
+			b, err := ioutil.ReadFile(filepath.Join(dir, "sub", "b.txt"))
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, "world")
+		})
+		Convey("Should not write files outside of the local directory", func() {
+			server.files["app/sdb/../escape.txt"] = []byte("gotcha")
+			result, err := cl.SecureFile().GetDirWithResult("app/sdb", dir, SyncOptions{})
+			So(err, ShouldBeNil)
+			So(result.Failed, ShouldContainKey, "app/sdb/../escape.txt")
+			So(result.Transferred, ShouldHaveLength, 2)
+			_, err = os.Stat(filepath.Join(dir, "..", "escape.txt"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+		Convey("Should not leave a partial file behind when a download fails", func() {
+			failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet && r.URL.Path == secureFileBasePath+"/app/sdb/sub/b.txt" {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				server.ServeHTTP(w, r)
+			}))
+			defer failing.Close()
+			cl, _ := NewClient(GenerateMockAuth(failing.URL, "a-cool-token", false, false), nil)
+			So(cl.SecureFile().GetDir("app/sdb", dir, SyncOptions{}), ShouldNotBeNil)
+			_, err := os.Stat(filepath.Join(dir, "sub", "b.txt"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+	})
+}
+
+func TestPutDir(t *testing.T) {
+	Convey("A local folder", t, func() {
+		server := newFakeSecureFileServer(map[string]string{
+			"app/sdb/a.txt": "hello",
+		})
+		ts := httptest.NewServer(server)
+		dir, err := ioutil.TempDir("", "cerberus-test")
+		So(err, ShouldBeNil)
+		writeTestFiles(t, dir, map[string]string{
+			"a.txt":     "hello",
+			"sub/b.txt": "world",
+		})
+		Reset(func() {
+			ts.Close()
+			os.RemoveAll(dir)
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should upload every file", func() {
+			So(cl.SecureFile().PutDir(dir, "app/sdb", SyncOptions{}), ShouldBeNil)
+			So(server.uploads, ShouldEqual, 2)
+			So(string(server.files["app/sdb/sub/b.txt"]), ShouldEqual, "world")
+		})
+		Convey("Should skip unchanged files", func() {
+			So(cl.SecureFile().PutDir(dir, "app/sdb", SyncOptions{SkipUnchanged: true}), ShouldBeNil)
+			So(server.uploads, ShouldEqual, 1)
+		})
+		Convey("Should upload files with a different checksum when comparing checksums", func() {
+			writeTestFiles(t, dir, map[string]string{"a.txt": "HELLO"})
+			So(cl.SecureFile().PutDir(dir, "app/sdb", SyncOptions{SkipUnchanged: true, CompareChecksums: true}), ShouldBeNil)
+			So(server.uploads, ShouldEqual, 2)
+			So(string(server.files["app/sdb/a.txt"]), ShouldEqual, "HELLO")
+		})
+		Convey("Should resolve the base path with the secure path prefix", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithSecurePathPrefix("app"))
+			So(cl.SecureFile().PutDir(dir, "sdb", SyncOptions{SkipUnchanged: true}), ShouldBeNil)
+			So(server.uploads, ShouldEqual, 1)
+			So(string(server.files["app/sdb/sub/b.txt"]), ShouldEqual, "world")
+		})
+		Convey("Should skip ignored files and directories", func() {
+			writeTestFiles(t, dir, map[string]string{
+				".git/config":   "[core]",
+				"sub/.DS_Store": "junk",
+				"sub/b.txt.swp": "junk",
+				"build/out.txt": "junk",
+			})
+			opts := SyncOptions{Ignore: []string{"*.swp", "build"}, SkipDotfiles: true}
+			So(cl.SecureFile().PutDir(dir, "app/sdb", opts), ShouldBeNil)
+			So(server.uploads, ShouldEqual, 2)
+			So(server.files, ShouldContainKey, "app/sdb/sub/b.txt")
+			So(server.files, ShouldNotContainKey, "app/sdb/.git/config")
+			So(server.files, ShouldNotContainKey, "app/sdb/build/out.txt")
+		})
+		Convey("Should match patterns with a slash against the relative path", func() {
+			opts := SyncOptions{Ignore: []string{"/sub/*.txt"}}
+			So(cl.SecureFile().PutDir(dir, "app/sdb", opts), ShouldBeNil)
+			So(server.uploads, ShouldEqual, 1)
+			So(server.files, ShouldNotContainKey, "app/sdb/sub/b.txt")
+		})
+		Convey("Should upload dotfiles by default", func() {
+			writeTestFiles(t, dir, map[string]string{".env": "A=1"})
+			So(cl.SecureFile().PutDir(dir, "app/sdb", SyncOptions{}), ShouldBeNil)
+			So(server.files, ShouldContainKey, "app/sdb/.env")
+		})
+		Convey("Should report the transferred and skipped files", func() {
+			result, err := cl.SecureFile().PutDirWithResult(dir, "app/sdb", SyncOptions{SkipUnchanged: true})
+			So(err, ShouldBeNil)
+			So(result.Transferred, ShouldResemble, []string{filepath.Join(dir, "sub", "b.txt")})
+			So(result.Skipped, ShouldResemble, []string{filepath.Join(dir, "a.txt")})
+			So(result.Failed, ShouldBeEmpty)
+		})
+		Convey("Should report that nothing changed", func() {
+			So(cl.SecureFile().PutDir(dir, "app/sdb", SyncOptions{}), ShouldBeNil)
+			result, err := cl.SecureFile().PutDirWithResult(dir, "app/sdb", SyncOptions{SkipUnchanged: true})
+			So(err, ShouldBeNil)
+			So(result.Changed(), ShouldBeFalse)
+			So(result.Skipped, ShouldHaveLength, 2)
+		})
+		Convey("Should upload the other files when one fails", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/a.txt") {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				server.ServeHTTP(w, r)
+			}))
+			defer ts.Close()
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			err := cl.SecureFile().PutDir(dir, "app/sdb", SyncOptions{})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "1 of 2 operations failed: "+filepath.Join(dir, "a.txt"))
+			So(server.files, ShouldContainKey, "app/sdb/sub/b.txt")
+			result, err := cl.SecureFile().PutDirWithResult(dir, "app/sdb", SyncOptions{})
+			So(err, ShouldBeNil)
+			So(result.Failed, ShouldContainKey, filepath.Join(dir, "a.txt"))
+			So(result.Transferred, ShouldResemble, []string{filepath.Join(dir, "sub", "b.txt")})
+		})
+		Convey("Should fail on an invalid pattern", func() {
+			err := cl.SecureFile().PutDir(dir, "app/sdb", SyncOptions{Ignore: []string{"["}})
+			So(err, ShouldNotBeNil)
+			So(server.uploads, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestVerifyDir(t *testing.T) {
+	Convey("A partially uploaded local folder", t, func() {
+		server := newFakeSecureFileServer(map[string]string{
+			"app/sdb/a.txt":     "hello",
+			"app/sdb/sub/b.txt": "wor",
+			"app/sdb/extra.txt": "only remote",
+			"app/other/c.txt":   "other",
+		})
+		ts := httptest.NewServer(server)
+		dir, err := ioutil.TempDir("", "cerberus-test")
+		So(err, ShouldBeNil)
+		writeTestFiles(t, dir, map[string]string{
+			"a.txt":      "hello",
+			"sub/b.txt":  "world",
+			"sub/c.txt":  "missing",
+			"z/deep.txt": "missing too",
+		})
+		Reset(func() {
+			ts.Close()
+			os.RemoveAll(dir)
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should report the missing files and the size mismatches", func() {
+			missing, sizeMismatch, err := cl.SecureFile().VerifyDir("app/sdb", dir)
+			So(err, ShouldBeNil)
+			So(missing, ShouldResemble, []string{"sub/c.txt", "z/deep.txt"})
+			So(sizeMismatch, ShouldResemble, []string{"sub/b.txt"})
+		})
+		Convey("Should report nothing once the folder is uploaded", func() {
+			So(cl.SecureFile().PutDir(dir, "app/sdb", SyncOptions{}), ShouldBeNil)
+			missing, sizeMismatch, err := cl.SecureFile().VerifyDir("app/sdb", dir)
+			So(err, ShouldBeNil)
+			So(missing, ShouldBeEmpty)
+			So(sizeMismatch, ShouldBeEmpty)
+		})
+		Convey("Should return an error for a missing local folder", func() {
+			_, _, err := cl.SecureFile().VerifyDir("app/sdb", filepath.Join(dir, "nope"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRelativeSecurePath(t *testing.T) {
+	Convey("A path under the root", t, func() {
+		rel, err := relativeSecurePath("/app/sdb/", "app/sdb/sub/file.txt")
+		So(err, ShouldBeNil)
+		So(rel, ShouldEqual, "sub/file.txt")
+	})
+	Convey("A path outside of the root", t, func() {
+		_, err := relativeSecurePath("app/sdb", "app/sdbother/file.txt")
+		So(err, ShouldNotBeNil)
+	})
+	Convey("A path escaping the root", t, func() {
+		_, err := relativeSecurePath("app/sdb", "app/sdb/../../../home/u/.ssh/authorized_keys")
+		So(err, ShouldNotBeNil)
+		_, err = relativeSecurePath("", "../file.txt")
+		So(err, ShouldNotBeNil)
+		_, err = relativeSecurePath("app/sdb", "app/sdb/..")
+		So(err, ShouldNotBeNil)
+	})
+	Convey("A path with dot elements staying under the root", t, func() {
+		rel, err := relativeSecurePath("app/sdb", "app/sdb/sub/../file.txt")
+		So(err, ShouldBeNil)
+		So(rel, ShouldEqual, "file.txt")
+	})
+}
+
+func TestDeletePrefix(t *testing.T) {
+	Convey("A folder of secure files", t, func() {
+		server := newFakeSecureFileServer(map[string]string{
+			"app/sdb/a.txt":     "hello",
+			"app/sdb/sub/b.txt": "world",
+			"app/sdb/sub/c.txt": "!",
+			"app/other/d.txt":   "kept",
+		})
+		ts := httptest.NewServer(server)
+		Reset(func() {
+			ts.Close()
+		})
+		Convey("Should delete every file under the prefix when enabled", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithBulkDelete(true))
+			So(cl, ShouldNotBeNil)
+			deleted, err := cl.SecureFile().DeletePrefix("app/sdb", 2)
+			So(err, ShouldBeNil)
+			So(deleted, ShouldEqual, 3)
+			So(server.files, ShouldHaveLength, 1)
+			So(server.files, ShouldContainKey, "app/other/d.txt")
+		})
+		Convey("Should delete every file under the secure path prefix", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil,
+				WithBulkDelete(true), WithSecurePathPrefix("app/sdb"))
+			deleted, err := cl.SecureFile().DeletePrefix("/", 2)
+			So(err, ShouldBeNil)
+			So(deleted, ShouldEqual, 3)
+			So(server.files, ShouldHaveLength, 1)
+		})
+		Convey("Should refuse an empty prefix", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithBulkDelete(true))
+			So(cl, ShouldNotBeNil)
+			deleted, err := cl.SecureFile().DeletePrefix("/", 2)
+			So(err, ShouldNotBeNil)
+			So(deleted, ShouldEqual, 0)
+			So(server.files, ShouldHaveLength, 4)
+		})
+		Convey("Should refuse to delete when not enabled", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			So(cl, ShouldNotBeNil)
+			deleted, err := cl.SecureFile().DeletePrefix("app/sdb", 2)
+			So(err, ShouldEqual, ErrorBulkDeleteDisabled)
+			So(deleted, ShouldEqual, 0)
+			So(server.files, ShouldHaveLength, 4)
+		})
+	})
+}
+
+func TestGetIfNewer(t *testing.T) {
+	Convey("A secure file", t, func() {
+		updated := time.Date(2018, 6, 14, 10, 34, 56, 0, time.UTC)
+		server := newFakeSecureFileServer(map[string]string{"app/sdb/a.txt": "hello"})
+		server.updated = updated
+		ts := httptest.NewServer(server)
+		dir, err := ioutil.TempDir("", "cerberus-test")
+		So(err, ShouldBeNil)
+		localpath := filepath.Join(dir, "a.txt")
+		Reset(func() {
+			ts.Close()
+			os.RemoveAll(dir)
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should be downloaded if there is no local file", func() {
+			downloaded, err := cl.SecureFile().GetIfNewer("app/sdb/a.txt", localpath)
+			So(err, ShouldBeNil)
+			So(downloaded, ShouldBeTrue)
+			content, _ := ioutil.ReadFile(localpath)
+			So(string(content), ShouldEqual, "hello")
+			Convey("And should not be downloaded again until it changes", func() {
+				downloaded, err := cl.SecureFile().GetIfNewer("app/sdb/a.txt", localpath)
+				So(err, ShouldBeNil)
+				So(downloaded, ShouldBeFalse)
+				So(server.downloads, ShouldEqual, 1)
+				server.updated = updated.Add(time.Minute)
+				downloaded, err = cl.SecureFile().GetIfNewer("app/sdb/a.txt", localpath)
+				So(err, ShouldBeNil)
+				So(downloaded, ShouldBeTrue)
+			})
+		})
+		Convey("Should not be downloaded if the local file is newer", func() {
+			writeTestFiles(t, dir, map[string]string{"a.txt": "local"})
+			downloaded, err := cl.SecureFile().GetIfNewer("app/sdb/a.txt", localpath)
+			So(err, ShouldBeNil)
+			So(downloaded, ShouldBeFalse)
+			content, _ := ioutil.ReadFile(localpath)
+			So(string(content), ShouldEqual, "local")
+		})
+		Convey("Should be downloaded if the local file is older", func() {
+			writeTestFiles(t, dir, map[string]string{"a.txt": "local"})
+			So(os.Chtimes(localpath, updated.Add(-time.Hour), updated.Add(-time.Hour)), ShouldBeNil)
+			downloaded, err := cl.SecureFile().GetIfNewer("app/sdb/a.txt", localpath)
+			So(err, ShouldBeNil)
+			So(downloaded, ShouldBeTrue)
+			info, _ := os.Stat(localpath)
+			So(info.ModTime().Equal(updated), ShouldBeTrue)
+		})
+		Convey("Should error if the secure file does not exist", func() {
+			downloaded, err := cl.SecureFile().GetIfNewer("app/sdb/missing.txt", localpath)
+			So(err, ShouldEqual, ErrorSecureFileNotFound)
+			So(downloaded, ShouldBeFalse)
+		})
+	})
+}
+
+func TestPutIfChanged(t *testing.T) {
+	Convey("A local file and a secure file", t, func() {
+		server := newFakeSecureFileServer(map[string]string{"app/sdb/a.txt": "hello"})
+		var etag string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead && etag != "" {
+				w.Header().Set("ETag", etag)
+				return
+			}
+			server.ServeHTTP(w, r)
+		}))
+		dir, err := ioutil.TempDir("", "cerberus-test")
+		So(err, ShouldBeNil)
+		localpath := filepath.Join(dir, "a.txt")
+		Reset(func() {
+			ts.Close()
+			os.RemoveAll(dir)
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should upload a file which does not exist", func() {
+			writeTestFiles(t, dir, map[string]string{"b.txt": "new"})
+			uploaded, err := cl.SecureFile().PutIfChanged("app/sdb/b.txt", filepath.Join(dir, "b.txt"))
+			So(err, ShouldBeNil)
+			So(uploaded, ShouldBeTrue)
+			So(string(server.files["app/sdb/b.txt"]), ShouldEqual, "new")
+		})
+		Convey("Should upload a file of another size", func() {
+			writeTestFiles(t, dir, map[string]string{"a.txt": "hello world"})
+			uploaded, err := cl.SecureFile().PutIfChanged("app/sdb/a.txt", localpath)
+			So(err, ShouldBeNil)
+			So(uploaded, ShouldBeTrue)
+			So(string(server.files["app/sdb/a.txt"]), ShouldEqual, "hello world")
+		})
+		Convey("Should skip a file of the same size without a checksum", func() {
+			writeTestFiles(t, dir, map[string]string{"a.txt": "HELLO"})
+			uploaded, err := cl.SecureFile().PutIfChanged("app/sdb/a.txt", localpath)
+			So(err, ShouldBeNil)
+			So(uploaded, ShouldBeFalse)
+			So(server.uploads, ShouldEqual, 0)
+		})
+		Convey("Should compare the checksum sent as ETag", func() {
+			// SHA-256 of "hello"
+			etag = `"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"`
+			writeTestFiles(t, dir, map[string]string{"a.txt": "hello"})
+			uploaded, err := cl.SecureFile().PutIfChanged("app/sdb/a.txt", localpath)
+			So(err, ShouldBeNil)
+			So(uploaded, ShouldBeFalse)
+			writeTestFiles(t, dir, map[string]string{"a.txt": "HELLO"})
+			uploaded, err = cl.SecureFile().PutIfChanged("app/sdb/a.txt", localpath)
+			So(err, ShouldBeNil)
+			So(uploaded, ShouldBeTrue)
+			So(string(server.files["app/sdb/a.txt"]), ShouldEqual, "HELLO")
+		})
+		Convey("Should compare an MD5 sent as ETag", func() {
+			// MD5 of "hello"
+			etag = `"5d41402abc4b2a76b9719d911017c592"`
+			writeTestFiles(t, dir, map[string]string{"a.txt": "HELLO"})
+			uploaded, err := cl.SecureFile().PutIfChanged("app/sdb/a.txt", localpath)
+			So(err, ShouldBeNil)
+			So(uploaded, ShouldBeTrue)
+		})
+		Convey("Should ignore an ETag which is not a checksum", func() {
+			etag = `W/"5d41402abc4b2a76b9719d911017c592"`
+			writeTestFiles(t, dir, map[string]string{"a.txt": "HELLO"})
+			uploaded, err := cl.SecureFile().PutIfChanged("app/sdb/a.txt", localpath)
+			So(err, ShouldBeNil)
+			So(uploaded, ShouldBeFalse)
+		})
+		Convey("Should error if the local file does not exist", func() {
+			_, err := cl.SecureFile().PutIfChanged("app/sdb/a.txt", filepath.Join(dir, "missing.txt"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// secretReference matches ${cerberus:path#key}
+var secretReference = regexp.MustCompile(`\$\{cerberus:([^#}]+)#([^}]+)\}`)
+
+// ExpandTemplate replaces every ${cerberus:path#key} in template with the value of key in the secret at
+// path, read with the Secret client. Each secret is read once. If some references cannot be resolved,
+// the error lists all of them. The context is checked before each secret is read
+func (c *Client) ExpandTemplate(ctx context.Context, template string) (string, error) {
+	var secrets = map[string]map[string]interface{}{}
+	var missing []string
+	for _, match := range secretReference.FindAllStringSubmatch(template, -1) {
+		secretPath, key := match[1], match[2]
+		data, read := secrets[secretPath]
+		if !read {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+			secret, err := c.Secret().Read(secretPath)
+			if err != nil {
+				return "", fmt.Errorf("Error while reading secret %s: %v", secretPath, err)
+			}
+			if secret != nil {
+				data = secret.Data
+			}
+			secrets[secretPath] = data
+		}
+		if _, ok := data[key]; !ok {
+			missing = append(missing, secretPath+"#"+key)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("Unresolved secret references: %s", strings.Join(missing, ", "))
+	}
+	return secretReference.ReplaceAllStringFunc(template, func(reference string) string {
+		match := secretReference.FindStringSubmatch(reference)
+		value := secrets[match[1]][match[2]]
+		if s, ok := value.(string); ok {
+			return s
+		}
+		return fmt.Sprint(value)
+	}), nil
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ErrorDownloadTooLarge is returned when a download is larger than the limit set with WithMaxDownloadBytes
+var ErrorDownloadTooLarge = fmt.Errorf("download exceeds the maximum allowed size")
+
+// ProgressFunc is called as a secure file is transferred with the number of bytes transferred so far
+// and the total size. The total is -1 when the server did not send a Content-Length
+type ProgressFunc func(secureFilePath string, transferred, total int64)
+
+// Operations reported to a TransferMetricsFunc
+const (
+	TransferUpload   = "upload"
+	TransferDownload = "download"
+)
+
+// TransferMetricsFunc is called once at the end of each secure file upload or download, successful or not,
+// with the operation (TransferUpload or TransferDownload) and the number of bytes of file content transferred
+type TransferMetricsFunc func(operation, secureFilePath string, bytes int64)
+
+// reportTransfer reports a transfer to the metrics function set with WithTransferMetrics, if any
+func (c *Client) reportTransfer(operation, secureFilePath string, n int64) {
+	if c.transferMetrics != nil {
+		c.transferMetrics(operation, secureFilePath, n)
+	}
+}
+
+// ErrorResponseTooLarge is returned when a JSON response is larger than the limit set with WithMaxResponseBytes
+var ErrorResponseTooLarge = fmt.Errorf("response exceeds the maximum allowed size")
+
+// limitedReader reads at most limit bytes and fails with err if there is more.
+// It works without knowing the size in advance, so it also enforces the limit on chunked responses
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	err       error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// Check whether there is anything left past the limit
+		var b [1]byte
+		n, err := l.r.Read(b[:])
+		if n > 0 {
+			return 0, l.err
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// progressWriter counts the bytes written through it and reports them to a ProgressFunc
+type progressWriter struct {
+	w        io.Writer
+	path     string
+	total    int64
+	written  int64
+	progress ProgressFunc
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	if p.progress != nil {
+		p.progress(p.path, p.written, p.total)
+	}
+	return n, err
+}
+
+// copyDownload copies a download response to output. It enforces the maximum download size, reports
+// progress and checks that the number of bytes received matches the Content-Length. When the server
+// does not send a Content-Length, progress is reported with an unknown total and the size check is skipped
+func (r *SecureFile) copyDownload(secureFilePath string, output io.Writer, resp *http.Response) error {
+	body, err := r.limitDownload(resp)
+	if err != nil {
+		return err
+	}
+	pw := &progressWriter{w: output, path: secureFilePath, total: resp.ContentLength, progress: r.c.progress}
+	_, err = io.Copy(pw, body)
+	r.c.reportTransfer(TransferDownload, secureFilePath, pw.written)
+	if err != nil {
+		return err
+	}
+	if resp.ContentLength < 0 {
+		if r.c.logger != nil {
+			r.c.logger.Printf("cerberus: no Content-Length for secure file %s, skipping size verification", secureFilePath)
+		}
+		return nil
+	}
+	if pw.written != resp.ContentLength {
+		return fmt.Errorf("error while downloading secure file %s: received %d bytes, expected %d",
+			secureFilePath, pw.written, resp.ContentLength)
+	}
+	return nil
+}
+
+// limitDownload returns the body of a download response, limited to the maximum download size if there is one
+func (r *SecureFile) limitDownload(resp *http.Response) (io.Reader, error) {
+	max := r.c.maxDownloadBytes
+	if max <= 0 {
+		return resp.Body, nil
+	}
+	if resp.ContentLength > max {
+		return nil, ErrorDownloadTooLarge
+	}
+	return &limitedReader{r: resp.Body, remaining: max, err: ErrorDownloadTooLarge}, nil
+}
+
+// ErrorRangeNotSupported is returned by GetRange when the server ignores the requested range and sends the whole file
+var ErrorRangeNotSupported = fmt.Errorf("server does not support byte ranges")
+
+// GetRange returns length bytes of a secure file starting at start, without downloading the rest of the file.
+// Fewer bytes are returned if the file ends before. It returns ErrorRangeNotSupported if the server sends the
+// whole file instead of the range
+func (r *SecureFile) GetRange(secureFilePath string, start, length int64) ([]byte, error) {
+	if start < 0 || length <= 0 {
+		return nil, fmt.Errorf("error while downloading secure file %s: invalid range of %d bytes at %d", secureFilePath, length, start)
+	}
+	resp, err := r.c.doRequest(context.Background(), http.MethodGet,
+		path.Join(secureFileBasePath, r.resolvePath(secureFilePath)),
+		map[string]string{},
+		http.Header{
+			"Accept": []string{r.c.downloadAccept},
+			"Range":  []string{fmt.Sprintf("bytes=%d-%d", start, start+length-1)},
+		},
+		"",
+		nil)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error while downloading secure file: %v", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		return nil, ErrorRangeNotSupported
+	case http.StatusRequestedRangeNotSatisfiable:
+		return nil, fmt.Errorf("error while downloading secure file %s: range starts after the end of the file", secureFilePath)
+	default:
+		return nil, fmt.Errorf("error while trying to download secure file %s. Got HTTP status code %d",
+			secureFilePath,
+			resp.StatusCode)
+	}
+	// Don't trust the server to send no more than the range
+	content, err := ioutil.ReadAll(io.LimitReader(resp.Body, length))
+	r.c.reportTransfer(TransferDownload, secureFilePath, int64(len(content)))
+	return content, err
+}
+
+// utf8BOM is the byte order mark some editors add at the start of UTF-8 files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// GetText downloads a secure file as text. A leading UTF-8 byte order mark is removed and, if the client was
+// created using WithNormalizeLineEndings(true), Windows (\r\n) and old Mac (\r) line endings are replaced by \n
+func (r *SecureFile) GetText(secureFilePath string) (string, error) {
+	var content bytes.Buffer
+	if err := r.Get(secureFilePath, &content); err != nil {
+		return "", err
+	}
+	text := string(bytes.TrimPrefix(content.Bytes(), utf8BOM))
+	if r.c.normalizeLineEndings {
+		text = strings.Replace(text, "\r\n", "\n", -1)
+		text = strings.Replace(text, "\r", "\n", -1)
+	}
+	return text, nil
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// chunkedServer replies with a body sent using chunked transfer encoding, so without a Content-Length
+func chunkedServer(content string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(http.StatusOK)
+		// Flushing before writing the body forces chunked encoding
+		w.(http.Flusher).Flush()
+		w.Write([]byte(content))
+	}))
+}
+
+func TestDownloadWithoutContentLength(t *testing.T) {
+	Convey("A chunked download", t, func() {
+		ts := chunkedServer("hello world")
+		Reset(func() {
+			ts.Close()
+		})
+		Convey("Should report progress with an unknown total", func() {
+			var lastTransferred, lastTotal int64
+			logger := &recordingLogger{}
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil,
+				WithLogger(logger),
+				WithProgress(func(p string, transferred, total int64) {
+					lastTransferred = transferred
+					lastTotal = total
+				}))
+			var buf bytes.Buffer
+			So(cl.SecureFile().Get("/test/file/hello.txt", &buf), ShouldBeNil)
+			So(buf.String(), ShouldEqual, "hello world")
+			So(lastTransferred, ShouldEqual, 11)
+			So(lastTotal, ShouldEqual, -1)
+			Convey("And should note that the size was not verified", func() {
+				So(logger.String(), ShouldContainSubstring, "skipping size verification")
+			})
+		})
+		Convey("Should still enforce the maximum download size", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithMaxDownloadBytes(5))
+			var buf bytes.Buffer
+			So(cl.SecureFile().Get("/test/file/hello.txt", &buf), ShouldEqual, ErrorDownloadTooLarge)
+			So(buf.Len(), ShouldBeLessThanOrEqualTo, 5)
+		})
+		Convey("Should allow a download at the maximum size", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithMaxDownloadBytes(11))
+			var buf bytes.Buffer
+			So(cl.SecureFile().Get("/test/file/hello.txt", &buf), ShouldBeNil)
+			So(buf.String(), ShouldEqual, "hello world")
+		})
+	})
+
+	Convey("A download with a Content-Length", t, withBinaryTestServer(http.StatusOK,
+		"/v1/secure-file/test/file/hello.txt",
+		http.MethodGet,
+		"hello.txt",
+		[]byte("hello world"),
+		func(ts *httptest.Server) {
+			Convey("Should report progress with the total", func() {
+				var lastTotal int64
+				cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil,
+					WithProgress(func(p string, transferred, total int64) {
+						lastTotal = total
+					}))
+				var buf bytes.Buffer
+				So(cl.SecureFile().Get("/test/file/hello.txt", &buf), ShouldBeNil)
+				So(lastTotal, ShouldEqual, 11)
+			})
+			Convey("Should reject a download over the maximum size before reading it", func() {
+				cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithMaxDownloadBytes(5))
+				var buf bytes.Buffer
+				So(cl.SecureFile().Get("/test/file/hello.txt", &buf), ShouldEqual, ErrorDownloadTooLarge)
+				So(buf.Len(), ShouldEqual, 0)
+			})
+		}))
+}
+
+func TestGetText(t *testing.T) {
+	Convey("A text file with a byte order mark and Windows line endings", t, withBinaryTestServer(http.StatusOK,
+		"/v1/secure-file/test/file/config.ini",
+		http.MethodGet,
+		"config.ini",
+		append([]byte{0xEF, 0xBB, 0xBF}, []byte("[db]\r\nhost=localhost\r\nold=mac\rend")...),
+		func(ts *httptest.Server) {
+			Convey("Should have the byte order mark removed", func() {
+				cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+				text, err := cl.SecureFile().GetText("/test/file/config.ini")
+				So(err, ShouldBeNil)
+				So(text, ShouldEqual, "[db]\r\nhost=localhost\r\nold=mac\rend")
+			})
+			Convey("Should have its line endings normalized when enabled", func() {
+				cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithNormalizeLineEndings(true))
+				text, err := cl.SecureFile().GetText("/test/file/config.ini")
+				So(err, ShouldBeNil)
+				So(text, ShouldEqual, "[db]\nhost=localhost\nold=mac\nend")
+			})
+		}))
+
+	Convey("A text file without a byte order mark", t, withBinaryTestServer(http.StatusOK,
+		"/v1/secure-file/test/file/hello.txt",
+		http.MethodGet,
+		"hello.txt",
+		[]byte("hello"),
+		func(ts *httptest.Server) {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			Convey("Should be returned as is", func() {
+				text, err := cl.SecureFile().GetText("/test/file/hello.txt")
+				So(err, ShouldBeNil)
+				So(text, ShouldEqual, "hello")
+			})
+		}))
+}
+
+func TestSecureFileGetRange(t *testing.T) {
+	Convey("A server supporting ranges", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			content := strings.NewReader("0123456789")
+			if r.URL.Path == "/v1/secure-file/app/sdb/no-range.txt" {
+				r.Header.Del("Range")
+			}
+			http.ServeContent(w, r, "file.txt", time.Time{}, content)
+		}))
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should return the requested bytes", func() {
+			content, err := cl.SecureFile().GetRange("app/sdb/a.txt", 2, 3)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "234")
+		})
+		Convey("Should return the end of the file if the range goes past it", func() {
+			content, err := cl.SecureFile().GetRange("app/sdb/a.txt", 8, 10)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "89")
+		})
+		Convey("Should error if the range starts after the end of the file", func() {
+			_, err := cl.SecureFile().GetRange("app/sdb/a.txt", 20, 1)
+			So(err, ShouldNotBeNil)
+		})
+		Convey("Should error if the whole file is sent", func() {
+			_, err := cl.SecureFile().GetRange("app/sdb/no-range.txt", 2, 3)
+			So(err, ShouldEqual, ErrorRangeNotSupported)
+		})
+		Convey("Should error on an invalid range", func() {
+			_, err := cl.SecureFile().GetRange("app/sdb/a.txt", 0, 0)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+// transferRecord is a transfer reported to a TransferMetricsFunc
+type transferRecord struct {
+	operation string
+	path      string
+	bytes     int64
+}
+
+func TestTransferMetrics(t *testing.T) {
+	Convey("A client reporting transfer metrics", t, func() {
+		fake := newFakeSecureFileServer(map[string]string{"app/sdb/hello.txt": "hello world"})
+		ts := httptest.NewServer(fake)
+		Reset(func() {
+			ts.Close()
+		})
+		var records []transferRecord
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil,
+			WithTransferMetrics(func(operation, p string, n int64) {
+				records = append(records, transferRecord{operation, p, n})
+			}))
+		So(cl, ShouldNotBeNil)
+		Convey("Should report the bytes of a download", func() {
+			var buf bytes.Buffer
+			So(cl.SecureFile().Get("app/sdb/hello.txt", &buf), ShouldBeNil)
+			So(records, ShouldResemble, []transferRecord{{TransferDownload, "app/sdb/hello.txt", 11}})
+		})
+		Convey("Should report the bytes of an upload once", func() {
+			_, err := cl.SecureFile().PutAndStat("app/sdb/new.txt", "new.txt", strings.NewReader("new content"))
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []transferRecord{{TransferUpload, "app/sdb/new.txt", 11}})
+		})
+		Convey("Should report a streamed download when it is closed", func() {
+			reader, _, err := cl.SecureFile().GetReaderWithType("app/sdb/hello.txt")
+			So(err, ShouldBeNil)
+			ioutil.ReadAll(io.LimitReader(reader, 5))
+			So(records, ShouldBeEmpty)
+			reader.Close()
+			reader.Close()
+			So(records, ShouldResemble, []transferRecord{{TransferDownload, "app/sdb/hello.txt", 5}})
+		})
+		Convey("Should not report a range the server does not send", func() {
+			content, err := cl.SecureFile().GetRange("app/sdb/hello.txt", 0, 5)
+			So(err, ShouldEqual, ErrorRangeNotSupported)
+			So(content, ShouldBeNil)
+			So(records, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// DefaultDialTimeout is the maximum time spent establishing a connection to Cerberus
+const DefaultDialTimeout = 5 * time.Second
+
+// HTTP2Mode is how the client uses HTTP/2, see WithHTTP2
+type HTTP2Mode int
+
+const (
+	// HTTP2Disabled sends all requests with HTTP/1.1. This is the default
+	HTTP2Disabled HTTP2Mode = iota
+	// HTTP2ALPN negotiates HTTP/2 during the TLS handshake of https URLs, falling back to HTTP/1.1 when
+	// the server doesn't support it. Plain http URLs use HTTP/1.1
+	HTTP2ALPN
+	// HTTP2PriorKnowledge also sends requests to plain http URLs with HTTP/2 without negotiating it, which
+	// fails if the server doesn't support it. It is meant for endpoints known to speak HTTP/2, such as a
+	// local proxy in front of Cerberus. https URLs negotiate HTTP/2 like with HTTP2ALPN
+	HTTP2PriorKnowledge
+)
+
+// newTransport returns a transport with the same settings as http.DefaultTransport, except for the
+// timeout used when establishing connections, whether connections are kept open between requests and
+// whether HTTP/2 is used
+func newTransport(settings transportSettings) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   settings.dialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	t := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		DisableKeepAlives:     settings.disableKeepAlives,
+	}
+	if settings.http2 == HTTP2Disabled {
+		return t
+	}
+	// This only fails if the transport already supports HTTP/2, which a new one doesn't
+	http2.ConfigureTransport(t)
+	if settings.http2 == HTTP2PriorKnowledge {
+		t.RegisterProtocol("http", &http2.Transport{
+			AllowHTTP: true,
+			// Connect without TLS, as the scheme is http
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return dialer.Dial(network, addr)
+			},
+		})
+	}
+	return t
+}
+
+// transportSettings are the client options a transport depends on
+type transportSettings struct {
+	dialTimeout       time.Duration
+	disableKeepAlives bool
+	http2             HTTP2Mode
+}
+
+// sharedTransports holds one transport per combination of settings, shared by all the clients using
+// them, so creating many clients doesn't open and leak many sets of connections
+var sharedTransports = struct {
+	sync.Mutex
+	transports map[transportSettings]*http.Transport
+}{transports: map[transportSettings]*http.Transport{}}
+
+// sharedTransport returns the transport shared by the clients with the given settings
+func sharedTransport(settings transportSettings) *http.Transport {
+	sharedTransports.Lock()
+	defer sharedTransports.Unlock()
+	t, ok := sharedTransports.transports[settings]
+	if !ok {
+		t = newTransport(settings)
+		sharedTransports.transports[settings] = t
+	}
+	return t
+}
+
+// healthCheckPath is a cheap endpoint used to open connections
+var healthCheckPath = "/healthcheck"
+
+// Warmup opens up to n connections to Cerberus ahead of time by sending that many concurrent health check
+// requests, so later requests don't pay for connecting and the TLS handshake. n is capped to the number of
+// idle connections the transport keeps per host (http.DefaultMaxIdleConnsPerHost unless configured), since
+// connections over that limit would be closed right away. It does nothing if the client was created with
+// WithDisableKeepAlives(true). Only connection errors are returned
+func (c *Client) Warmup(ctx context.Context, n int) error {
+	if t, ok := c.httpClient.Transport.(*http.Transport); ok {
+		// Connections are closed after each request, so there is nothing to open ahead of time
+		if t.DisableKeepAlives {
+			return nil
+		}
+		limit := t.MaxIdleConnsPerHost
+		if limit <= 0 {
+			limit = http.DefaultMaxIdleConnsPerHost
+		}
+		if t.MaxIdleConns > 0 && t.MaxIdleConns < limit {
+			limit = t.MaxIdleConns
+		}
+		if n > limit {
+			n = limit
+		}
+	}
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var firstErr error
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.doRequest(ctx, http.MethodGet, healthCheckPath, nil, nil, "", nil)
+			// The connection is opened even if the health check is redirected
+			if _, ok := err.(*RedirectError); ok {
+				return
+			}
+			if err != nil {
+				lock.Lock()
+				defer lock.Unlock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			// The connection is only reused once the body is read and closed
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
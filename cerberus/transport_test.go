@@ -0,0 +1,280 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/http2"
+)
+
+func TestDialTimeout(t *testing.T) {
+	Convey("A new client", t, func() {
+		cl, _ := NewClient(GenerateMockAuth("http://example.com", "a-cool-token", false, false), nil)
+		Convey("Should use the default dial timeout", func() {
+			So(cl.dialTimeout, ShouldEqual, DefaultDialTimeout)
+			// The transport is shared, so it is not printed by the assertion while other clients use it
+			So(cl.httpClient.Transport != nil, ShouldBeTrue)
+		})
+	})
+
+	Convey("A client with a short dial timeout", t, func() {
+		// This address is not routable, so connecting to it hangs until the timeout
+		cl, _ := NewClient(GenerateMockAuth("http://10.255.255.1", "a-cool-token", false, false), nil,
+			WithDialTimeout(100*time.Millisecond))
+		Convey("Should fail fast when the server cannot be reached", func() {
+			start := time.Now()
+			_, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+			So(err, ShouldNotBeNil)
+			So(time.Since(start), ShouldBeLessThan, 2*time.Second)
+		})
+	})
+}
+
+// connectionCountingServer returns a server counting the connections opened to it. Requests are
+// slowed down so concurrent requests need their own connection
+func connectionCountingServer(count *int, lock *sync.Mutex) *httptest.Server {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	ts.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			lock.Lock()
+			*count++
+			lock.Unlock()
+		}
+	}
+	ts.Start()
+	return ts
+}
+
+func TestWarmup(t *testing.T) {
+	Convey("A warmup", t, func() {
+		var lock sync.Mutex
+		var connections int
+		ts := connectionCountingServer(&connections, &lock)
+		Reset(func() {
+			ts.Close()
+		})
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should open the requested connections", func() {
+			So(cl.Warmup(context.Background(), 2), ShouldBeNil)
+			lock.Lock()
+			So(connections, ShouldEqual, 2)
+			lock.Unlock()
+			Convey("And should reuse them for the next requests", func() {
+				cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+				lock.Lock()
+				defer lock.Unlock()
+				So(connections, ShouldEqual, 2)
+			})
+		})
+		Convey("Should not open more connections than the transport keeps idle", func() {
+			So(cl.Warmup(context.Background(), 10), ShouldBeNil)
+			lock.Lock()
+			defer lock.Unlock()
+			So(connections, ShouldEqual, http.DefaultMaxIdleConnsPerHost)
+		})
+	})
+
+	Convey("A warmup against a server which cannot be reached", t, func() {
+		cl, _ := NewClient(GenerateMockAuth("http://127.0.0.1:32876", "a-cool-token", false, false), nil)
+		Convey("Should error", func() {
+			So(cl.Warmup(context.Background(), 1), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestDisableKeepAlives(t *testing.T) {
+	Convey("Sequential requests", t, func() {
+		var lock sync.Mutex
+		var connections int
+		ts := connectionCountingServer(&connections, &lock)
+		Reset(func() {
+			ts.Close()
+		})
+		send := func(cl *Client) {
+			for i := 0; i < 2; i++ {
+				resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+				So(err, ShouldBeNil)
+				resp.Body.Close()
+			}
+		}
+		Convey("Should reuse the connection by default", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			send(cl)
+			lock.Lock()
+			defer lock.Unlock()
+			So(connections, ShouldEqual, 1)
+		})
+		Convey("Should open a connection per request when keep-alives are disabled", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithDisableKeepAlives(true))
+			send(cl)
+			lock.Lock()
+			So(connections, ShouldEqual, 2)
+			lock.Unlock()
+			Convey("And should not warm up connections", func() {
+				So(cl.Warmup(context.Background(), 2), ShouldBeNil)
+				lock.Lock()
+				defer lock.Unlock()
+				So(connections, ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+func TestSharedTransport(t *testing.T) {
+	Convey("Clients with the same settings", t, func() {
+		a, _ := NewClient(GenerateMockAuth("http://example.com", "a-cool-token", false, false), nil)
+		b, _ := NewClient(GenerateMockAuth("http://example.com", "a-cool-token", false, false), nil)
+		Convey("Should share a transport", func() {
+			So(a.transport == b.transport, ShouldBeTrue)
+		})
+		Convey("Should not share it with clients with other settings", func() {
+			c, _ := NewClient(GenerateMockAuth("http://example.com", "a-cool-token", false, false), nil,
+				WithDialTimeout(time.Second))
+			So(c.transport == a.transport, ShouldBeFalse)
+			So(c.transport.DisableKeepAlives, ShouldBeFalse)
+		})
+	})
+
+	Convey("A client with a shared transport", t, func() {
+		var lock sync.Mutex
+		var connections int
+		ts := connectionCountingServer(&connections, &lock)
+		Reset(func() {
+			ts.Close()
+		})
+		shared := &http.Transport{}
+		Convey("Should reuse the connections of other clients", func() {
+			for i := 0; i < 2; i++ {
+				cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil,
+					WithSharedTransport(shared), WithDisableKeepAlives(true))
+				So(cl.httpClient.Transport == shared, ShouldBeTrue)
+				resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+				So(err, ShouldBeNil)
+				resp.Body.Close()
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			So(connections, ShouldEqual, 1)
+		})
+	})
+}
+
+// protoServer records the protocol of the requests it receives
+func protoServer(protos *[]string, lock *sync.Mutex) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		*protos = append(*protos, r.Proto)
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestHTTP2(t *testing.T) {
+	send := func(cl *Client) {
+		resp, err := cl.DoRequest(http.MethodGet, "/v1/blah", map[string]string{}, nil)
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+	}
+
+	Convey("A plain http server", t, func() {
+		var lock sync.Mutex
+		var protos []string
+		ts := httptest.NewServer(protoServer(&protos, &lock))
+		Reset(func() {
+			ts.Close()
+		})
+		Convey("Should get HTTP/1.1 requests by default", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			send(cl)
+			So(protos, ShouldResemble, []string{"HTTP/1.1"})
+		})
+		Convey("Should get HTTP/1.1 requests when HTTP/2 is negotiated", func() {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithHTTP2(HTTP2ALPN))
+			send(cl)
+			So(protos, ShouldResemble, []string{"HTTP/1.1"})
+		})
+	})
+
+	Convey("A plain http server only speaking HTTP/2", t, func() {
+		var lock sync.Mutex
+		var protos []string
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			listener.Close()
+		})
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				go (&http2.Server{}).ServeConn(conn, &http2.ServeConnOpts{Handler: protoServer(&protos, &lock)})
+			}
+		}()
+		Convey("Should get HTTP/2 requests with prior knowledge", func() {
+			cl, _ := NewClient(GenerateMockAuth("http://"+listener.Addr().String(), "a-cool-token", false, false), nil,
+				WithHTTP2(HTTP2PriorKnowledge))
+			send(cl)
+			send(cl)
+			lock.Lock()
+			defer lock.Unlock()
+			So(protos, ShouldResemble, []string{"HTTP/2.0", "HTTP/2.0"})
+		})
+	})
+
+	Convey("HTTP/2 negotiated over TLS", t, func() {
+		var lock sync.Mutex
+		var protos []string
+		// newTLSClient trusts the certificate of the test server
+		newTLSClient := func(ts *httptest.Server) *Client {
+			transport := newTransport(transportSettings{dialTimeout: DefaultDialTimeout, http2: HTTP2ALPN})
+			pool := x509.NewCertPool()
+			pool.AddCert(ts.Certificate())
+			transport.TLSClientConfig.RootCAs = pool
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil, WithSharedTransport(transport))
+			return cl
+		}
+		Convey("Should be used when the server supports it", func() {
+			ts := httptest.NewUnstartedServer(protoServer(&protos, &lock))
+			ts.EnableHTTP2 = true
+			ts.StartTLS()
+			defer ts.Close()
+			send(newTLSClient(ts))
+			So(protos, ShouldResemble, []string{"HTTP/2.0"})
+		})
+		Convey("Should fall back to HTTP/1.1 when the server doesn't support it", func() {
+			ts := httptest.NewTLSServer(protoServer(&protos, &lock))
+			defer ts.Close()
+			send(newTLSClient(ts))
+			So(protos, ShouldResemble, []string{"HTTP/1.1"})
+		})
+	})
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Nike-Inc/cerberus-go-client/api"
+)
+
+// UploadErrorKind classifies why an upload was rejected by the server
+type UploadErrorKind int
+
+const (
+	// UploadFailed is any failure not covered by another kind
+	UploadFailed UploadErrorKind = iota
+	// UploadTooLarge means the file is larger than the server accepts (413)
+	UploadTooLarge
+	// UploadInvalidPath means the server rejected the path of the file (400)
+	UploadInvalidPath
+	// UploadUnauthorized means the token does not allow writing the file (401 or 403)
+	UploadUnauthorized
+	// UploadServerError means the server failed to handle the upload (5xx)
+	UploadServerError
+)
+
+func (k UploadErrorKind) String() string {
+	switch k {
+	case UploadTooLarge:
+		return "file too large"
+	case UploadInvalidPath:
+		return "invalid path"
+	case UploadUnauthorized:
+		return "unauthorized"
+	case UploadServerError:
+		return "server error"
+	default:
+		return "upload failed"
+	}
+}
+
+// UploadError is returned by Put when the server rejects an upload
+type UploadError struct {
+	Kind       UploadErrorKind
+	Path       string
+	StatusCode int
+	// Details is the error sent by the server, nil if the response had no error body
+	Details *api.ErrorResponse
+}
+
+func (e *UploadError) Error() string {
+	msg := fmt.Sprintf("error while trying to upload secure file %s (%s). Got HTTP status code %d", e.Path, e.Kind, e.StatusCode)
+	if e.Details != nil {
+		msg += ": " + e.Details.Error()
+	}
+	return msg
+}
+
+// uploadErrorKind returns the kind of upload error for a status code
+func uploadErrorKind(statusCode int) UploadErrorKind {
+	switch {
+	case statusCode == http.StatusRequestEntityTooLarge:
+		return UploadTooLarge
+	case statusCode == http.StatusBadRequest:
+		return UploadInvalidPath
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return UploadUnauthorized
+	case statusCode >= 500:
+		return UploadServerError
+	default:
+		return UploadFailed
+	}
+}
+
+// newUploadError builds the UploadError of a rejected upload, reading the error details from the body
+func newUploadError(secureFilePath string, statusCode int, body io.Reader) *UploadError {
+	e := &UploadError{
+		Kind:       uploadErrorKind(statusCode),
+		Path:       secureFilePath,
+		StatusCode: statusCode,
+	}
+	if apiErr, ok := handleAPIError(body).(api.ErrorResponse); ok {
+		e.Details = &apiErr
+	}
+	return e
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2017 Nike Inc.
+
+Licensed under the Apache License, Version 2.0 (the License);
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an AS IS BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cerberus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var uploadErrorBody = `{
+	"error_id": "a-cool-error-id",
+	"errors": [ {
+		"code": 99105,
+		"message": "The secure file path is invalid"
+	} ]
+}`
+
+func TestUploadErrors(t *testing.T) {
+	var cases = []struct {
+		code int
+		kind UploadErrorKind
+	}{
+		{http.StatusRequestEntityTooLarge, UploadTooLarge},
+		{http.StatusBadRequest, UploadInvalidPath},
+		{http.StatusUnauthorized, UploadUnauthorized},
+		{http.StatusForbidden, UploadUnauthorized},
+		{http.StatusInternalServerError, UploadServerError},
+		{http.StatusBadGateway, UploadServerError},
+		{http.StatusConflict, UploadFailed},
+	}
+	for _, tc := range cases {
+		Convey("A put rejected with "+http.StatusText(tc.code), t, WithTestServer(tc.code, "/v1/secure-file/test/file/hello.txt", http.MethodPost, "", func(ts *httptest.Server) {
+			cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+			So(cl, ShouldNotBeNil)
+			Convey("Should return an UploadError of the right kind", func() {
+				err := cl.SecureFile().Put("/test/file/hello.txt", "hello.txt", getTestInputReader(t, "hello"))
+				So(err, ShouldHaveSameTypeAs, &UploadError{})
+				uploadErr := err.(*UploadError)
+				So(uploadErr.Kind, ShouldEqual, tc.kind)
+				So(uploadErr.StatusCode, ShouldEqual, tc.code)
+				So(uploadErr.Path, ShouldEqual, "/test/file/hello.txt")
+				So(uploadErr.Details, ShouldBeNil)
+			})
+		}))
+	}
+
+	Convey("A put rejected with an error body", t, WithTestServer(http.StatusBadRequest, "/v1/secure-file/test/file/hello.txt", http.MethodPost, uploadErrorBody, func(ts *httptest.Server) {
+		cl, _ := NewClient(GenerateMockAuth(ts.URL, "a-cool-token", false, false), nil)
+		So(cl, ShouldNotBeNil)
+		Convey("Should include the details", func() {
+			err := cl.SecureFile().Put("/test/file/hello.txt", "hello.txt", getTestInputReader(t, "hello"))
+			So(err, ShouldNotBeNil)
+			uploadErr := err.(*UploadError)
+			So(uploadErr.Details, ShouldNotBeNil)
+			So(uploadErr.Details.ErrorID, ShouldEqual, "a-cool-error-id")
+			So(uploadErr.Details.Errors[0].Message, ShouldEqual, "The secure file path is invalid")
+			So(err.Error(), ShouldContainSubstring, "invalid path")
+			So(err.Error(), ShouldContainSubstring, "a-cool-error-id")
+		})
+	}))
+}